@@ -4,29 +4,98 @@ import (
 	"context"
 	"github.com/ahornerr/artifacts/httperror"
 	"github.com/promiseofcake/artifactsmmo-go-client/client"
+	"log"
 	"sync"
+	"time"
 )
 
 type Bank struct {
-	items map[string]int
+	snapshot Snapshot
 
-	client  *client.ClientWithResponses
-	mux     sync.Mutex
-	updates chan<- map[string]int
+	subscribers    map[int]chan Change
+	lastSubscriber int
+
+	reservations    map[int]*Reservation
+	lastReservation int
+
+	// ledger is nil unless SetLedger was called
+	ledger *Ledger
+
+	client *client.ClientWithResponses
+	mux    sync.Mutex
 }
 
+// Items is a copy of what's in the bank right now
 func (b *Bank) Items() map[string]int {
+	return b.Snapshot().Items()
+}
+
+func NewBank(c *client.ClientWithResponses) *Bank {
+	return &Bank{
+		snapshot:     Snapshot{items: map[string]int{}},
+		subscribers:  map[int]chan Change{},
+		reservations: map[int]*Reservation{},
+		client:       c,
+	}
+}
+
+// SetLedger records every deposit and withdrawal from now on
+func (b *Bank) SetLedger(ledger *Ledger) {
 	b.mux.Lock()
 	defer b.mux.Unlock()
 
-	return b.items
+	b.ledger = ledger
 }
 
-func NewBank(c *client.ClientWithResponses, updates chan<- map[string]int) *Bank {
-	return &Bank{
-		items:   map[string]int{},
-		client:  c,
-		updates: updates,
+// Deposited updates the bank to what the deposit left in it and records that owner deposited the items.
+// Both happen under one lock so the ledger balance is the one the deposit left.
+func (b *Bank) Deposited(owner string, newItems []client.SimpleItemSchema, itemCode string, quantity int) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.update(newItems)
+	b.record(owner, ActionDeposit, itemCode, quantity)
+}
+
+// GoldDeposited updates the bank's gold and records that owner deposited some
+func (b *Bank) GoldDeposited(owner string, gold int, quantity int) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.set(b.snapshot.items, gold)
+	b.record(owner, ActionDepositGold, "", quantity)
+}
+
+// GoldWithdrawn updates the bank's gold and records that owner withdrew some
+func (b *Bank) GoldWithdrawn(owner string, gold int, quantity int) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.set(b.snapshot.items, gold)
+	b.record(owner, ActionWithdrawGold, "", -quantity)
+}
+
+// record adds an entry to the ledger. Gold has no item code.
+func (b *Bank) record(owner, action, itemCode string, delta int) {
+	if b.ledger == nil {
+		return
+	}
+
+	balance := b.snapshot.Quantity(itemCode)
+	if itemCode == "" {
+		balance = b.snapshot.Gold
+	}
+
+	err := b.ledger.Append(Entry{
+		Time:      time.Now(),
+		Character: owner,
+		Action:    action,
+		Item:      itemCode,
+		Delta:     delta,
+		Balance:   balance,
+	})
+	if err != nil {
+		log.Println("Error writing bank ledger:", err)
 	}
 }
 
@@ -65,10 +134,39 @@ func (b *Bank) Update(newItems []client.SimpleItemSchema) {
 	b.mux.Lock()
 	defer b.mux.Unlock()
 
-	b.items = map[string]int{}
+	b.update(newItems)
+}
+
+// update must be called with the lock held
+func (b *Bank) update(newItems []client.SimpleItemSchema) {
+	items := map[string]int{}
 	for _, bankItem := range newItems {
-		b.items[bankItem.Code] += bankItem.Quantity
+		items[bankItem.Code] += bankItem.Quantity
 	}
 
-	b.updates <- b.items
+	b.set(items, b.snapshot.Gold)
+}
+
+func (b *Bank) Gold() int {
+	return b.Snapshot().Gold
+}
+
+func (b *Bank) LoadGold(ctx context.Context) (int, error) {
+	resp, err := b.client.GetBankGoldsMyBankGoldGetWithResponse(ctx)
+	if err != nil {
+		return 0, err
+	} else if resp.JSON200 == nil {
+		return 0, httperror.NewHTTPError(resp.StatusCode(), resp.Body)
+	}
+
+	b.UpdateGold(resp.JSON200.Data.Quantity)
+
+	return resp.JSON200.Data.Quantity, nil
+}
+
+func (b *Bank) UpdateGold(gold int) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.set(b.snapshot.items, gold)
 }
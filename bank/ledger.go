@@ -0,0 +1,160 @@
+package bank
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	ActionDeposit      = "deposit"
+	ActionWithdraw     = "withdraw"
+	ActionDepositGold  = "deposit_gold"
+	ActionWithdrawGold = "withdraw_gold"
+)
+
+// Entry is one deposit or withdrawal. Delta is negative for withdrawals and Balance is what the bank had afterward.
+// Gold entries have no Item.
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Character string    `json:"character"`
+	Action    string    `json:"action"`
+	Item      string    `json:"item"`
+	Delta     int       `json:"delta"`
+	Balance   int       `json:"balance"`
+}
+
+// maxLedgerEntries is how many of the most recent entries are kept in memory for queries. The file keeps all of them.
+const maxLedgerEntries = 20000
+
+// Ledger is an append-only history of the bank, kept as one JSON entry per line in a local file
+type Ledger struct {
+	file    *os.File
+	entries []Entry
+	mux     sync.Mutex
+}
+
+// OpenLedger reads the entries already in the file at path and appends new ones to it, creating it if needed
+func OpenLedger(path string) (*Ledger, error) {
+	b, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var entry Entry
+		if err = json.Unmarshal(line, &entry); err != nil {
+			// A line cut short by a crash, the rest are still good
+			log.Printf("Skipping bad bank ledger line %q: %s\n", line, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	entries = trimEntries(entries)
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't let the next entry end up on the same line as a cut short one
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		if _, err = file.Write([]byte("\n")); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return &Ledger{file: file, entries: entries}, nil
+}
+
+func (l *Ledger) Append(entry Entry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	l.entries = trimEntries(append(l.entries, entry))
+	_, err = l.file.Write(append(b, '\n'))
+	return err
+}
+
+// trimEntries drops the oldest entries past maxLedgerEntries, a tenth more than needed so it isn't every append
+func trimEntries(entries []Entry) []Entry {
+	if len(entries) <= maxLedgerEntries {
+		return entries
+	}
+	keep := maxLedgerEntries - maxLedgerEntries/10
+	return slices.Clone(entries[len(entries)-keep:])
+}
+
+func (l *Ledger) Close() error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	return l.file.Close()
+}
+
+// Query matches entries on every field that's set
+type Query struct {
+	Character string
+	Action    string
+	Item      string
+	Since     time.Time
+	Until     time.Time
+}
+
+func (q Query) matches(entry Entry) bool {
+	return (q.Character == "" || entry.Character == q.Character) &&
+		(q.Action == "" || entry.Action == q.Action) &&
+		(q.Item == "" || entry.Item == q.Item) &&
+		(q.Since.IsZero() || !entry.Time.Before(q.Since)) &&
+		(q.Until.IsZero() || entry.Time.Before(q.Until))
+}
+
+// Find returns the matching entries out of the recent ones kept in memory, oldest first
+func (l *Ledger) Find(q Query) []Entry {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	var found []Entry
+	for _, entry := range l.entries {
+		if q.matches(entry) {
+			found = append(found, entry)
+		}
+	}
+	return found
+}
+
+// ByCharacter adds up the matching deltas for each character. Asking for withdrawals of an item shows who took it.
+func (l *Ledger) ByCharacter(q Query) map[string]int {
+	totals := map[string]int{}
+	for _, entry := range l.Find(q) {
+		totals[entry.Character] += entry.Delta
+	}
+	return totals
+}
+
+// LastEmptied is the withdrawal that most recently left the bank with none of the item
+func (l *Ledger) LastEmptied(itemCode string) (Entry, bool) {
+	entries := l.Find(Query{Action: ActionWithdraw, Item: itemCode})
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Balance == 0 {
+			return entries[i], true
+		}
+	}
+	return Entry{}, false
+}
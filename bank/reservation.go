@@ -0,0 +1,141 @@
+package bank
+
+import (
+	"errors"
+	"fmt"
+	"github.com/promiseofcake/artifactsmmo-go-client/client"
+	"time"
+)
+
+var ErrInsufficientStock = errors.New("not enough unreserved items in the bank")
+
+// Reservation claims items in the bank for one character until they withdraw them, release them or it expires
+type Reservation struct {
+	ID      int
+	Owner   string
+	Items   map[string]int
+	Expires time.Time
+}
+
+// Reserve claims all of the items for owner, or none of them if the bank doesn't have enough that other characters
+// haven't already reserved
+func (b *Bank) Reserve(owner string, items map[string]int, ttl time.Duration) (*Reservation, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.expireReservations()
+
+	reserved := b.reserved(owner)
+	for itemCode, quantity := range items {
+		if unreserved := b.snapshot.Quantity(itemCode) - reserved[itemCode]; unreserved < quantity {
+			return nil, fmt.Errorf("%w: want %d %s, %d unreserved", ErrInsufficientStock, quantity, itemCode, max(0, unreserved))
+		}
+	}
+
+	b.lastReservation++
+	reservation := &Reservation{
+		ID:      b.lastReservation,
+		Owner:   owner,
+		Items:   map[string]int{},
+		Expires: time.Now().Add(ttl),
+	}
+	for itemCode, quantity := range items {
+		if quantity > 0 {
+			reservation.Items[itemCode] = quantity
+		}
+	}
+	b.reservations[reservation.ID] = reservation
+
+	// Callers get a copy so the quantities can't change under them when items are withdrawn
+	return copyReservation(reservation), nil
+}
+
+// Release gives back whatever is left of the reservation
+func (b *Bank) Release(reservation *Reservation) {
+	if reservation == nil {
+		return
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	delete(b.reservations, reservation.ID)
+}
+
+// Withdrawn updates the bank to what the withdrawal left in it, records that owner withdrew the items and takes
+// them out of their reservations, all under one lock
+func (b *Bank) Withdrawn(owner string, newItems []client.SimpleItemSchema, itemCode string, quantity int) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.update(newItems)
+	b.record(owner, ActionWithdraw, itemCode, -quantity)
+
+	for id, reservation := range b.reservations {
+		if quantity <= 0 {
+			break
+		}
+		if reservation.Owner != owner || reservation.Items[itemCode] == 0 {
+			continue
+		}
+
+		taken := min(quantity, reservation.Items[itemCode])
+		quantity -= taken
+		reservation.Items[itemCode] -= taken
+		if reservation.Items[itemCode] == 0 {
+			delete(reservation.Items, itemCode)
+		}
+		if len(reservation.Items) == 0 {
+			delete(b.reservations, id)
+		}
+	}
+}
+
+// Available is the bank as owner should see it, without the items other characters have reserved
+func (b *Bank) Available(owner string) map[string]int {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.expireReservations()
+
+	reserved := b.reserved(owner)
+	available := map[string]int{}
+	for itemCode, quantity := range b.snapshot.items {
+		if quantity -= reserved[itemCode]; quantity > 0 {
+			available[itemCode] = quantity
+		}
+	}
+	return available
+}
+
+// reserved totals every reservation that doesn't belong to owner
+func (b *Bank) reserved(owner string) map[string]int {
+	reserved := map[string]int{}
+	for _, reservation := range b.reservations {
+		if reservation.Owner == owner {
+			continue
+		}
+		for itemCode, quantity := range reservation.Items {
+			reserved[itemCode] += quantity
+		}
+	}
+	return reserved
+}
+
+func (b *Bank) expireReservations() {
+	now := time.Now()
+	for id, reservation := range b.reservations {
+		if now.After(reservation.Expires) {
+			delete(b.reservations, id)
+		}
+	}
+}
+
+func copyReservation(reservation *Reservation) *Reservation {
+	c := *reservation
+	c.Items = map[string]int{}
+	for itemCode, quantity := range reservation.Items {
+		c.Items[itemCode] = quantity
+	}
+	return &c
+}
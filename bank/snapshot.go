@@ -0,0 +1,94 @@
+package bank
+
+// subscriberBuffer is how many changes a subscriber can fall behind before it starts missing them
+const subscriberBuffer = 16
+
+// Snapshot is the bank's items and gold at one point in time. It never changes, the bank makes a new one with a
+// higher Version every time its contents do.
+type Snapshot struct {
+	Version int
+	Gold    int
+	items   map[string]int
+}
+
+func (s Snapshot) Quantity(itemCode string) int {
+	return s.items[itemCode]
+}
+
+// Items is a copy of the items that the caller is free to change
+func (s Snapshot) Items() map[string]int {
+	items := make(map[string]int, len(s.items))
+	for itemCode, quantity := range s.items {
+		items[itemCode] = quantity
+	}
+	return items
+}
+
+// Change is sent to subscribers when the bank's items change
+type Change struct {
+	// Snapshot is the bank after the change
+	Snapshot Snapshot
+
+	// Deltas is how much each item that changed went up or down by. Every subscriber gets the same map, so don't change it.
+	Deltas map[string]int
+
+	GoldDelta int
+}
+
+func (b *Bank) Snapshot() Snapshot {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	return b.snapshot
+}
+
+// Subscribe to changes until unsubscribe is called. A subscriber that falls too far behind misses changes,
+// which shows up as a gap in the snapshot versions. The latest snapshot always has the whole bank.
+func (b *Bank) Subscribe() (changes <-chan Change, unsubscribe func()) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.lastSubscriber++
+	id := b.lastSubscriber
+	c := make(chan Change, subscriberBuffer)
+	b.subscribers[id] = c
+
+	return c, func() {
+		b.mux.Lock()
+		defer b.mux.Unlock()
+
+		if _, ok := b.subscribers[id]; ok {
+			delete(b.subscribers, id)
+			close(c)
+		}
+	}
+}
+
+// set replaces the snapshot and tells subscribers what changed. It must be called with the lock held.
+func (b *Bank) set(items map[string]int, gold int) {
+	deltas := map[string]int{}
+	for itemCode, quantity := range items {
+		if delta := quantity - b.snapshot.items[itemCode]; delta != 0 {
+			deltas[itemCode] = delta
+		}
+	}
+	for itemCode, quantity := range b.snapshot.items {
+		if _, ok := items[itemCode]; !ok {
+			deltas[itemCode] = -quantity
+		}
+	}
+	goldDelta := gold - b.snapshot.Gold
+	if len(deltas) == 0 && goldDelta == 0 {
+		return
+	}
+
+	b.snapshot = Snapshot{Version: b.snapshot.Version + 1, Gold: gold, items: items}
+
+	change := Change{Snapshot: b.snapshot, Deltas: deltas, GoldDelta: goldDelta}
+	for _, c := range b.subscribers {
+		select {
+		case c <- change:
+		default:
+		}
+	}
+}
@@ -4,16 +4,21 @@ import (
 	"context"
 	"fmt"
 	"github.com/ahornerr/artifacts/bank"
+	"github.com/ahornerr/artifacts/combat"
 	"github.com/ahornerr/artifacts/game"
 	"github.com/ahornerr/artifacts/httperror"
 	"github.com/promiseofcake/artifactsmmo-go-client/client"
 	"log"
-	"math"
+	"maps"
+	"math/rand"
 	"slices"
 	"sync"
 	"time"
 )
 
+// maxFightHistory is how many fights each character keeps for calibrating the combat simulator
+const maxFightHistory = 500
+
 var equipmentSlotOrder = []string{
 	"weapon",
 	"helmet",
@@ -24,11 +29,27 @@ var equipmentSlotOrder = []string{
 	"ring2",
 	"leg_armor",
 	"boots",
+	"artifact1",
+	"artifact2",
+	"artifact3",
+	"consumable1",
+	"consumable2",
+}
+
+// slotsByType are the slots an item type can go in. The same artifact or consumable can't be in two slots at once.
+var slotsByType = map[string][]string{
+	"ring":       {"ring1", "ring2"},
+	"artifact":   {"artifact1", "artifact2", "artifact3"},
+	"consumable": {"consumable1", "consumable2"},
 }
 
+// maxEquippedConsumables is how much food we take into a fight per consumable slot
+const maxEquippedConsumables = 50
+
 type Character struct {
 	client           *client.ClientWithResponses
 	bank             *bank.Bank
+	catalog          *game.Catalog
 	Name             string
 	CooldownExpires  time.Time
 	CooldownDuration int
@@ -41,6 +62,9 @@ type Character struct {
 	Inventory map[string]int
 	Equipment map[string]string
 
+	// EquipmentQuantity is how many of the item are in each consumable slot
+	EquipmentQuantity map[string]int
+
 	InventoryMaxItems int
 	Skin              string
 	Gold              int
@@ -51,23 +75,33 @@ type Character struct {
 	TaskTotal    int
 
 	updates chan<- *Character
+	fights  *combat.History
+
+	// bestSets are the sets GetBestOwnedEquipment found recently
+	bestSets *setCache
 
 	State []string
 	mux   sync.Mutex
 }
 
-func NewCharacter(c *client.ClientWithResponses, bank *bank.Bank, updates chan<- *Character, name string) *Character {
+func NewCharacter(c *client.ClientWithResponses, bank *bank.Bank, catalog *game.Catalog, updates chan<- *Character, name string) *Character {
 	return &Character{
 		client:    c,
 		bank:      bank,
+		catalog:   catalog,
 		Name:      name,
 		Levels:    map[string]int{},
 		Xp:        map[string]int{},
 		MaxXp:     map[string]int{},
 		Inventory: map[string]int{},
 		Equipment: map[string]string{},
-		updates:   updates,
-		State:     []string{},
+
+		EquipmentQuantity: map[string]int{},
+
+		updates:  updates,
+		fights:   combat.NewHistory(maxFightHistory),
+		bestSets: newSetCache(),
+		State:    []string{},
 	}
 }
 
@@ -147,16 +181,25 @@ func (c *Character) update(ctx context.Context, char client.CharacterSchema, wai
 	}
 
 	c.Equipment = map[string]string{
-		// TODO: Add artifact1-3, consumable1-2
-		"amulet":     char.AmuletSlot,
-		"body_armor": char.BodyArmorSlot,
-		"boots":      char.BootsSlot,
-		"helmet":     char.HelmetSlot,
-		"leg_armor":  char.LegArmorSlot,
-		"ring1":      char.Ring1Slot,
-		"ring2":      char.Ring2Slot,
-		"shield":     char.ShieldSlot,
-		"weapon":     char.WeaponSlot,
+		"amulet":      char.AmuletSlot,
+		"artifact1":   char.Artifact1Slot,
+		"artifact2":   char.Artifact2Slot,
+		"artifact3":   char.Artifact3Slot,
+		"body_armor":  char.BodyArmorSlot,
+		"boots":       char.BootsSlot,
+		"consumable1": char.Consumable1Slot,
+		"consumable2": char.Consumable2Slot,
+		"helmet":      char.HelmetSlot,
+		"leg_armor":   char.LegArmorSlot,
+		"ring1":       char.Ring1Slot,
+		"ring2":       char.Ring2Slot,
+		"shield":      char.ShieldSlot,
+		"weapon":      char.WeaponSlot,
+	}
+
+	c.EquipmentQuantity = map[string]int{
+		"consumable1": char.Consumable1SlotQuantity,
+		"consumable2": char.Consumable2SlotQuantity,
 	}
 
 	c.Task = char.Task
@@ -181,8 +224,18 @@ func (c *Character) MaxInventoryItems() int {
 	return c.InventoryMaxItems
 }
 
+// Bank is what's in the bank less what other characters have reserved
 func (c *Character) Bank() map[string]int {
-	return c.bank.Items()
+	return c.bank.Available(c.Name)
+}
+
+// BankVersion goes up every time the items in the bank change
+func (c *Character) BankVersion() int {
+	return c.bank.Snapshot().Version
+}
+
+func (c *Character) Catalog() *game.Catalog {
+	return c.catalog
 }
 
 func (c *Character) InventoryCount() int {
@@ -280,6 +333,23 @@ func (c *Character) Fight(ctx context.Context) (*client.FightSchema, error) {
 
 	result := &resp.JSON200.Data.Fight
 
+	// The fight already happened so this is what we were wearing, the character update below may not be
+	if monster := c.catalog.Monsters.Get(c.catalog.Maps.MonsterAt(c.Location)); monster != nil {
+		fightLog := combat.ParseLogs(result.Logs)
+		player := c.Fighter()
+		// We don't track HP between fights, the log says what we started with
+		player.Hp = fightLog.CharacterHp
+		c.fights.Add(combat.Record{
+			Time:      time.Now(),
+			Character: c.Name,
+			Monster:   monster.Code,
+			Player:    player,
+			Opponent:  combat.NewMonster(monster),
+			Turns:     result.Turns,
+			Log:       fightLog,
+		})
+	}
+
 	c.PushState(result.Logs[len(result.Logs)-1])
 	defer c.PopState()
 
@@ -334,7 +404,7 @@ func (c *Character) DepositBank(ctx context.Context, code string, quantity int)
 		return nil, httperror.NewHTTPError(resp.StatusCode(), resp.Body)
 	}
 
-	c.bank.Update(resp.JSON200.Data.Bank)
+	c.bank.Deposited(c.Name, resp.JSON200.Data.Bank, code, quantity)
 	c.update(ctx, resp.JSON200.Data.Character, true)
 
 	return resp.JSON200.Data.Bank, nil
@@ -354,12 +424,64 @@ func (c *Character) WithdrawBank(ctx context.Context, code string, quantity int)
 		return nil, httperror.NewHTTPError(resp.StatusCode(), resp.Body)
 	}
 
-	c.bank.Update(resp.JSON200.Data.Bank)
+	c.bank.Withdrawn(c.Name, resp.JSON200.Data.Bank, code, quantity)
 	c.update(ctx, resp.JSON200.Data.Character, true)
 
 	return resp.JSON200.Data.Bank, nil
 }
 
+func (c *Character) DepositBankGold(ctx context.Context, quantity int) (int, error) {
+	c.PushState("Depositing %d gold", quantity)
+	defer c.PopState()
+
+	resp, err := c.client.ActionDepositBankGoldMyNameActionBankDepositGoldPostWithResponse(ctx, c.Name, client.ActionDepositBankGoldMyNameActionBankDepositGoldPostJSONRequestBody{
+		Quantity: quantity,
+	})
+	if err != nil {
+		return 0, err
+	} else if resp.JSON200 == nil {
+		return 0, httperror.NewHTTPError(resp.StatusCode(), resp.Body)
+	}
+
+	c.bank.GoldDeposited(c.Name, resp.JSON200.Data.Bank.Quantity, quantity)
+	c.update(ctx, resp.JSON200.Data.Character, true)
+
+	return resp.JSON200.Data.Bank.Quantity, nil
+}
+
+func (c *Character) WithdrawBankGold(ctx context.Context, quantity int) (int, error) {
+	c.PushState("Withdrawing %d gold", quantity)
+	defer c.PopState()
+
+	resp, err := c.client.ActionWithdrawBankGoldMyNameActionBankWithdrawGoldPostWithResponse(ctx, c.Name, client.ActionWithdrawBankGoldMyNameActionBankWithdrawGoldPostJSONRequestBody{
+		Quantity: quantity,
+	})
+	if err != nil {
+		return 0, err
+	} else if resp.JSON200 == nil {
+		return 0, httperror.NewHTTPError(resp.StatusCode(), resp.Body)
+	}
+
+	c.bank.GoldWithdrawn(c.Name, resp.JSON200.Data.Bank.Quantity, quantity)
+	c.update(ctx, resp.JSON200.Data.Character, true)
+
+	return resp.JSON200.Data.Bank.Quantity, nil
+}
+
+// BankGold is how much gold is in the bank
+func (c *Character) BankGold() int {
+	return c.bank.Gold()
+}
+
+// ReserveBank claims bank items so other characters leave them alone until they're withdrawn or released
+func (c *Character) ReserveBank(items map[string]int, ttl time.Duration) (*bank.Reservation, error) {
+	return c.bank.Reserve(c.Name, items, ttl)
+}
+
+func (c *Character) ReleaseBank(reservation *bank.Reservation) {
+	c.bank.Release(reservation)
+}
+
 func (c *Character) MoveClosest(ctx context.Context, locations []game.Location) error {
 	if c.IsAtOneOf(locations) {
 		return nil
@@ -508,8 +630,51 @@ func (c *Character) Recycle(ctx context.Context, itemCode string, quantity int)
 	return &resp.JSON200.Data.Details, nil
 }
 
+// BuyGE buys items from the grand exchange. Price is what we expect to pay for each one, the purchase fails if it changed.
+func (c *Character) BuyGE(ctx context.Context, itemCode string, quantity int, price int) (*client.GETransactionSchema, error) {
+	c.PushState("Buying %d %s for %d gold each", quantity, itemCode, price)
+	defer c.PopState()
+
+	resp, err := c.client.ActionGeBuyItemMyNameActionGeBuyPostWithResponse(ctx, c.Name, client.ActionGeBuyItemMyNameActionGeBuyPostJSONRequestBody{
+		Code:     itemCode,
+		Quantity: quantity,
+		Price:    price,
+	})
+	if err != nil {
+		return nil, err
+	} else if resp.JSON200 == nil {
+		return nil, httperror.NewHTTPError(resp.StatusCode(), resp.Body)
+	}
+
+	c.update(ctx, resp.JSON200.Data.Character, true)
+
+	return &resp.JSON200.Data.Transaction, nil
+}
+
+// SellGE sells items to the grand exchange. Price is what we expect to get for each one, the sale fails if it changed.
+func (c *Character) SellGE(ctx context.Context, itemCode string, quantity int, price int) (*client.GETransactionSchema, error) {
+	c.PushState("Selling %d %s for %d gold each", quantity, itemCode, price)
+	defer c.PopState()
+
+	resp, err := c.client.ActionGeSellItemMyNameActionGeSellPostWithResponse(ctx, c.Name, client.ActionGeSellItemMyNameActionGeSellPostJSONRequestBody{
+		Code:     itemCode,
+		Quantity: quantity,
+		Price:    price,
+	})
+	if err != nil {
+		return nil, err
+	} else if resp.JSON200 == nil {
+		return nil, httperror.NewHTTPError(resp.StatusCode(), resp.Body)
+	}
+
+	c.update(ctx, resp.JSON200.Data.Character, true)
+
+	return &resp.JSON200.Data.Transaction, nil
+}
+
 var equipmentTypes = map[string]bool{
 	"amulet":     true,
+	"artifact":   true,
 	"body_armor": true,
 	"boots":      true,
 	"helmet":     true,
@@ -519,16 +684,24 @@ var equipmentTypes = map[string]bool{
 	"weapon":     true,
 }
 
+// fightSimulations is how many fights are simulated to estimate the outcome of the best set
+const fightSimulations = 1000
+
 type EquipmentSet struct {
-	Equipment          map[string]*game.Item
-	TurnsToKillMonster int
-	TurnsToKillPlayer  int
-	Haste              int8
+	Equipment map[string]*game.Item
+
+	// Quantities of the item to put in each consumable slot
+	Quantities map[string]int
+
+	// Outcome of fighting the target with this set. For resources, ExpectedTurns is the turns to gather.
+	Outcome combat.Outcome
+	Haste   int
 }
 
 func NewEquipmentSet(other *EquipmentSet) *EquipmentSet {
 	s := &EquipmentSet{
-		Equipment: map[string]*game.Item{},
+		Equipment:  map[string]*game.Item{},
+		Quantities: map[string]int{},
 	}
 
 	if other != nil {
@@ -536,15 +709,50 @@ func NewEquipmentSet(other *EquipmentSet) *EquipmentSet {
 		for slot, item := range other.Equipment {
 			s.Equipment[slot] = item
 		}
+		for slot, quantity := range other.Quantities {
+			s.Quantities[slot] = quantity
+		}
+		s.Outcome = other.Outcome
+		s.Haste = other.Haste
 	}
 
 	return s
 }
 
+// Quantity of the item that goes in the slot, which is only ever more than 1 for consumables
+func (s *EquipmentSet) Quantity(slot string) int {
+	if quantity, ok := s.Quantities[slot]; ok {
+		return quantity
+	}
+	return 1
+}
+
+func isConsumableSlot(slot string) bool {
+	return slices.Contains(slotsByType["consumable"], slot)
+}
+
+// inOtherSlot is true when the item is an artifact or consumable that's already in one of the other slots it can go in
+func inOtherSlot(equipment map[string]*game.Item, slot string, item *game.Item) bool {
+	if item.Type == "ring" {
+		return false
+	}
+	for _, other := range slotsByType[item.Type] {
+		if other != slot && equipment[other] == item {
+			return true
+		}
+	}
+	return false
+}
+
+// isCombatFood is a consumable that does something in a fight
+func isCombatFood(item *game.Item) bool {
+	return item.Stats != nil && (item.Stats.Restore > 0 || item.Stats.HasBoost())
+}
+
 func (c *Character) GetEquipmentUpgrades() ([]*game.Item, []*game.Item) {
 	var withinLevel []*game.Item
 	var aboveLevel []*game.Item
-	for _, item := range game.Items.GetAll() {
+	for _, item := range c.catalog.Items.GetAll() {
 		if _, ok := equipmentTypes[item.Type]; !ok {
 			continue
 		}
@@ -561,24 +769,21 @@ func (c *Character) GetEquipmentUpgrades() ([]*game.Item, []*game.Item) {
 	return withinLevel, aboveLevel
 }
 
-func (c *Character) GetBestOwnedEquipment(targetStats *game.Stats) *EquipmentSet {
-	invBankAndEquipment := map[*game.Item]bool{}
-	for itemCode := range c.Bank() {
-		invBankAndEquipment[game.Items.Get(itemCode)] = true
-	}
-	for itemCode := range c.Inventory {
-		invBankAndEquipment[game.Items.Get(itemCode)] = true
-	}
-	for _, itemCode := range c.Equipment {
-		if itemCode != "" {
-			invBankAndEquipment[game.Items.Get(itemCode)] = true
-		}
-	}
+// GetBestOwnedEquipment finds the best set for the target out of everything the character owns, as judged by the objective
+func (c *Character) GetBestOwnedEquipment(targetStats *game.Stats, objective Objective) *EquipmentSet {
+	equipped := c.EquippedItems()
+	_, equipmentQuantity := c.equipment()
+	owned := objective.available(c.ownedEquipment(), equipped, equipmentQuantity)
 
 	slotsEquipment := map[string][]*game.Item{}
-	for item := range invBankAndEquipment {
+	for item := range owned {
 		itemType := item.Type
-		if _, ok := equipmentTypes[itemType]; !ok {
+		if itemType == "consumable" {
+			// Food is only useful in fights
+			if targetStats.IsResource || !isCombatFood(item) {
+				continue
+			}
+		} else if _, ok := equipmentTypes[itemType]; !ok {
 			continue
 		}
 
@@ -586,37 +791,26 @@ func (c *Character) GetBestOwnedEquipment(targetStats *game.Stats) *EquipmentSet
 			continue
 		}
 
-		if targetStats.IsResource != (item.SubType == "tool") {
+		if itemType != "consumable" && targetStats.IsResource != (item.SubType == "tool") {
 			continue
 		}
 
 		// TODO: Exclude items that are too low level/never going to be the best
 
-		if itemType == "ring" {
-			if _, ok := slotsEquipment["ring1"]; !ok {
-				slotsEquipment["ring1"] = []*game.Item{}
-			}
-			slotsEquipment["ring1"] = append(slotsEquipment["ring1"], item)
-
-			if _, ok := slotsEquipment["ring2"]; !ok {
-				slotsEquipment["ring2"] = []*game.Item{}
-			}
-			slotsEquipment["ring2"] = append(slotsEquipment["ring2"], item)
-		} else {
-			if _, ok := slotsEquipment[itemType]; !ok {
-				slotsEquipment[itemType] = []*game.Item{}
-			}
-			slotsEquipment[itemType] = append(slotsEquipment[itemType], item)
+		slots, ok := slotsByType[itemType]
+		if !ok {
+			slots = []string{itemType}
+		}
+		for _, slot := range slots {
+			slotsEquipment[slot] = append(slotsEquipment[slot], item)
 		}
 	}
 
-	basePlayerHp := 115 + 5*c.GetLevel("combat")
+	basePlayerHp := c.BaseHp()
 
 	set := NewEquipmentSet(nil)
-	for slot, itemCode := range c.Equipment {
-		if itemCode != "" {
-			set.Equipment[slot] = game.Items.Get(itemCode)
-		}
+	for slot, item := range equipped {
+		set.Equipment[slot] = item
 	}
 
 	for _, slot := range equipmentSlotOrder {
@@ -646,6 +840,13 @@ func (c *Character) GetBestOwnedEquipment(targetStats *game.Stats) *EquipmentSet
 		slotsEquipment[slot] = newItems
 	}
 
+	objective.keep(c.catalog, c.GetLevel("combat"), owned, equipped, slotsEquipment)
+
+	cacheKey := setCacheKey(c.GetLevel("combat"), objective, equipped, slotsEquipment, owned, targetStats)
+	if cached, ok := c.bestSets.get(cacheKey); ok {
+		return cached
+	}
+
 	var slots []string
 	for _, slot := range equipmentSlotOrder {
 		items := slotsEquipment[slot]
@@ -653,7 +854,7 @@ func (c *Character) GetBestOwnedEquipment(targetStats *game.Stats) *EquipmentSet
 			continue
 		}
 		if len(items) == 1 {
-			if items[0] != set.Equipment[slot] {
+			if items[0] != set.Equipment[slot] && !inOtherSlot(set.Equipment, slot, items[0]) {
 				set.Equipment[slot] = items[0]
 			}
 			continue
@@ -662,85 +863,139 @@ func (c *Character) GetBestOwnedEquipment(targetStats *game.Stats) *EquipmentSet
 		slots = append(slots, slot)
 	}
 
-	turnsToKillMonster, turnsToKillPlayer, haste := computeBestForRestOfSet(set, targetStats, basePlayerHp, slotsEquipment, slots)
-	set.TurnsToKillPlayer = turnsToKillPlayer
-	set.TurnsToKillMonster = turnsToKillMonster
-	set.Haste = haste
+	best := optimize(set, objective, targetStats, basePlayerHp, owned, slotsEquipment, slots)
+	set.Outcome = best.outcome
+	set.Haste = best.haste
+
+	for _, slot := range slotsByType["consumable"] {
+		if item := set.Equipment[slot]; item != nil {
+			set.Quantities[slot] = consumableQuantity(owned, item)
+		}
+	}
+
+	if !targetStats.IsResource {
+		// The search uses expected values, now get a real win probability for the set we picked
+		rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+		set.Outcome = combat.Estimate(fighter(set.Equipment, owned, basePlayerHp), combat.Fighter{Stats: targetStats}, fightSimulations, rng)
+	}
+
+	c.bestSets.put(cacheKey, set)
+
 	return set
 }
 
-func computeBestForRestOfSet(set *EquipmentSet, targetStats *game.Stats, basePlayerHp int, slotsEquipment map[string][]*game.Item, slots []string) (int, int, int8) {
-	fewestTurnsToKill, mostTurnsToDie, bestHaste := calculateTurns(set, targetStats, basePlayerHp)
+// ownedEquipment counts every item in the bank, inventory and equipment slots
+func (c *Character) ownedEquipment() map[*game.Item]int {
+	owned := map[*game.Item]int{}
+	for itemCode, quantity := range c.Bank() {
+		owned[c.catalog.Items.Get(itemCode)] += quantity
+	}
+	for itemCode, quantity := range c.inventory() {
+		owned[c.catalog.Items.Get(itemCode)] += quantity
+	}
+	equipment, equipmentQuantity := c.equipment()
+	for slot, item := range c.itemsInSlots(equipment) {
+		owned[item] += max(1, equipmentQuantity[slot])
+	}
+	delete(owned, nil)
+	return owned
+}
 
-	// Pick a slot. Pick an item. Put an item in the slot. Calculate all other slots
-	for _, slot := range slots {
-		items := slotsEquipment[slot]
-		if len(items) == 0 {
-			continue
+// Items counts what's in the character's inventory and equipment slots
+func (c *Character) Items() map[string]int {
+	items := c.inventory()
+	equipment, equipmentQuantity := c.equipment()
+	for slot, itemCode := range equipment {
+		if itemCode != "" {
+			items[itemCode] += max(1, equipmentQuantity[slot])
 		}
+	}
+	return items
+}
 
-		newSlots := slots[1:]
-		bestItem := set.Equipment[slot]
+// InventoryItems counts what's in the character's inventory, without equipment
+func (c *Character) InventoryItems() map[string]int {
+	return c.inventory()
+}
 
-		for _, item := range items {
-			set.Equipment[slot] = item
-
-			otherSlotsEquipment := slotsEquipment
-			if slot == "weapon" {
-				// Filter out other slots that don't contribute to this weapon's attack bonuses
-				otherSlotsEquipment = map[string][]*game.Item{}
-				for otherSlot, otherItems := range slotsEquipment {
-					if otherSlot == "weapon" {
-						continue
-					}
-					for _, otherItem := range otherItems {
-						if (item.Stats.AttackAir > 0 && (otherItem.Stats.AttackAir > 0 || otherItem.Stats.DamageAir > 0)) ||
-							(item.Stats.AttackEarth > 0 && (otherItem.Stats.AttackEarth > 0 || otherItem.Stats.DamageEarth > 0)) ||
-							(item.Stats.AttackFire > 0 && (otherItem.Stats.AttackFire > 0 || otherItem.Stats.DamageFire > 0)) ||
-							(item.Stats.AttackWater > 0 && (otherItem.Stats.AttackWater > 0 || otherItem.Stats.DamageWater > 0)) {
-							otherSlotsEquipment[otherSlot] = append(otherSlotsEquipment[otherSlot], otherItem)
-						}
-					}
-				}
-			}
+// inventory is a copy of the inventory that other goroutines can read while the character acts
+func (c *Character) inventory() map[string]int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return maps.Clone(c.Inventory)
+}
+
+// equipment is a copy of the equipment slots and consumable quantities that other goroutines can read while the character acts
+func (c *Character) equipment() (map[string]string, map[string]int) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
 
-			turnsToKill, turnsToDie, haste := computeBestForRestOfSet(set, targetStats, basePlayerHp, otherSlotsEquipment, newSlots)
+	return maps.Clone(c.Equipment), maps.Clone(c.EquipmentQuantity)
+}
 
-			if bestItem == nil ||
-				(turnsToKill < fewestTurnsToKill) ||
-				(turnsToKill == fewestTurnsToKill && turnsToDie > mostTurnsToDie) ||
-				(turnsToKill == fewestTurnsToKill && turnsToDie == mostTurnsToDie && haste > bestHaste) {
+func consumableQuantity(owned map[*game.Item]int, item *game.Item) int {
+	return min(owned[item], maxEquippedConsumables)
+}
 
-				fewestTurnsToKill = turnsToKill
-				mostTurnsToDie = turnsToDie
-				bestHaste = haste
-				bestItem = item
-			}
-		}
+// Fights are the character's most recent fights with their parsed logs
+func (c *Character) Fights() []combat.Record {
+	return c.fights.Records()
+}
 
-		set.Equipment[slot] = bestItem
-	}
+// EquippedItems maps each slot with something in it to the item
+func (c *Character) EquippedItems() map[string]*game.Item {
+	equipment, _ := c.equipment()
+	return c.itemsInSlots(equipment)
+}
 
-	return fewestTurnsToKill, mostTurnsToDie, bestHaste
+func (c *Character) itemsInSlots(equipment map[string]string) map[string]*game.Item {
+	equipped := map[string]*game.Item{}
+	for slot, itemCode := range equipment {
+		if itemCode != "" {
+			equipped[slot] = c.catalog.Items.Get(itemCode)
+		}
+	}
+	return equipped
 }
 
-func calculateTurns(set *EquipmentSet, targetStats *game.Stats, basePlayerHp int) (int, int, int8) {
-	equipmentStats := game.AccumulatedStats(set.Equipment)
+// BaseHp is the character's HP without any equipment
+func (c *Character) BaseHp() int {
+	return 115 + 5*c.GetLevel("combat")
+}
 
-	playerAttack := equipmentStats.GetDamageAgainst(targetStats)
-	monsterAttack := targetStats.GetDamageAgainst(equipmentStats)
+// Fighter is the character as the combat simulator sees them wearing what they have equipped right now
+func (c *Character) Fighter() combat.Fighter {
+	equipmentCodes, equipmentQuantity := c.equipment()
+	equipment := c.itemsInSlots(equipmentCodes)
 
-	playerHp := int(equipmentStats.Hp) + basePlayerHp
+	consumables := map[*game.Item]int{}
+	for _, slot := range slotsByType["consumable"] {
+		if item := equipment[slot]; item != nil {
+			consumables[item] += equipmentQuantity[slot]
+		}
+	}
+	return fighter(equipment, consumables, c.BaseHp())
+}
 
-	turnsToKillMonster := math.MaxUint8
-	if playerAttack > 0 {
-		turnsToKillMonster = int(targetStats.Hp) / playerAttack
+// fighter puts equipment stats together with the base HP. Consumable slots become food, as much as quantities allows.
+func fighter(equipment map[string]*game.Item, quantities map[*game.Item]int, basePlayerHp int) combat.Fighter {
+	gear := map[string]*game.Item{}
+	for slot, item := range equipment {
+		if !isConsumableSlot(slot) {
+			gear[slot] = item
+		}
 	}
 
-	turnsToKillPlayer := math.MaxUint8
-	if monsterAttack > 0 {
-		turnsToKillPlayer = playerHp / monsterAttack
+	stats := game.AccumulatedStats(gear)
+	stats.Hp += basePlayerHp
+
+	var consumables []combat.Consumable
+	for _, slot := range slotsByType["consumable"] {
+		if item := equipment[slot]; item != nil {
+			consumables = append(consumables, combat.Consumable{Item: item, Quantity: consumableQuantity(quantities, item)})
+		}
 	}
 
-	return turnsToKillMonster, turnsToKillPlayer, equipmentStats.Haste
+	return combat.Fighter{Stats: stats, Consumables: consumables}
 }
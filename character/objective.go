@@ -0,0 +1,223 @@
+package character
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"github.com/ahornerr/artifacts/combat"
+	"github.com/ahornerr/artifacts/game"
+	"math"
+	"slices"
+)
+
+const (
+	// ProfileFastest kills the target in the fewest turns, then keeps the most HP
+	ProfileFastest = "fastest"
+	// ProfileSafest ends the fight with the most HP left, then kills fastest
+	ProfileSafest = "safest"
+	// ProfileXpPerHour gets the most XP per second of cooldown, counting wisdom
+	ProfileXpPerHour = "xp_per_hour"
+	// ProfileGathering is for tools, fewest turns to gather then the most drops
+	ProfileGathering = "gathering"
+	// ProfileWeighted adds up Objective.Weights
+	ProfileWeighted = "weighted"
+)
+
+var ErrUnknownProfile = errors.New("unknown objective profile")
+
+// metric is something about a set where higher is better
+type metric func(s setScore) float64
+
+var profiles = map[string][]metric{
+	ProfileFastest:   {fewerTurns, hpLeft, haste},
+	ProfileSafest:    {hpLeft, fewerTurns, haste},
+	ProfileXpPerHour: {xpRate, hpLeft},
+	ProfileGathering: {fewerTurns, prospecting, wisdom},
+}
+
+// Objective is what GetBestOwnedEquipment optimizes for. The zero value is ProfileFastest with no constraints.
+// Winning always comes first, and sets that can't win are compared by how close they get.
+type Objective struct {
+	Profile string
+	Weights Weights
+
+	// Keep are item codes that have to be in the set, as long as the character owns them and is high enough level
+	Keep []string
+
+	// Exclude are item codes that can't come from the bank or inventory, like items reserved for another character.
+	// Anything already equipped can stay.
+	Exclude []string
+}
+
+// Weights for ProfileWeighted. Every term counts up for a better set, which is why none of them can be negative.
+type Weights struct {
+	Turns       float64
+	HpLeft      float64
+	Haste       float64
+	Wisdom      float64
+	Prospecting float64
+}
+
+// Profiles are the names Objective.Profile can be
+func Profiles() []string {
+	names := []string{ProfileWeighted}
+	for name := range profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (o Objective) Validate() error {
+	if o.Profile != "" && o.Profile != ProfileWeighted && profiles[o.Profile] == nil {
+		return fmt.Errorf("%w: %q", ErrUnknownProfile, o.Profile)
+	}
+	w := o.Weights
+	if w.Turns < 0 || w.HpLeft < 0 || w.Haste < 0 || w.Wisdom < 0 || w.Prospecting < 0 {
+		return fmt.Errorf("objective weights can't be negative: %+v", w)
+	}
+	return nil
+}
+
+func (o Objective) metrics() []metric {
+	switch o.Profile {
+	case "":
+		return profiles[ProfileFastest]
+	case ProfileWeighted:
+		return []metric{o.Weights.sum}
+	}
+	return profiles[o.Profile]
+}
+
+// betterThan is true when a is a better set than b for this objective
+func (o Objective) betterThan(a, b setScore) bool {
+	if a.outcome.WinProbability != b.outcome.WinProbability {
+		return a.outcome.WinProbability > b.outcome.WinProbability
+	}
+	if a.outcome.WinProbability == 0 {
+		if a.outcome.ExpectedMonsterHpLeft != b.outcome.ExpectedMonsterHpLeft {
+			return a.outcome.ExpectedMonsterHpLeft < b.outcome.ExpectedMonsterHpLeft
+		}
+		return a.haste > b.haste
+	}
+
+	for _, m := range o.metrics() {
+		if c := cmp.Compare(m(a), m(b)); c != 0 {
+			return c > 0
+		}
+	}
+	return false
+}
+
+// available takes excluded items out of what's owned, leaving whatever is equipped
+func (o Objective) available(owned map[*game.Item]int, equipped map[string]*game.Item, equippedQuantity map[string]int) map[*game.Item]int {
+	if len(o.Exclude) == 0 {
+		return owned
+	}
+
+	available := map[*game.Item]int{}
+	for item, quantity := range owned {
+		if !slices.Contains(o.Exclude, item.Code) {
+			available[item] = quantity
+		}
+	}
+	for slot, item := range equipped {
+		if slices.Contains(o.Exclude, item.Code) {
+			available[item] += max(1, equippedQuantity[slot])
+		}
+	}
+	return available
+}
+
+// keep narrows the candidates down to the kept items, using the slot a kept item is already in when there is one
+func (o Objective) keep(catalog *game.Catalog, level int, owned map[*game.Item]int, equipped map[string]*game.Item, slotsEquipment map[string][]*game.Item) {
+	pinned := map[string]bool{}
+
+	for _, itemCode := range o.Keep {
+		item := catalog.Items.Get(itemCode)
+		if item == nil || owned[item] == 0 || item.Level > level {
+			continue
+		}
+
+		slots, ok := slotsByType[item.Type]
+		if !ok {
+			slots = []string{item.Type}
+		}
+
+		slot := ""
+		for _, s := range slots {
+			if !pinned[s] && equipped[s] == item {
+				slot = s
+				break
+			}
+		}
+		if slot == "" {
+			for _, s := range slots {
+				if !pinned[s] && slices.Contains(equipmentSlotOrder, s) {
+					slot = s
+					break
+				}
+			}
+		}
+		if slot == "" {
+			continue
+		}
+
+		pinned[slot] = true
+		slotsEquipment[slot] = []*game.Item{item}
+
+		// Artifacts and consumables can only go in one slot
+		if item.Type != "ring" {
+			for _, other := range slots {
+				if !pinned[other] {
+					slotsEquipment[other] = slices.DeleteFunc(slices.Clone(slotsEquipment[other]), func(i *game.Item) bool {
+						return i == item
+					})
+				}
+			}
+		}
+	}
+}
+
+func fewerTurns(s setScore) float64 {
+	return -s.outcome.ExpectedTurns
+}
+
+func hpLeft(s setScore) float64 {
+	return s.outcome.ExpectedHpLeft
+}
+
+func haste(s setScore) float64 {
+	return float64(s.haste)
+}
+
+func wisdom(s setScore) float64 {
+	return float64(s.wisdom)
+}
+
+func prospecting(s setScore) float64 {
+	return float64(s.prospecting)
+}
+
+// xpRate is relative XP per second for the same target. Every 10 wisdom is 1% more XP.
+func xpRate(s setScore) float64 {
+	cooldown := combat.Cooldown(int(math.Round(s.outcome.ExpectedTurns)), s.haste)
+	return s.outcome.WinProbability * (1 + float64(s.wisdom)/1000) / cooldown.Seconds()
+}
+
+func (w Weights) sum(s setScore) float64 {
+	total := 0.0
+	// Zero weights are skipped so an unbounded HP left from the optimizer doesn't turn into NaN
+	for _, term := range []struct{ weight, value float64 }{
+		{w.Turns, fewerTurns(s)},
+		{w.HpLeft, hpLeft(s)},
+		{w.Haste, haste(s)},
+		{w.Wisdom, wisdom(s)},
+		{w.Prospecting, prospecting(s)},
+	} {
+		if term.weight != 0 {
+			total += term.weight * term.value
+		}
+	}
+	return total
+}
@@ -0,0 +1,351 @@
+package character
+
+import (
+	"fmt"
+	"github.com/ahornerr/artifacts/combat"
+	"github.com/ahornerr/artifacts/game"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// maxCachedSets is how many results the optimizer remembers before starting over
+const maxCachedSets = 256
+
+type setScore struct {
+	outcome     combat.Outcome
+	haste       int
+	wisdom      int
+	prospecting int
+}
+
+func scoreFighter(player combat.Fighter, targetStats *game.Stats) setScore {
+	score := setScore{
+		haste:       player.Stats.Haste,
+		wisdom:      player.Stats.Wisdom,
+		prospecting: player.Stats.Prospecting,
+	}
+
+	if !targetStats.IsResource {
+		score.outcome = combat.Expected(player, combat.Fighter{Stats: targetStats})
+		return score
+	}
+
+	// Gathering can't be lost, it just takes as many turns as it takes
+	score.outcome = combat.Outcome{ExpectedTurns: math.MaxUint8}
+	if attack := player.Stats.GetDamageAgainst(targetStats); attack > 0 {
+		score.outcome.WinProbability = 1
+		score.outcome.ExpectedTurns = float64(targetStats.Hp / attack)
+	}
+	return score
+}
+
+// optimizer is a depth-first branch and bound over the slots that have more than one candidate.
+// Every stat helps whoever has it, so a partial set plus the best value of every stat among the
+// candidates for the remaining slots can't do worse than any way of filling those slots. When even
+// that can't beat the best complete set so far, the branch is skipped.
+type optimizer struct {
+	objective    Objective
+	targetStats  *game.Stats
+	basePlayerHp int
+	owned        map[*game.Item]int
+	slots        []string
+
+	equipment map[string]*game.Item
+	best      setScore
+	bestSet   map[string]*game.Item
+}
+
+// optimize fills the slots of set with the best candidates. Slots not being optimized keep what's in them.
+func optimize(set *EquipmentSet, objective Objective, targetStats *game.Stats, basePlayerHp int, owned map[*game.Item]int, slotsEquipment map[string][]*game.Item, slots []string) setScore {
+	o := &optimizer{
+		objective:    objective,
+		targetStats:  targetStats,
+		basePlayerHp: basePlayerHp,
+		owned:        owned,
+		slots:        slots,
+		equipment:    set.Equipment,
+	}
+
+	// What we're already wearing is the set to beat
+	o.best = scoreFighter(fighter(set.Equipment, owned, basePlayerHp), targetStats)
+	o.bestSet = copyEquipment(set.Equipment)
+
+	// Food that restores HP makes HP left depend on when it gets eaten, so better stats
+	// don't always mean a better fight and nothing can be ruled out ahead of time.
+	restores := false
+	for _, item := range set.Equipment {
+		restores = restores || isRestore(item)
+	}
+	for _, items := range slotsEquipment {
+		restores = restores || slices.ContainsFunc(items, isRestore)
+	}
+
+	candidates := map[string][]*game.Item{}
+	for slot, items := range slotsEquipment {
+		if restores {
+			candidates[slot] = items
+		} else {
+			candidates[slot] = pruneDominated(slot, items, owned)
+		}
+	}
+
+	o.search(candidates, 0)
+
+	set.Equipment = o.bestSet
+	return o.best
+}
+
+func (o *optimizer) search(candidates map[string][]*game.Item, depth int) {
+	if depth == len(o.slots) {
+		score := scoreFighter(fighter(o.equipment, o.owned, o.basePlayerHp), o.targetStats)
+		if o.objective.betterThan(score, o.best) {
+			o.best = score
+			o.bestSet = copyEquipment(o.equipment)
+		}
+		return
+	}
+
+	if !o.objective.betterThan(o.bound(candidates, depth), o.best) {
+		return
+	}
+
+	slot := o.slots[depth]
+	previous, hadPrevious := o.equipment[slot]
+
+	placed := false
+	for _, item := range candidates[slot] {
+		if inOtherSlot(o.equipment, slot, item) {
+			continue
+		}
+		placed = true
+		o.equipment[slot] = item
+
+		next := candidates
+		if slot == "weapon" {
+			next = filterForWeapon(item, candidates)
+		}
+		o.search(next, depth+1)
+	}
+
+	if !placed {
+		// Nothing can go here, because the weapon ruled everything out or every candidate is already in a sibling
+		// slot, e.g. two artifacts for three slots. Whatever's equipped stays unless it's moved to a sibling.
+		if hadPrevious && inOtherSlot(o.equipment, slot, previous) {
+			delete(o.equipment, slot)
+		}
+		o.search(candidates, depth+1)
+	}
+
+	if hadPrevious {
+		o.equipment[slot] = previous
+	} else {
+		delete(o.equipment, slot)
+	}
+}
+
+// bound scores the slots chosen so far together with the best of everything that could go in the rest
+func (o *optimizer) bound(candidates map[string][]*game.Item, depth int) setScore {
+	remaining := o.slots[depth:]
+
+	chosen := map[string]*game.Item{}
+	for slot, item := range o.equipment {
+		if !slices.Contains(remaining, slot) {
+			chosen[slot] = item
+		}
+	}
+
+	player := fighter(chosen, o.owned, o.basePlayerHp)
+	stats := *player.Stats
+
+	for _, slot := range remaining {
+		// What's equipped can stay when none of the candidates can go in, so it counts toward the bound too
+		items := candidates[slot]
+		if equipped := o.equipment[slot]; equipped != nil && !slices.Contains(items, equipped) {
+			items = append(slices.Clip(items), equipped)
+		}
+		if len(items) == 0 {
+			continue
+		}
+
+		if isConsumableSlot(slot) {
+			for _, item := range items {
+				player.Consumables = append(player.Consumables, combat.Consumable{Item: item, Quantity: consumableQuantity(o.owned, item)})
+			}
+			continue
+		}
+
+		var best game.Stats
+		for _, item := range items {
+			if item.Stats != nil {
+				best.Max(item.Stats)
+			}
+		}
+		stats.Add(&best)
+	}
+
+	player.Stats = &stats
+	score := scoreFighter(player, o.targetStats)
+
+	// Lower HP means eating sooner, so a weaker set can end a fight with more HP left than this one.
+	// Only bound HP left when there's no food to restore with.
+	for _, consumable := range player.Consumables {
+		if isRestore(consumable.Item) {
+			score.outcome.ExpectedHpLeft = math.Inf(1)
+			break
+		}
+	}
+
+	return score
+}
+
+// filterForWeapon drops items from the other slots that don't contribute to this weapon's attack bonuses
+func filterForWeapon(weapon *game.Item, candidates map[string][]*game.Item) map[string][]*game.Item {
+	filtered := map[string][]*game.Item{}
+	for slot, items := range candidates {
+		if slot == "weapon" {
+			continue
+		}
+		if strings.HasPrefix(slot, "artifact") || isConsumableSlot(slot) {
+			// These help in other ways than attack
+			filtered[slot] = items
+			continue
+		}
+		for _, item := range items {
+			if (weapon.Stats.AttackAir > 0 && (item.Stats.AttackAir > 0 || item.Stats.DamageAir > 0)) ||
+				(weapon.Stats.AttackEarth > 0 && (item.Stats.AttackEarth > 0 || item.Stats.DamageEarth > 0)) ||
+				(weapon.Stats.AttackFire > 0 && (item.Stats.AttackFire > 0 || item.Stats.DamageFire > 0)) ||
+				(weapon.Stats.AttackWater > 0 && (item.Stats.AttackWater > 0 || item.Stats.DamageWater > 0)) {
+				filtered[slot] = append(filtered[slot], item)
+			}
+		}
+	}
+	return filtered
+}
+
+// pruneDominated drops items when enough others are at least as good on every stat that the item would never be picked.
+// Artifacts and consumables can't be doubled up, so one of those needs to be dominated once per slot of its type.
+func pruneDominated(slot string, items []*game.Item, owned map[*game.Item]int) []*game.Item {
+	var kept []*game.Item
+	for i, item := range items {
+		needed := 1
+		if item.Type != "ring" {
+			if slots, ok := slotsByType[item.Type]; ok {
+				needed = len(slots)
+			}
+		}
+
+		dominatedBy := 0
+		for j, other := range items {
+			if i == j || !dominates(other, item, owned) {
+				continue
+			}
+			// Identical items dominate each other, keep whichever comes first
+			if j > i && dominates(item, other, owned) {
+				continue
+			}
+			dominatedBy++
+		}
+
+		if dominatedBy < needed {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+func dominates(a, b *game.Item, owned map[*game.Item]int) bool {
+	if a.Stats == nil || b.Stats == nil {
+		return a.Stats != nil
+	}
+	if a.Type == "consumable" && consumableQuantity(owned, a) < consumableQuantity(owned, b) {
+		return false
+	}
+	return a.Stats.Dominates(b.Stats)
+}
+
+func isRestore(item *game.Item) bool {
+	return item != nil && item.Stats != nil && item.Stats.Restore > 0
+}
+
+func copyEquipment(equipment map[string]*game.Item) map[string]*game.Item {
+	c := map[string]*game.Item{}
+	for slot, item := range equipment {
+		c[slot] = item
+	}
+	return c
+}
+
+// setCache remembers the best set for the same candidates, equipment, level, target and objective
+type setCache struct {
+	sets map[string]*EquipmentSet
+	mux  sync.Mutex
+}
+
+func newSetCache() *setCache {
+	return &setCache{sets: map[string]*EquipmentSet{}}
+}
+
+func (c *setCache) get(key string) (*EquipmentSet, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	set, ok := c.sets[key]
+	if !ok {
+		return nil, false
+	}
+	return NewEquipmentSet(set), true
+}
+
+func (c *setCache) put(key string, set *EquipmentSet) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if len(c.sets) >= maxCachedSets {
+		c.sets = map[string]*EquipmentSet{}
+	}
+	c.sets[key] = NewEquipmentSet(set)
+}
+
+// setCacheKey covers everything the result depends on. Only consumable quantities matter, one of anything else is enough.
+func setCacheKey(level int, objective Objective, equipped map[string]*game.Item, slotsEquipment map[string][]*game.Item, owned map[*game.Item]int, targetStats *game.Stats) string {
+	// Keep and Exclude are already applied to the candidates, only how sets are compared is left
+	w := objective.Weights
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d|%s %g %g %g %g %g|", level, objective.Profile, w.Turns, w.HpLeft, w.Haste, w.Wisdom, w.Prospecting)
+	writeStatsKey(&b, targetStats)
+	b.WriteString("|")
+
+	for _, slot := range equipmentSlotOrder {
+		if item := equipped[slot]; item != nil {
+			fmt.Fprintf(&b, "%s=%s,", slot, item.Code)
+		}
+	}
+	b.WriteString("|")
+
+	for _, slot := range equipmentSlotOrder {
+		fmt.Fprintf(&b, "%s:", slot)
+		for _, item := range slotsEquipment[slot] {
+			b.WriteString(item.Code)
+			if item.Type == "consumable" {
+				fmt.Fprintf(&b, "*%d", consumableQuantity(owned, item))
+			}
+			b.WriteString(",")
+		}
+	}
+
+	return b.String()
+}
+
+func writeStatsKey(b *strings.Builder, s *game.Stats) {
+	fmt.Fprintf(b, "%t,%d,%d,%d,%d,", s.IsResource, s.Hp, s.Restore, s.Haste, s.BoostHp)
+	fmt.Fprintf(b, "%d,%d,%d,%d,", s.AttackFire, s.AttackWater, s.AttackEarth, s.AttackAir)
+	fmt.Fprintf(b, "%d,%d,%d,%d,", s.AttackWoodcutting, s.AttackMining, s.AttackFishing, s.AttackAlchemy)
+	fmt.Fprintf(b, "%d,%d,%d,%d,", s.ResistFire, s.ResistWater, s.ResistEarth, s.ResistAir)
+	fmt.Fprintf(b, "%d,%d,%d,%d,", s.ResistWoodcutting, s.ResistMining, s.ResistFishing, s.ResistAlchemy)
+	fmt.Fprintf(b, "%d,%d,%d,%d,", s.DamageFire, s.DamageWater, s.DamageEarth, s.DamageAir)
+	fmt.Fprintf(b, "%d,%d,%d,%d,", s.BoostDamageFire, s.BoostDamageWater, s.BoostDamageEarth, s.BoostDamageAir)
+	fmt.Fprintf(b, "%d,%d,%d,%d,", s.BoostResistFire, s.BoostResistWater, s.BoostResistEarth, s.BoostResistAir)
+	fmt.Fprintf(b, "%d,%d,%d,%d", s.CriticalStrike, s.Wisdom, s.Prospecting, s.InventorySpace)
+}
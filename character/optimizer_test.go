@@ -0,0 +1,257 @@
+package character
+
+import (
+	"fmt"
+	"github.com/ahornerr/artifacts/game"
+	"github.com/promiseofcake/artifactsmmo-go-client/client"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func testItem(code, itemType string, level int, effects map[string]int) client.ItemSchema {
+	var schemaEffects []client.ItemEffectSchema
+	for name, value := range effects {
+		schemaEffects = append(schemaEffects, client.ItemEffectSchema{Name: name, Value: value})
+	}
+	return client.ItemSchema{Code: code, Name: code, Type: itemType, Level: level, Effects: &schemaEffects}
+}
+
+// testCatalog is a fixed bank of gear with a few of everything, enough that exhaustive search takes a while
+func testCatalog(t testing.TB) *game.Catalog {
+	t.Helper()
+
+	catalog, err := game.NewCatalog(game.Data{Items: []client.ItemSchema{
+		testItem("fire_staff", "weapon", 20, map[string]int{"attack_fire": 28}),
+		testItem("earth_hammer", "weapon", 20, map[string]int{"attack_earth": 30}),
+		testItem("water_bow", "weapon", 18, map[string]int{"attack_water": 18, "attack_air": 10}),
+		testItem("fire_helmet", "helmet", 20, map[string]int{"hp": 40, "dmg_fire": 12}),
+		testItem("earth_helmet", "helmet", 19, map[string]int{"hp": 30, "dmg_earth": 15}),
+		testItem("tank_helmet", "helmet", 20, map[string]int{"hp": 80, "res_water": 10}),
+		testItem("fire_amulet", "amulet", 20, map[string]int{"dmg_fire": 10, "wisdom": 10}),
+		testItem("earth_amulet", "amulet", 20, map[string]int{"dmg_earth": 12}),
+		testItem("fire_plate", "body_armor", 20, map[string]int{"hp": 60, "dmg_fire": 15}),
+		testItem("earth_plate", "body_armor", 20, map[string]int{"hp": 50, "dmg_earth": 10, "res_water": 5}),
+		testItem("plain_plate", "body_armor", 18, map[string]int{"hp": 40}),
+		testItem("water_shield", "shield", 20, map[string]int{"res_water": 15}),
+		testItem("tower_shield", "shield", 20, map[string]int{"res_water": 8, "hp": 40}),
+		testItem("fire_ring", "ring", 20, map[string]int{"dmg_fire": 8}),
+		testItem("earth_ring", "ring", 20, map[string]int{"dmg_earth": 8}),
+		testItem("water_ring", "ring", 18, map[string]int{"dmg_water": 8, "dmg_air": 4}),
+		testItem("hp_ring", "ring", 20, map[string]int{"hp": 30}),
+		testItem("fire_legs", "leg_armor", 20, map[string]int{"hp": 40, "dmg_fire": 8}),
+		testItem("earth_legs", "leg_armor", 20, map[string]int{"hp": 40, "dmg_earth": 10}),
+		testItem("fast_boots", "boots", 20, map[string]int{"hp": 20, "haste": 5, "dmg_fire": 2}),
+		testItem("earth_boots", "boots", 20, map[string]int{"hp": 30, "dmg_earth": 5}),
+		testItem("fire_totem", "artifact", 20, map[string]int{"dmg_fire": 5}),
+		testItem("earth_totem", "artifact", 20, map[string]int{"dmg_earth": 5}),
+		testItem("wise_totem", "artifact", 20, map[string]int{"wisdom": 25}),
+		testItem("lucky_totem", "artifact", 20, map[string]int{"prospecting": 25}),
+		testItem("fire_soup", "consumable", 20, map[string]int{"boost_dmg_fire": 10}),
+		testItem("earth_soup", "consumable", 20, map[string]int{"boost_dmg_earth": 10}),
+		testItem("water_soup", "consumable", 20, map[string]int{"boost_res_water": 10}),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return catalog
+}
+
+// largeTestCatalog is every element at a few levels for every slot, closer to the real game than testCatalog
+// and far too big to search exhaustively
+func largeTestCatalog(t testing.TB) *game.Catalog {
+	t.Helper()
+
+	var items []client.ItemSchema
+	for _, element := range []string{"fire", "earth", "water", "air"} {
+		for level := 5; level <= 30; level += 5 {
+			suffix := fmt.Sprintf("%s_%d", element, level)
+			items = append(items,
+				testItem("weapon_"+suffix, "weapon", level, map[string]int{"attack_" + element: level + 5}),
+				testItem("helmet_"+suffix, "helmet", level, map[string]int{"hp": level * 2, "dmg_" + element: level / 2}),
+				testItem("amulet_"+suffix, "amulet", level, map[string]int{"dmg_" + element: level / 2, "wisdom": level / 5}),
+				testItem("plate_"+suffix, "body_armor", level, map[string]int{"hp": level * 3, "dmg_" + element: level / 3}),
+				testItem("shield_"+suffix, "shield", level, map[string]int{"res_" + element: level / 2, "hp": level}),
+				testItem("ring_"+suffix, "ring", level, map[string]int{"dmg_" + element: level / 3}),
+				testItem("legs_"+suffix, "leg_armor", level, map[string]int{"hp": level * 2, "dmg_" + element: level / 4}),
+				testItem("boots_"+suffix, "boots", level, map[string]int{"hp": level, "dmg_" + element: level / 5}),
+				testItem("totem_"+suffix, "artifact", level, map[string]int{"dmg_" + element: level / 5, "prospecting": level / 2}),
+				testItem("soup_"+suffix, "consumable", level, map[string]int{"boost_dmg_" + element: level / 2}),
+			)
+		}
+	}
+
+	catalog, err := game.NewCatalog(game.Data{Items: items})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return catalog
+}
+
+func testTarget() *game.Stats {
+	return &game.Stats{Hp: 900, AttackWater: 40, ResistFire: 10, ResistEarth: 20, CriticalStrike: 5}
+}
+
+// testSearch sets up what GetBestOwnedEquipment hands the optimizer: candidates per slot, owned quantities
+// and the slots with more than one candidate
+func testSearch(catalog *game.Catalog, equipped map[string]string) (*EquipmentSet, map[*game.Item]int, map[string][]*game.Item, []string) {
+	set := NewEquipmentSet(nil)
+	for slot, itemCode := range equipped {
+		set.Equipment[slot] = catalog.Items.Get(itemCode)
+	}
+
+	owned := map[*game.Item]int{}
+	slotsEquipment := map[string][]*game.Item{}
+	for _, item := range catalog.Items.GetAll() {
+		owned[item] = 10
+		slots, ok := slotsByType[item.Type]
+		if !ok {
+			slots = []string{item.Type}
+		}
+		for _, slot := range slots {
+			slotsEquipment[slot] = append(slotsEquipment[slot], item)
+		}
+	}
+
+	var slots []string
+	for _, slot := range equipmentSlotOrder {
+		items := slotsEquipment[slot]
+		slices.SortFunc(items, func(a, b *game.Item) int { return strings.Compare(a.Code, b.Code) })
+		if len(items) > 1 {
+			slots = append(slots, slot)
+		}
+	}
+
+	return set, owned, slotsEquipment, slots
+}
+
+// exhaustive is the search without any bounds or dominance pruning: every item in every slot, the weapon
+// filter the optimizer has always had, and whatever's equipped staying put when nothing else can go in
+func exhaustive(equipment map[string]*game.Item, objective Objective, targetStats *game.Stats, basePlayerHp int, owned map[*game.Item]int, candidates map[string][]*game.Item, slots []string) setScore {
+	if len(slots) == 0 {
+		return scoreFighter(fighter(equipment, owned, basePlayerHp), targetStats)
+	}
+
+	slot := slots[0]
+	previous, hadPrevious := equipment[slot]
+	defer func() {
+		if hadPrevious {
+			equipment[slot] = previous
+		} else {
+			delete(equipment, slot)
+		}
+	}()
+
+	var best *setScore
+	for _, item := range candidates[slot] {
+		if inOtherSlot(equipment, slot, item) {
+			continue
+		}
+		equipment[slot] = item
+
+		next := candidates
+		if slot == "weapon" {
+			next = filterForWeapon(item, candidates)
+		}
+		score := exhaustive(equipment, objective, targetStats, basePlayerHp, owned, next, slots[1:])
+		if best == nil || objective.betterThan(score, *best) {
+			best = &score
+		}
+	}
+
+	if best == nil {
+		if hadPrevious && inOtherSlot(equipment, slot, previous) {
+			delete(equipment, slot)
+		}
+		score := exhaustive(equipment, objective, targetStats, basePlayerHp, owned, candidates, slots[1:])
+		best = &score
+	}
+
+	return *best
+}
+
+func TestOptimizeMatchesExhaustiveSearch(t *testing.T) {
+	catalog := testCatalog(t)
+
+	equippedSets := map[string]map[string]string{
+		"nothing equipped": {},
+		"water gear equipped": {
+			"weapon":    "water_bow",
+			"ring1":     "water_ring",
+			"artifact1": "lucky_totem",
+			"artifact2": "wise_totem",
+		},
+	}
+
+	for name, equipped := range equippedSets {
+		for _, profile := range []string{ProfileFastest, ProfileSafest, ProfileXpPerHour} {
+			t.Run(fmt.Sprintf("%s/%s", name, profile), func(t *testing.T) {
+				objective := Objective{Profile: profile}
+				set, owned, slotsEquipment, slots := testSearch(catalog, equipped)
+				baseHp := 215
+
+				// The exhaustive search starts from what's equipped too, and it has to be at least as good
+				want := exhaustive(copyEquipment(set.Equipment), objective, testTarget(), baseHp, owned, slotsEquipment, slots)
+				current := scoreFighter(fighter(set.Equipment, owned, baseHp), testTarget())
+				if objective.betterThan(current, want) {
+					want = current
+				}
+
+				got := optimize(set, objective, testTarget(), baseHp, owned, slotsEquipment, slots)
+
+				if objective.betterThan(want, got) || objective.betterThan(got, want) {
+					t.Errorf("optimize found %+v, exhaustive search found %+v", got, want)
+				}
+				if rescored := scoreFighter(fighter(set.Equipment, owned, baseHp), testTarget()); rescored != got {
+					t.Errorf("the set optimize picked scores %+v, not the %+v it reported", rescored, got)
+				}
+			})
+		}
+	}
+}
+
+// A slot the weapon rules everything out of keeps what's in it
+func TestOptimizeKeepsEquippedWhenNothingFits(t *testing.T) {
+	catalog := testCatalog(t)
+	set, owned, slotsEquipment, slots := testSearch(catalog, map[string]string{"helmet": "tank_helmet"})
+
+	// Only fire weapons and helmets without fire damage, so the weapon filter leaves no helmets
+	slotsEquipment["weapon"] = []*game.Item{catalog.Items.Get("fire_staff"), catalog.Items.Get("earth_hammer")}
+	slotsEquipment["helmet"] = []*game.Item{catalog.Items.Get("earth_helmet"), catalog.Items.Get("tank_helmet")}
+	target := &game.Stats{Hp: 900, AttackWater: 40, ResistEarth: 90}
+
+	optimize(set, Objective{}, target, 215, owned, slotsEquipment, slots)
+
+	if set.Equipment["weapon"] != catalog.Items.Get("fire_staff") {
+		t.Fatalf("picked %v, want the fire staff", set.Equipment["weapon"])
+	}
+	if set.Equipment["helmet"] != catalog.Items.Get("tank_helmet") {
+		t.Errorf("helmet is %v, want the tank helmet that was already equipped", set.Equipment["helmet"])
+	}
+}
+
+func BenchmarkOptimize(b *testing.B) {
+	catalogs := map[string]func(testing.TB) *game.Catalog{"small": testCatalog, "large": largeTestCatalog}
+	for name, newCatalog := range catalogs {
+		b.Run(name, func(b *testing.B) {
+			catalog := newCatalog(b)
+			objective := Objective{Profile: ProfileFastest}
+
+			for i := 0; i < b.N; i++ {
+				set, owned, slotsEquipment, slots := testSearch(catalog, nil)
+				optimize(set, objective, testTarget(), 215, owned, slotsEquipment, slots)
+			}
+		})
+	}
+}
+
+// BenchmarkExhaustive is what the small catalog costs without the optimizer's pruning
+func BenchmarkExhaustive(b *testing.B) {
+	catalog := testCatalog(b)
+	objective := Objective{Profile: ProfileFastest}
+
+	for i := 0; i < b.N; i++ {
+		set, owned, slotsEquipment, slots := testSearch(catalog, nil)
+		exhaustive(set.Equipment, objective, testTarget(), 215, owned, slotsEquipment, slots)
+	}
+}
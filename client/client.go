@@ -7,9 +7,22 @@ import (
 	"github.com/promiseofcake/artifactsmmo-go-client/client"
 	"math"
 	"net/http"
+	"os"
 )
 
+const DefaultServer = "https://api.artifactsmmo.com"
+
+// New creates a client for the server in the ARTIFACTS_SERVER environment variable,
+// falling back to the real API when it isn't set.
 func New(token string) (*client.ClientWithResponses, error) {
+	server := os.Getenv("ARTIFACTS_SERVER")
+	if server == "" {
+		server = DefaultServer
+	}
+	return NewWithServer(server, token)
+}
+
+func NewWithServer(server, token string) (*client.ClientWithResponses, error) {
 	retryClient := retryablehttp.NewClient()
 	retryClient.RetryMax = math.MaxInt32 // Effectively infinite retries
 	retryClient.CheckRetry = func(ctx context.Context, resp *http.Response, err error) (bool, error) {
@@ -49,5 +62,5 @@ func New(token string) (*client.ClientWithResponses, error) {
 		}))
 	}
 
-	return client.NewClientWithResponses("https://api.artifactsmmo.com", opts...)
+	return client.NewClientWithResponses(server, opts...)
 }
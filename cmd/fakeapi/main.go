@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"github.com/ahornerr/artifacts/fakeapi"
+	"log"
+)
+
+// Serves a local stand-in for the ArtifactsMMO API.
+// Point the bot at it with ARTIFACTS_SERVER=http://localhost:9000
+func main() {
+	worldPath := flag.String("world", "", "path to the world JSON file, the built-in world if it's empty")
+	addr := flag.String("addr", ":9000", "address to listen on")
+	cooldownScale := flag.Float64("cooldown-scale", 1, "multiplier applied to every cooldown")
+	seed := flag.Int64("seed", 0, "random seed for drops and fights")
+	flag.Parse()
+
+	world, err := fakeapi.DefaultWorld()
+	if *worldPath != "" {
+		world, err = fakeapi.LoadWorld(*worldPath)
+	}
+	if err != nil {
+		log.Fatalf("loading world: %s", err)
+	}
+
+	server := fakeapi.New(world, fakeapi.Options{
+		CooldownScale: *cooldownScale,
+		Seed:          *seed,
+	})
+
+	log.Println("Listening on", *addr)
+	log.Fatal(server.ListenAndServe(*addr))
+}
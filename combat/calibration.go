@@ -0,0 +1,212 @@
+package combat
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record is a fight that actually happened along with the stats we thought both sides had
+type Record struct {
+	Time      time.Time
+	Character string
+	Monster   string
+
+	Player   Fighter
+	Opponent Fighter
+
+	Turns int
+	Log   FightLog
+}
+
+// History keeps the most recent fights
+type History struct {
+	records []Record
+	size    int
+	mux     sync.Mutex
+}
+
+func NewHistory(size int) *History {
+	return &History{size: size}
+}
+
+func (h *History) Add(record Record) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	h.records = append(h.records, record)
+	if len(h.records) > h.size {
+		h.records = h.records[len(h.records)-h.size:]
+	}
+}
+
+func (h *History) Records() []Record {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	return slices.Clone(h.records)
+}
+
+type Report struct {
+	Monsters []MonsterReport
+	Damage   []DamageReport
+
+	// UnparsedLines across all fights, if this isn't zero ParseLogs needs updating
+	UnparsedLines int
+}
+
+type MonsterReport struct {
+	Monster string
+	Fights  int
+	Wins    int
+
+	PredictedWinProbability float64
+	PredictedTurns          float64
+	ActualTurns             float64
+}
+
+// DamageReport compares the damage formula for a hit that isn't blocked (Stats.ElementHitAgainst) with real hits.
+// Fights are grouped by the predicted damage, so equipment changes show up as separate rows.
+type DamageReport struct {
+	Monster  string
+	Attacker string
+	Element  string
+
+	Predicted int
+	Hits      int
+
+	// Observed is the mean of unblocked, non-critical hits
+	Observed float64
+	Min      int
+	Max      int
+
+	Blocks             int
+	PredictedBlockRate float64
+	ObservedBlockRate  float64
+}
+
+type damageKey struct {
+	monster   string
+	attacker  string
+	element   string
+	predicted int
+}
+
+// Calibrate compares what the simulator predicted for each recorded fight with what happened
+func Calibrate(records []Record) Report {
+	var report Report
+
+	monsters := map[string]*MonsterReport{}
+	damage := map[damageKey]*DamageReport{}
+	observedTotal := map[damageKey]int{}
+	observedHits := map[damageKey]int{}
+
+	for _, record := range records {
+		report.UnparsedLines += len(record.Log.Unparsed)
+
+		monster, ok := monsters[record.Monster]
+		if !ok {
+			monster = &MonsterReport{Monster: record.Monster}
+			monsters[record.Monster] = monster
+		}
+
+		// Records from before the log was parsed for it have no starting HP, which would mean full HP
+		player := record.Player
+		if player.Hp == 0 {
+			player.Hp = record.Log.CharacterHp
+		}
+
+		expected := Expected(player, record.Opponent)
+		monster.Fights++
+		if record.Log.Win {
+			monster.Wins++
+		}
+		monster.PredictedWinProbability += expected.WinProbability
+		monster.PredictedTurns += expected.ExpectedTurns
+		monster.ActualTurns += float64(record.Turns)
+
+		for _, turn := range record.Log.Turns {
+			if turn.Element == "" {
+				continue
+			}
+
+			attacker, defender := player.stats(), record.Opponent.stats()
+			if turn.Attacker == "monster" {
+				attacker, defender = defender, attacker
+			}
+
+			key := damageKey{
+				monster:   record.Monster,
+				attacker:  turn.Attacker,
+				element:   turn.Element,
+				predicted: attacker.ElementHitAgainst(turn.Element, defender),
+			}
+
+			d, ok := damage[key]
+			if !ok {
+				d = &DamageReport{
+					Monster:            key.monster,
+					Attacker:           key.attacker,
+					Element:            key.element,
+					Predicted:          key.predicted,
+					Min:                -1,
+					PredictedBlockRate: defender.BlockChance(turn.Element),
+				}
+				damage[key] = d
+			}
+
+			d.Hits++
+			if turn.Blocked {
+				d.Blocks++
+				continue
+			}
+			if turn.Critical {
+				continue
+			}
+
+			observedTotal[key] += turn.Damage
+			observedHits[key]++
+			if d.Min < 0 || turn.Damage < d.Min {
+				d.Min = turn.Damage
+			}
+			d.Max = max(d.Max, turn.Damage)
+		}
+	}
+
+	for _, monster := range monsters {
+		n := float64(monster.Fights)
+		monster.PredictedWinProbability /= n
+		monster.PredictedTurns /= n
+		monster.ActualTurns /= n
+		report.Monsters = append(report.Monsters, *monster)
+	}
+
+	for key, d := range damage {
+		if observedHits[key] > 0 {
+			d.Observed = float64(observedTotal[key]) / float64(observedHits[key])
+		} else {
+			d.Min = 0
+		}
+		d.ObservedBlockRate = float64(d.Blocks) / float64(d.Hits)
+		report.Damage = append(report.Damage, *d)
+	}
+
+	slices.SortFunc(report.Monsters, func(a, b MonsterReport) int {
+		return strings.Compare(a.Monster, b.Monster)
+	})
+	slices.SortFunc(report.Damage, func(a, b DamageReport) int {
+		if c := strings.Compare(a.Monster, b.Monster); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.Attacker, b.Attacker); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.Element, b.Element); c != 0 {
+			return c
+		}
+		return a.Predicted - b.Predicted
+	})
+
+	return report
+}
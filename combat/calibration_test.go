@@ -0,0 +1,60 @@
+package combat
+
+import (
+	"github.com/ahornerr/artifacts/game"
+	"testing"
+)
+
+func TestCalibrate(t *testing.T) {
+	player := Fighter{Stats: &game.Stats{Hp: 120, AttackEarth: 10}}
+	monster := Fighter{Stats: &game.Stats{Hp: 60, AttackWater: 10}}
+
+	// Records from before Player.Hp was set have to take the starting HP from the log
+	report := Calibrate([]Record{
+		{Monster: "yellow_slime", Player: player, Opponent: monster, Turns: 11, Log: ParseLogs(wonFight)},
+		{Monster: "yellow_slime", Player: player, Opponent: monster, Turns: 6, Log: ParseLogs(lostFight)},
+	})
+
+	if report.UnparsedLines != 1 {
+		t.Errorf("%d unparsed lines, want 1", report.UnparsedLines)
+	}
+
+	if len(report.Monsters) != 1 {
+		t.Fatalf("monster reports = %+v, want one for yellow_slime", report.Monsters)
+	}
+	want := MonsterReport{
+		Monster: "yellow_slime",
+		Fights:  2,
+		Wins:    1,
+		// Starting on 30 HP loses, assuming full HP would have predicted two wins
+		PredictedWinProbability: 0.5,
+		PredictedTurns:          8.5,
+		ActualTurns:             8.5,
+	}
+	if got := report.Monsters[0]; got != want {
+		t.Errorf("monster report = %+v, want %+v", got, want)
+	}
+
+	wantDamage := []DamageReport{
+		{Monster: "yellow_slime", Attacker: "character", Element: "earth", Predicted: 10, Hits: 9, Observed: 10, Min: 10, Max: 10},
+		{Monster: "yellow_slime", Attacker: "monster", Element: "water", Predicted: 10, Hits: 8, Observed: 10, Min: 10, Max: 10, Blocks: 1, ObservedBlockRate: 0.125},
+	}
+	if len(report.Damage) != len(wantDamage) {
+		t.Fatalf("damage reports = %+v, want %+v", report.Damage, wantDamage)
+	}
+	for i, got := range report.Damage {
+		if got != wantDamage[i] {
+			t.Errorf("damage report = %+v, want %+v", got, wantDamage[i])
+		}
+	}
+}
+
+func TestCalibrateRecordedHp(t *testing.T) {
+	player := Fighter{Stats: &game.Stats{Hp: 120, AttackEarth: 10}, Hp: 30}
+	monster := Fighter{Stats: &game.Stats{Hp: 60, AttackWater: 10}}
+
+	report := Calibrate([]Record{{Monster: "yellow_slime", Player: player, Opponent: monster, Turns: 6, Log: ParseLogs(lostFight)}})
+	if p := report.Monsters[0].PredictedWinProbability; p != 0 {
+		t.Errorf("predicted win probability = %g starting on 30 HP, want 0", p)
+	}
+}
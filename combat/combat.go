@@ -0,0 +1,291 @@
+package combat
+
+import (
+	"fmt"
+	"github.com/ahornerr/artifacts/game"
+	"math"
+	"math/rand"
+	"slices"
+	"time"
+)
+
+// MaxTurns is when the API calls the fight a loss for the character
+const MaxTurns = 100
+
+const criticalMultiplier = 1.5
+
+type Fighter struct {
+	// Stats of the fighter with everything equipped. Hp is the fighter's full HP.
+	Stats *game.Stats
+
+	// Hp at the start of the fight. Zero means full HP.
+	Hp int
+
+	Consumables []Consumable
+}
+
+// Consumable is food in a consumable slot. Boosts are used once at the start of the fight,
+// restores are used at the start of a turn whenever the fighter is below half HP.
+type Consumable struct {
+	Item     *game.Item
+	Quantity int
+}
+
+func NewMonster(monster *game.Monster) Fighter {
+	return Fighter{Stats: monster.Stats}
+}
+
+type Result struct {
+	Win       bool
+	Turns     int
+	PlayerHp  int
+	MonsterHp int
+
+	// Logs are in the same form as FightSchema.Logs
+	Logs []string
+}
+
+// Outcome summarizes one or many fights from the character's point of view
+type Outcome struct {
+	WinProbability float64
+	ExpectedTurns  float64
+
+	// ExpectedHpLeft is the character's HP after the fight, counting losses as 0
+	ExpectedHpLeft float64
+
+	// ExpectedMonsterHpLeft is how far from winning the character got, useful to compare losing sets
+	ExpectedMonsterHpLeft float64
+
+	Cooldown time.Duration
+}
+
+// Cooldown is how long the character waits after a fight. Every turn takes 2 seconds, reduced by haste percent.
+func Cooldown(turns int, haste int) time.Duration {
+	seconds := float64(turns*2) * (1 - float64(haste)/100)
+	return time.Duration(max(seconds, 1) * float64(time.Second))
+}
+
+// Simulate one fight, rolling blocks and critical strikes with rng
+func Simulate(player, monster Fighter, rng *rand.Rand) Result {
+	f := newFight(player, monster, rng)
+	f.run()
+
+	return Result{
+		Win:       f.monster.hp <= 0,
+		Turns:     f.turns,
+		PlayerHp:  int(f.player.hp),
+		MonsterHp: int(f.monster.hp),
+		Logs:      f.logs,
+	}
+}
+
+// Estimate simulates the fight the given number of times
+func Estimate(player, monster Fighter, fights int, rng *rand.Rand) Outcome {
+	var outcome Outcome
+	if fights <= 0 {
+		return outcome
+	}
+
+	totalTurns := 0
+	for i := 0; i < fights; i++ {
+		result := Simulate(player, monster, rng)
+		if result.Win {
+			outcome.WinProbability++
+			outcome.ExpectedHpLeft += float64(result.PlayerHp)
+		}
+		outcome.ExpectedTurns += float64(result.Turns)
+		outcome.ExpectedMonsterHpLeft += float64(result.MonsterHp)
+		totalTurns += result.Turns
+	}
+
+	n := float64(fights)
+	outcome.WinProbability /= n
+	outcome.ExpectedTurns /= n
+	outcome.ExpectedHpLeft /= n
+	outcome.ExpectedMonsterHpLeft /= n
+	outcome.Cooldown = Cooldown(int(math.Round(float64(totalTurns)/n)), player.stats().Haste)
+
+	return outcome
+}
+
+// Expected runs a single fight where every hit deals its expected damage after blocks and critical strikes.
+// It's deterministic and much cheaper than Estimate, which makes it good for comparing lots of equipment sets,
+// but the win probability is always 0 or 1.
+func Expected(player, monster Fighter) Outcome {
+	f := newFight(player, monster, nil)
+	f.run()
+
+	outcome := Outcome{
+		ExpectedTurns:         float64(f.turns),
+		ExpectedMonsterHpLeft: max(0, f.monster.hp),
+		Cooldown:              Cooldown(f.turns, f.player.stats.Haste),
+	}
+	if f.monster.hp <= 0 {
+		outcome.WinProbability = 1
+		outcome.ExpectedHpLeft = f.player.hp
+	}
+	return outcome
+}
+
+func (f Fighter) stats() *game.Stats {
+	if f.Stats == nil {
+		return &game.Stats{}
+	}
+	return f.Stats
+}
+
+type combatant struct {
+	name        string
+	stats       *game.Stats
+	hp          float64
+	maxHp       float64
+	consumables []Consumable
+}
+
+func newCombatant(name string, f Fighter) *combatant {
+	stats := *f.stats()
+	consumables := slices.Clone(f.Consumables)
+
+	for i, consumable := range consumables {
+		boost := consumable.Item.Stats
+		if consumable.Quantity <= 0 || boost == nil || !boost.HasBoost() {
+			continue
+		}
+
+		stats.AddBoosts(boost)
+		consumables[i].Quantity--
+	}
+
+	maxHp := float64(stats.Hp + stats.BoostHp)
+	hp := maxHp
+	if f.Hp > 0 {
+		hp = min(float64(f.Hp), maxHp)
+	}
+
+	return &combatant{
+		name:        name,
+		stats:       &stats,
+		hp:          hp,
+		maxHp:       maxHp,
+		consumables: consumables,
+	}
+}
+
+type fight struct {
+	player  *combatant
+	monster *combatant
+	turns   int
+
+	// rng is nil when using expected values, in which case there are no logs either
+	rng  *rand.Rand
+	logs []string
+}
+
+func newFight(player, monster Fighter, rng *rand.Rand) *fight {
+	return &fight{
+		player:  newCombatant("character", player),
+		monster: newCombatant("monster", monster),
+		rng:     rng,
+	}
+}
+
+func (f *fight) logf(format string, args ...interface{}) {
+	if f.rng != nil {
+		f.logs = append(f.logs, fmt.Sprintf(format, args...))
+	}
+}
+
+func (f *fight) run() {
+	f.logf("Fight start: Character HP: %d/%d, Monster HP: %d/%d",
+		int(f.player.hp), int(f.player.maxHp), int(f.monster.hp), int(f.monster.maxHp))
+
+	// The character always goes first, then turns alternate
+	for f.turns < MaxTurns && f.player.hp > 0 && f.monster.hp > 0 {
+		f.turns++
+		attacker, defender := f.player, f.monster
+		if f.turns%2 == 0 {
+			attacker, defender = f.monster, f.player
+		}
+		f.turn(attacker, defender)
+	}
+
+	result := "lose"
+	if f.monster.hp <= 0 {
+		result = "win"
+	}
+	f.logf("Fight result: %s. (Character HP: %d/%d, Monster HP: %d/%d)",
+		result, int(f.player.hp), int(f.player.maxHp), int(f.monster.hp), int(f.monster.maxHp))
+}
+
+func (f *fight) turn(attacker, defender *combatant) {
+	f.restore(attacker)
+
+	critical := 1.0
+	chance := float64(attacker.stats.CriticalStrike) / 100
+	if f.rng == nil {
+		critical += chance * (criticalMultiplier - 1)
+	} else if f.rng.Float64() < chance {
+		critical = criticalMultiplier
+	}
+
+	for _, element := range game.Elements {
+		if defender.hp <= 0 {
+			return
+		}
+
+		// ElementDamageAgainst already averages in blocks, so it's only for expected fights. Random fights roll
+		// the block themselves and hit for the unblocked damage.
+		if f.rng == nil {
+			damage := float64(attacker.stats.ElementDamageAgainst(element, defender.stats))
+			defender.hp = max(0, defender.hp-damage*critical)
+			continue
+		}
+
+		damage := float64(attacker.stats.ElementHitAgainst(element, defender.stats))
+		if damage <= 0 {
+			continue
+		}
+
+		if f.rng.Float64() < defender.stats.BlockChance(element) {
+			f.logf("Turn %d: The %s blocked %s attack.", f.turns, defender.name, element)
+			continue
+		}
+
+		dealt := math.Round(damage * critical)
+		defender.hp = max(0, defender.hp-dealt)
+
+		crit := ""
+		if critical > 1 {
+			crit = " with a critical strike"
+		}
+		f.logf("Turn %d: The %s used %s attack and dealt %d damage%s. (%s HP: %d/%d)",
+			f.turns, attacker.name, element, int(dealt), crit, capitalize(defender.name), int(defender.hp), int(defender.maxHp))
+	}
+}
+
+// restore eats the first restoring consumable if the fighter is below half HP
+func (f *fight) restore(c *combatant) {
+	if c.hp >= c.maxHp/2 {
+		return
+	}
+
+	for i, consumable := range c.consumables {
+		stats := consumable.Item.Stats
+		if consumable.Quantity <= 0 || stats == nil || stats.Restore <= 0 {
+			continue
+		}
+
+		before := c.hp
+		c.hp = min(c.maxHp, c.hp+float64(stats.Restore))
+		c.consumables[i].Quantity--
+		f.logf("Turn %d: The %s used %s and restored %d HP.", f.turns, c.name, consumable.Item.Code, int(c.hp-before))
+		return
+	}
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return string(s[0]-'a'+'A') + s[1:]
+}
@@ -0,0 +1,140 @@
+package combat
+
+import (
+	"github.com/ahornerr/artifacts/game"
+	"math/rand"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fixedSource makes every roll the same, 0 to land every block and critical strike or 1<<62 (0.5) to miss them
+type fixedSource int64
+
+func (s fixedSource) Int63() int64 { return int64(s) }
+func (s fixedSource) Seed(int64)   {}
+
+var (
+	alwaysRoll = rand.New(fixedSource(0))
+	neverRoll  = rand.New(fixedSource(1 << 62))
+)
+
+func TestSimulateBlocks(t *testing.T) {
+	player := Fighter{Stats: &game.Stats{Hp: 50, AttackEarth: 10}}
+	// 10% resistance is a 1% block chance, every roll of 0 lands it
+	monster := Fighter{Stats: &game.Stats{Hp: 60, AttackWater: 10, ResistEarth: 10}}
+
+	result := Simulate(player, monster, alwaysRoll)
+	if result.Win || result.Turns != 10 || result.MonsterHp != 60 {
+		t.Errorf("got win %t after %d turns with the monster on %d HP, want every hit blocked and a loss after 10 turns",
+			result.Win, result.Turns, result.MonsterHp)
+	}
+
+	fightLog := ParseLogs(result.Logs)
+	want := Turn{Turn: 1, Attacker: "character", Element: "earth", Blocked: true}
+	if fightLog.Turns[0] != want {
+		t.Errorf("first turn = %+v, want %+v", fightLog.Turns[0], want)
+	}
+}
+
+func TestSimulateCriticals(t *testing.T) {
+	player := Fighter{Stats: &game.Stats{Hp: 50, AttackEarth: 10, CriticalStrike: 10}}
+	monster := Fighter{Stats: &game.Stats{Hp: 30, AttackWater: 10}}
+
+	// 15 damage a hit kills it in two
+	result := Simulate(player, monster, alwaysRoll)
+	if !result.Win || result.Turns != 3 {
+		t.Errorf("got win %t after %d turns, want a win after 3", result.Win, result.Turns)
+	}
+	if want := "Turn 1: The character used earth attack and dealt 15 damage with a critical strike. (Monster HP: 15/30)"; result.Logs[1] != want {
+		t.Errorf("first turn = %q, want %q", result.Logs[1], want)
+	}
+
+	result = Simulate(player, monster, neverRoll)
+	if !result.Win || result.Turns != 5 {
+		t.Errorf("got win %t after %d turns without criticals, want a win after 5", result.Win, result.Turns)
+	}
+}
+
+func TestSimulateFoodRestore(t *testing.T) {
+	chicken := &game.Item{Code: "cooked_chicken", Stats: &game.Stats{Restore: 30}}
+	// Starting below half HP the character eats on their first turn, and only has one to eat
+	player := Fighter{
+		Stats:       &game.Stats{Hp: 100, AttackEarth: 10},
+		Hp:          10,
+		Consumables: []Consumable{{Item: chicken, Quantity: 1}},
+	}
+	monster := Fighter{Stats: &game.Stats{Hp: 30}}
+
+	result := Simulate(player, monster, neverRoll)
+	if !result.Win || result.PlayerHp != 40 {
+		t.Errorf("got win %t on %d HP, want a win on 40", result.Win, result.PlayerHp)
+	}
+
+	restores := slices.DeleteFunc(slices.Clone(result.Logs), func(line string) bool {
+		return !strings.Contains(line, "restored")
+	})
+	if want := []string{"Turn 1: The character used cooked_chicken and restored 30 HP."}; !reflect.DeepEqual(restores, want) {
+		t.Errorf("restores = %q, want %q", restores, want)
+	}
+}
+
+func TestExpectedBoostDamage(t *testing.T) {
+	potion := &game.Item{Code: "earth_boost_potion", Stats: &game.Stats{BoostDamageEarth: 50}}
+	monster := Fighter{Stats: &game.Stats{Hp: 30}}
+
+	plain := Expected(Fighter{Stats: &game.Stats{Hp: 50, AttackEarth: 10}}, monster)
+	boosted := Expected(Fighter{
+		Stats:       &game.Stats{Hp: 50, AttackEarth: 10},
+		Consumables: []Consumable{{Item: potion, Quantity: 1}},
+	}, monster)
+
+	// 10 damage a hit takes three, 15 takes two
+	if plain.ExpectedTurns != 5 || boosted.ExpectedTurns != 3 {
+		t.Errorf("took %g turns without the potion and %g with it, want 5 and 3", plain.ExpectedTurns, boosted.ExpectedTurns)
+	}
+}
+
+func TestEstimateCloseFight(t *testing.T) {
+	// Without critical strikes the character wins by going first, with them it comes down to who lands more
+	player := Fighter{Stats: &game.Stats{Hp: 40, AttackEarth: 10, CriticalStrike: 50}}
+	monster := Fighter{Stats: &game.Stats{Hp: 40, AttackWater: 10, CriticalStrike: 50}}
+
+	outcome := Estimate(player, monster, 1000, rand.New(rand.NewSource(1)))
+	if outcome.WinProbability <= 0 || outcome.WinProbability >= 1 {
+		t.Errorf("win probability = %g, want somewhere between 0 and 1", outcome.WinProbability)
+	}
+
+	// Expected fights are all or nothing
+	if p := Expected(player, monster).WinProbability; p != 0 && p != 1 {
+		t.Errorf("expected win probability = %g, want 0 or 1", p)
+	}
+}
+
+func TestExpectedMatchesRecordedFight(t *testing.T) {
+	// lostFight had no blocks or critical strikes, so the expected fight goes exactly the same way
+	player := Fighter{Stats: &game.Stats{Hp: 120, AttackEarth: 10}, Hp: 30}
+	monster := Fighter{Stats: &game.Stats{Hp: 60, AttackWater: 10}}
+	recorded := ParseLogs(lostFight)
+
+	outcome := Expected(player, monster)
+	last := recorded.Turns[len(recorded.Turns)-1]
+	if outcome.ExpectedTurns != float64(last.Turn) {
+		t.Errorf("expected %g turns, the fight took %d", outcome.ExpectedTurns, last.Turn)
+	}
+	if won := outcome.WinProbability == 1; won != recorded.Win {
+		t.Errorf("expected a win %t, the fight was a win %t", won, recorded.Win)
+	}
+	if outcome.ExpectedMonsterHpLeft != 30 {
+		t.Errorf("expected the monster left on %g HP, the fight left it on 30", outcome.ExpectedMonsterHpLeft)
+	}
+
+	// Simulating without any lucky rolls writes the same log, less the line we don't understand
+	want := slices.DeleteFunc(slices.Clone(lostFight), func(line string) bool {
+		return slices.Contains(recorded.Unparsed, line)
+	})
+	if logs := Simulate(player, monster, neverRoll).Logs; !reflect.DeepEqual(logs, want) {
+		t.Errorf("simulated log:\n%s\nrecorded:\n%s", strings.Join(logs, "\n"), strings.Join(want, "\n"))
+	}
+}
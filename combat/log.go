@@ -0,0 +1,102 @@
+package combat
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Turn is one line of a fight log. A turn with two elements of attack is two Turns with the same number.
+type Turn struct {
+	Turn int
+
+	// Attacker is "character" or "monster"
+	Attacker string
+	Element  string
+	Damage   int
+	Blocked  bool
+	Critical bool
+
+	// Restored is set instead of an attack when the attacker ate food
+	Restored int
+
+	// HpLeft and MaxHp are the defender's after the attack, or the attacker's after restoring
+	HpLeft int
+	MaxHp  int
+}
+
+type FightLog struct {
+	CharacterHp    int
+	CharacterMaxHp int
+	MonsterHp      int
+	MonsterMaxHp   int
+
+	Turns []Turn
+	Win   bool
+
+	// Unparsed lines, which probably means the API changed the wording
+	Unparsed []string
+}
+
+var (
+	startRegexp   = regexp.MustCompile(`(?i)^Fight start: Character HP: (\d+)/(\d+), Monster HP: (\d+)/(\d+)$`)
+	attackRegexp  = regexp.MustCompile(`(?i)^Turn (\d+): The (character|monster) used (\w+) attack and dealt (\d+) damage( with a critical strike)?\. \((?:character|monster) HP: (\d+)/(\d+)\)$`)
+	blockRegexp   = regexp.MustCompile(`(?i)^Turn (\d+): The (character|monster) blocked (\w+) attack\.$`)
+	restoreRegexp = regexp.MustCompile(`(?i)^Turn (\d+): The (character|monster) used (\S+) and restored (\d+) HP\.$`)
+	resultRegexp  = regexp.MustCompile(`(?i)^Fight result: (win|lose)\. \(Character HP: (\d+)/(\d+), Monster HP: (\d+)/(\d+)\)$`)
+)
+
+// ParseLogs turns FightSchema.Logs into structured turns
+func ParseLogs(logs []string) FightLog {
+	var fightLog FightLog
+
+	for _, line := range logs {
+		line = strings.TrimSpace(line)
+
+		if m := startRegexp.FindStringSubmatch(line); m != nil {
+			fightLog.CharacterHp = atoi(m[1])
+			fightLog.CharacterMaxHp = atoi(m[2])
+			fightLog.MonsterHp = atoi(m[3])
+			fightLog.MonsterMaxHp = atoi(m[4])
+		} else if m := attackRegexp.FindStringSubmatch(line); m != nil {
+			fightLog.Turns = append(fightLog.Turns, Turn{
+				Turn:     atoi(m[1]),
+				Attacker: strings.ToLower(m[2]),
+				Element:  strings.ToLower(m[3]),
+				Damage:   atoi(m[4]),
+				Critical: m[5] != "",
+				HpLeft:   atoi(m[6]),
+				MaxHp:    atoi(m[7]),
+			})
+		} else if m := blockRegexp.FindStringSubmatch(line); m != nil {
+			// The log names the one who blocked, we want who attacked
+			attacker := "character"
+			if strings.EqualFold(m[2], "character") {
+				attacker = "monster"
+			}
+			fightLog.Turns = append(fightLog.Turns, Turn{
+				Turn:     atoi(m[1]),
+				Attacker: attacker,
+				Element:  strings.ToLower(m[3]),
+				Blocked:  true,
+			})
+		} else if m := restoreRegexp.FindStringSubmatch(line); m != nil {
+			fightLog.Turns = append(fightLog.Turns, Turn{
+				Turn:     atoi(m[1]),
+				Attacker: strings.ToLower(m[2]),
+				Restored: atoi(m[4]),
+			})
+		} else if m := resultRegexp.FindStringSubmatch(line); m != nil {
+			fightLog.Win = strings.EqualFold(m[1], "win")
+		} else if line != "" {
+			fightLog.Unparsed = append(fightLog.Unparsed, line)
+		}
+	}
+
+	return fightLog
+}
+
+func atoi(s string) int {
+	i, _ := strconv.Atoi(s)
+	return i
+}
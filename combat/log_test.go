@@ -0,0 +1,84 @@
+package combat
+
+import (
+	"reflect"
+	"testing"
+)
+
+// lostFight is FightSchema.Logs from a fight the character started hurt and lost
+var lostFight = []string{
+	"Fight start: Character HP: 30/120, Monster HP: 60/60",
+	"Turn 1: The character used earth attack and dealt 10 damage. (Monster HP: 50/60)",
+	"Turn 2: The monster used water attack and dealt 10 damage. (Character HP: 20/120)",
+	"Turn 3: The character used earth attack and dealt 10 damage. (Monster HP: 40/60)",
+	"Turn 4: The monster used water attack and dealt 10 damage. (Character HP: 10/120)",
+	"Turn 5: The character used earth attack and dealt 10 damage. (Monster HP: 30/60)",
+	"Turn 6: The monster is enraged.",
+	"Turn 6: The monster used water attack and dealt 10 damage. (Character HP: 0/120)",
+	"Fight result: lose. (Character HP: 0/120, Monster HP: 30/60)",
+}
+
+// wonFight is FightSchema.Logs from a fight at full HP with a critical strike and a block
+var wonFight = []string{
+	"Fight start: Character HP: 120/120, Monster HP: 60/60",
+	"Turn 1: The character used earth attack and dealt 10 damage. (Monster HP: 50/60)",
+	"Turn 2: The monster used water attack and dealt 10 damage. (Character HP: 110/120)",
+	"Turn 3: The character used earth attack and dealt 15 damage with a critical strike. (Monster HP: 35/60)",
+	"Turn 4: The character blocked water attack.",
+	"Turn 5: The character used earth attack and dealt 10 damage. (Monster HP: 25/60)",
+	"Turn 6: The monster used water attack and dealt 10 damage. (Character HP: 100/120)",
+	"Turn 7: The character used earth attack and dealt 10 damage. (Monster HP: 15/60)",
+	"Turn 8: The monster used water attack and dealt 10 damage. (Character HP: 90/120)",
+	"Turn 9: The character used earth attack and dealt 10 damage. (Monster HP: 5/60)",
+	"Turn 10: The monster used water attack and dealt 10 damage. (Character HP: 80/120)",
+	"Turn 11: The character used earth attack and dealt 10 damage. (Monster HP: 0/60)",
+	"Fight result: win. (Character HP: 80/120, Monster HP: 0/60)",
+}
+
+func TestParseLogs(t *testing.T) {
+	fightLog := ParseLogs(lostFight)
+
+	if fightLog.CharacterHp != 30 || fightLog.CharacterMaxHp != 120 || fightLog.MonsterHp != 60 || fightLog.MonsterMaxHp != 60 {
+		t.Errorf("start HP = %d/%d vs %d/%d, want 30/120 vs 60/60",
+			fightLog.CharacterHp, fightLog.CharacterMaxHp, fightLog.MonsterHp, fightLog.MonsterMaxHp)
+	}
+	if fightLog.Win {
+		t.Error("parsed a loss as a win")
+	}
+	if want := []string{"Turn 6: The monster is enraged."}; !reflect.DeepEqual(fightLog.Unparsed, want) {
+		t.Errorf("unparsed = %q, want %q", fightLog.Unparsed, want)
+	}
+	if len(fightLog.Turns) != 6 {
+		t.Fatalf("parsed %d turns, want 6", len(fightLog.Turns))
+	}
+	want := Turn{Turn: 6, Attacker: "monster", Element: "water", Damage: 10, HpLeft: 0, MaxHp: 120}
+	if turn := fightLog.Turns[5]; turn != want {
+		t.Errorf("last turn = %+v, want %+v", turn, want)
+	}
+
+	fightLog = ParseLogs(wonFight)
+	if !fightLog.Win {
+		t.Error("parsed a win as a loss")
+	}
+	if len(fightLog.Unparsed) != 0 {
+		t.Errorf("unparsed = %q", fightLog.Unparsed)
+	}
+	want = Turn{Turn: 3, Attacker: "character", Element: "earth", Damage: 15, Critical: true, HpLeft: 35, MaxHp: 60}
+	if turn := fightLog.Turns[2]; turn != want {
+		t.Errorf("critical strike = %+v, want %+v", turn, want)
+	}
+	// The log names who blocked, the turn is the monster's attack
+	want = Turn{Turn: 4, Attacker: "monster", Element: "water", Blocked: true}
+	if turn := fightLog.Turns[3]; turn != want {
+		t.Errorf("block = %+v, want %+v", turn, want)
+	}
+}
+
+func TestParseLogsRestore(t *testing.T) {
+	fightLog := ParseLogs([]string{"Turn 3: The character used cooked_chicken and restored 50 HP."})
+
+	want := []Turn{{Turn: 3, Attacker: "character", Restored: 50}}
+	if !reflect.DeepEqual(fightLog.Turns, want) {
+		t.Errorf("turns = %+v, want %+v", fightLog.Turns, want)
+	}
+}
@@ -0,0 +1,295 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ahornerr/artifacts/game"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	RoleCrafter   = "crafter"
+	RoleHarvester = "harvester"
+	RoleFighter   = "fighter"
+	RoleTasks     = "tasks"
+	RoleIdle      = "idle"
+)
+
+var roles = []string{RoleCrafter, RoleHarvester, RoleFighter, RoleTasks, RoleIdle}
+
+const (
+	DefaultListen   = ":8080"
+	DefaultTokenEnv = "ARTIFACTS_TOKEN"
+)
+
+// Config is the account, the characters and what each of them does. It's YAML, or JSON if the file ends in .json.
+type Config struct {
+	Account    Account     `yaml:"account" json:"account"`
+	Listen     string      `yaml:"listen" json:"listen"`
+	Characters []Character `yaml:"characters" json:"characters"`
+	Training   Training    `yaml:"training" json:"training"`
+
+	// Stockpile is how much of each item the crafter keeps around once everyone's geared up
+	Stockpile map[string]int `yaml:"stockpile" json:"stockpile"`
+}
+
+// Account says where the API token comes from, the first one that's set of Token, TokenFile and TokenEnv.
+// Server is the API to talk to, the real one if it's empty.
+type Account struct {
+	Token     string `yaml:"token" json:"token"`
+	TokenFile string `yaml:"token_file" json:"token_file"`
+	TokenEnv  string `yaml:"token_env" json:"token_env"`
+	Server    string `yaml:"server" json:"server"`
+}
+
+type Character struct {
+	Name   string `yaml:"name" json:"name"`
+	Role   string `yaml:"role" json:"role"`
+	Params Params `yaml:"params" json:"params"`
+
+	// Gold is how much gold the character carries, DefaultGold if it's left out
+	Gold *Gold `yaml:"gold,omitempty" json:"gold,omitempty"`
+}
+
+// Gold over Max goes to the bank, and a character with less than Min takes what the bank has to get back up to it
+type Gold struct {
+	Min int `yaml:"min" json:"min"`
+	Max int `yaml:"max" json:"max"`
+}
+
+// DefaultGold is enough to buy a few things without a trip to the bank
+var DefaultGold = Gold{Min: 0, Max: 1000}
+
+// Params are settings for a character's role. Roles only look at the ones that are theirs.
+type Params struct {
+	// Harvesters is how many characters a crafter splits gathering between, every harvester if it's 0
+	Harvesters int `yaml:"harvesters,omitempty" json:"harvesters,omitempty"`
+
+	// Monster is what a fighter fights
+	Monster string `yaml:"monster,omitempty" json:"monster,omitempty"`
+}
+
+// Training left out uses the bot's defaults
+type Training struct {
+	MaxLevel        int   `yaml:"max_level" json:"max_level"`
+	LevelMilestones []int `yaml:"level_milestones" json:"level_milestones"`
+}
+
+// Default is how the bot ran before it had a config file
+func Default() *Config {
+	c := &Config{
+		Characters: []Character{
+			{Name: "curlyBoy1", Role: RoleCrafter},
+			{Name: "curlyBoy2", Role: RoleHarvester},
+			{Name: "curlyBoy3", Role: RoleHarvester},
+			{Name: "curlyBoy4", Role: RoleHarvester},
+			{Name: "curlyBoy5", Role: RoleHarvester},
+		},
+	}
+	c.setDefaults()
+	return c
+}
+
+// Load reads and validates the config at path
+func Load(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Config{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(b))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(c)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(b))
+		decoder.KnownFields(true)
+		err = decoder.Decode(c)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading config from %s: %w", path, err)
+	}
+
+	c.setDefaults()
+	if err = c.Validate(); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+
+	return c, nil
+}
+
+func (c *Config) setDefaults() {
+	if c.Listen == "" {
+		c.Listen = DefaultListen
+	}
+	if c.Account.Token == "" && c.Account.TokenFile == "" && c.Account.TokenEnv == "" {
+		c.Account.TokenEnv = DefaultTokenEnv
+	}
+	for i := range c.Characters {
+		if c.Characters[i].Role == "" {
+			c.Characters[i].Role = RoleIdle
+		}
+		if c.Characters[i].Gold == nil {
+			gold := DefaultGold
+			c.Characters[i].Gold = &gold
+		}
+	}
+}
+
+// Validate finds everything wrong with the config, not just the first thing
+func (c *Config) Validate() error {
+	var errs []error
+
+	if len(c.Characters) == 0 {
+		errs = append(errs, errors.New("no characters"))
+	}
+	names := map[string]bool{}
+	for i, char := range c.Characters {
+		if char.Name == "" {
+			errs = append(errs, fmt.Errorf("character %d has no name", i+1))
+		} else if names[char.Name] {
+			errs = append(errs, fmt.Errorf("character %s is listed more than once", char.Name))
+		}
+		names[char.Name] = true
+
+		if !slices.Contains(roles, char.Role) {
+			errs = append(errs, fmt.Errorf("character %s has unknown role %q, want one of %s", char.Name, char.Role, strings.Join(roles, ", ")))
+		}
+		if char.Role == RoleFighter && char.Params.Monster == "" {
+			errs = append(errs, fmt.Errorf("fighter %s needs a monster", char.Name))
+		}
+		if char.Params.Harvesters < 0 {
+			errs = append(errs, fmt.Errorf("character %s can't have %d harvesters", char.Name, char.Params.Harvesters))
+		}
+		if char.Gold != nil && (char.Gold.Min < 0 || char.Gold.Max < char.Gold.Min) {
+			errs = append(errs, fmt.Errorf("character %s gold range %d-%d has to be positive and go up", char.Name, char.Gold.Min, char.Gold.Max))
+		}
+	}
+
+	if c.Training.MaxLevel < 0 {
+		errs = append(errs, fmt.Errorf("max level %d is too low", c.Training.MaxLevel))
+	}
+	for i, level := range c.Training.LevelMilestones {
+		if level < 1 || (i > 0 && level <= c.Training.LevelMilestones[i-1]) {
+			errs = append(errs, errors.New("level milestones have to be positive and go up"))
+			break
+		}
+	}
+
+	for itemCode, quantity := range c.Stockpile {
+		if quantity < 1 {
+			errs = append(errs, fmt.Errorf("stockpile of %s has to be at least 1", itemCode))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// CheckCatalog makes sure the monsters and items the config talks about exist
+func (c *Config) CheckCatalog(catalog *game.Catalog) error {
+	var errs []error
+	for _, char := range c.Characters {
+		if char.Params.Monster != "" && catalog.Monsters.Get(char.Params.Monster) == nil {
+			errs = append(errs, fmt.Errorf("character %s has unknown monster %q", char.Name, char.Params.Monster))
+		}
+	}
+	for itemCode := range c.Stockpile {
+		if catalog.Items.Get(itemCode) == nil {
+			errs = append(errs, fmt.Errorf("unknown stockpile item %q", itemCode))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ResolveToken gets the API token from wherever the account says it is
+func (a Account) ResolveToken() (string, error) {
+	switch {
+	case a.Token != "":
+		return a.Token, nil
+	case a.TokenFile != "":
+		b, err := os.ReadFile(a.TokenFile)
+		if err != nil {
+			return "", fmt.Errorf("reading token: %w", err)
+		}
+		return strings.TrimSpace(string(b)), nil
+	default:
+		token := os.Getenv(a.TokenEnv)
+		if token == "" {
+			return "", fmt.Errorf("no token in %s", a.TokenEnv)
+		}
+		return token, nil
+	}
+}
+
+func (c *Config) Character(name string) (Character, bool) {
+	for _, char := range c.Characters {
+		if char.Name == name {
+			return char, true
+		}
+	}
+	return Character{}, false
+}
+
+// Harvesters is how many characters are harvesters
+func (c *Config) Harvesters() int {
+	n := 0
+	for _, char := range c.Characters {
+		if char.Role == RoleHarvester {
+			n++
+		}
+	}
+	return n
+}
+
+// Watch checks the file at path every interval and calls onChange with the new config whenever it changes.
+// A config that doesn't load is logged and skipped so a typo doesn't take everything down.
+func Watch(ctx context.Context, path string, interval time.Duration, onChange func(*Config)) {
+	var lastMod time.Time
+	if info, err := os.Stat(path); err == nil {
+		lastMod = info.ModTime()
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			info, err := os.Stat(path)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			} else if err != nil {
+				log.Println("Error checking config:", err)
+				continue
+			}
+			if info.ModTime().Equal(lastMod) {
+				continue
+			}
+			lastMod = info.ModTime()
+
+			c, err := Load(path)
+			if err != nil {
+				log.Println("Not reloading config:", err)
+				continue
+			}
+			log.Println("Reloading config from", path)
+			onChange(c)
+		}
+	}()
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, name, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadExample(t *testing.T) {
+	c, err := Load("../config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.Characters) == 0 {
+		t.Fatal("no characters")
+	}
+	crafter, ok := c.Character("curlyBoy1")
+	if !ok || crafter.Role != RoleCrafter {
+		t.Errorf("curlyBoy1 is %+v, want a crafter", crafter)
+	}
+	if crafter.Gold == nil || crafter.Gold.Max <= crafter.Gold.Min {
+		t.Errorf("crafter gold is %+v, want the range from the example", crafter.Gold)
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `
+characters:
+  - name: a
+  - name: b
+    role: harvester
+`)
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Listen != DefaultListen {
+		t.Errorf("listen is %q, want %q", c.Listen, DefaultListen)
+	}
+	if c.Account.TokenEnv != DefaultTokenEnv {
+		t.Errorf("token env is %q, want %q", c.Account.TokenEnv, DefaultTokenEnv)
+	}
+	if c.Characters[0].Role != RoleIdle {
+		t.Errorf("a character without a role is %q, want idle", c.Characters[0].Role)
+	}
+	if gold := c.Characters[1].Gold; gold == nil || *gold != DefaultGold {
+		t.Errorf("gold is %+v, want %+v", gold, DefaultGold)
+	}
+	if c.Harvesters() != 1 {
+		t.Errorf("%d harvesters, want 1", c.Harvesters())
+	}
+}
+
+func TestLoadJSON(t *testing.T) {
+	path := writeConfig(t, "config.json", `{
+		"characters": [{"name": "a", "role": "fighter", "params": {"monster": "chicken"}, "gold": {"min": 10, "max": 20}}],
+		"stockpile": {"cooked_chicken": 50}
+	}`)
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	char := c.Characters[0]
+	if char.Role != RoleFighter || char.Params.Monster != "chicken" {
+		t.Errorf("character is %+v, want a chicken fighter", char)
+	}
+	if *char.Gold != (Gold{Min: 10, Max: 20}) {
+		t.Errorf("gold is %+v", *char.Gold)
+	}
+	if c.Stockpile["cooked_chicken"] != 50 {
+		t.Errorf("stockpile is %v", c.Stockpile)
+	}
+}
+
+func TestLoadUnknownField(t *testing.T) {
+	for name, contents := range map[string]string{
+		"config.yaml": "characters:\n  - name: a\n    rol: crafter\n",
+		"config.json": `{"characters": [{"name": "a", "rol": "crafter"}]}`,
+	} {
+		if _, err := Load(writeConfig(t, name, contents)); err == nil {
+			t.Errorf("%s with a misspelled field loaded", name)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	c := &Config{
+		Characters: []Character{
+			{Name: "a", Role: "painter"},
+			{Name: "a", Role: RoleFighter},
+			{Name: "", Role: RoleIdle},
+			{Name: "b", Role: RoleCrafter, Params: Params{Harvesters: -1}, Gold: &Gold{Min: 100, Max: 50}},
+		},
+		Training:  Training{MaxLevel: -1, LevelMilestones: []int{10, 5}},
+		Stockpile: map[string]int{"copper": 0},
+	}
+
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("no error")
+	}
+
+	// Every problem is reported, not just the first
+	for _, want := range []string{
+		`unknown role "painter"`,
+		"listed more than once",
+		"fighter a needs a monster",
+		"character 3 has no name",
+		"-1 harvesters",
+		"gold range 100-50",
+		"max level -1",
+		"level milestones",
+		"stockpile of copper",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't mention %q", err, want)
+		}
+	}
+
+	if err = Default().Validate(); err != nil {
+		t.Errorf("the default config isn't valid: %v", err)
+	}
+}
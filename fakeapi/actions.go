@@ -0,0 +1,726 @@
+package fakeapi
+
+import (
+	"fmt"
+	"github.com/ahornerr/artifacts/game"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	tasksCoinItemCode = "tasks_coin"
+
+	maxFightTurns = 100
+)
+
+func (s *Server) move(char *Character, r *http.Request) (map[string]interface{}, time.Duration, error) {
+	var body struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		return nil, 0, err
+	}
+
+	if char.X == body.X && char.Y == body.Y {
+		return nil, 0, errorf(490, "Character already at destination.")
+	}
+
+	destination, ok := s.tileAt(body.X, body.Y)
+	if !ok {
+		return nil, 0, errorf(404, "Map not found.")
+	}
+
+	distance := math.Abs(float64(char.X-body.X)) + math.Abs(float64(char.Y-body.Y))
+	char.X = body.X
+	char.Y = body.Y
+
+	return map[string]interface{}{
+		"destination": destination,
+	}, time.Duration(distance*5) * time.Second, nil
+}
+
+func (s *Server) fight(char *Character, _ *http.Request) (map[string]interface{}, time.Duration, error) {
+	content, err := s.requireContent(char, "monster")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	monster, ok := s.monsters[content.Code]
+	if !ok {
+		return nil, 0, errorf(598, "Monster not found on this map.")
+	}
+
+	if char.inventoryCount() >= char.InventoryMaxItems {
+		return nil, 0, errorf(497, "Character inventory is full.")
+	}
+
+	player := s.characterFighter(char)
+	enemy := monsterFighter(monster)
+
+	// Fights pick up from whatever HP the last one left the character with
+	playerHp := player.hp
+	if char.Hp > 0 && char.Hp < player.hp {
+		playerHp = char.Hp
+	}
+	monsterHp := enemy.hp
+	playerBlocked := map[string]int{}
+	monsterBlocked := map[string]int{}
+
+	logs := []string{
+		fmt.Sprintf("Fight start: Character HP: %d/%d, Monster HP: %d/%d", playerHp, player.hp, monsterHp, enemy.hp),
+	}
+
+	turn := 1
+	for ; turn <= maxFightTurns && playerHp > 0 && monsterHp > 0; turn++ {
+		characterTurn := turn%2 == 1
+		attacker, defender := player, enemy
+		defenderHp, defenderMaxHp := &monsterHp, enemy.hp
+		who, whom, blocked := "character", "Monster", monsterBlocked
+		if !characterTurn {
+			attacker, defender = enemy, player
+			defenderHp, defenderMaxHp = &playerHp, player.hp
+			who, whom, blocked = "monster", "Character", playerBlocked
+		}
+
+		for _, element := range elements {
+			attack := attacker.attack[element]
+			if attack <= 0 || *defenderHp <= 0 {
+				continue
+			}
+
+			resist := defender.resist[element]
+			if s.rng.Float64() < game.BlockChance(resist) {
+				blocked[element]++
+				logs = append(logs, fmt.Sprintf("Turn %d: The %s blocked %s attack.", turn, strings.ToLower(whom), element))
+				continue
+			}
+
+			damage := game.ElementHit(attack, attacker.damage[element], resist)
+			*defenderHp = max(0, *defenderHp-damage)
+
+			logs = append(logs, fmt.Sprintf("Turn %d: The %s used %s attack and dealt %d damage. (%s HP: %d/%d)",
+				turn, who, element, damage, whom, *defenderHp, defenderMaxHp))
+		}
+	}
+	turns := turn - 1
+
+	result := "lose"
+	if monsterHp == 0 {
+		result = "win"
+	}
+	logs = append(logs, fmt.Sprintf("Fight result: %s. (Character HP: %d/%d, Monster HP: %d/%d)",
+		result, playerHp, player.hp, monsterHp, enemy.hp))
+
+	xp := 0
+	gold := 0
+	drops := []SimpleItem{}
+	if result == "win" {
+		xp = monster.Level * 10
+		gold = s.randBetween(monster.MinGold, monster.MaxGold)
+		char.addXp("combat", xp)
+		char.Gold += gold
+
+		for _, drop := range s.rollDrops(monster.Drops) {
+			// Drops that don't fit are lost, same as the real thing
+			if char.addItem(drop.Code, drop.Quantity) == nil {
+				drops = append(drops, drop)
+			}
+		}
+
+		if char.TaskType == "monsters" && char.Task == monster.Code && char.TaskProgress < char.TaskTotal {
+			char.TaskProgress++
+		}
+		char.Hp = playerHp
+	} else {
+		// Losing sends you back to spawn with full HP
+		char.X = 0
+		char.Y = 0
+		char.Hp = player.hp
+	}
+
+	cooldown := float64(turns*2) * (1 - float64(player.haste)/100)
+
+	return map[string]interface{}{
+		"fight": map[string]interface{}{
+			"xp":                   xp,
+			"gold":                 gold,
+			"drops":                drops,
+			"turns":                turns,
+			"monster_blocked_hits": blockedHits(monsterBlocked),
+			"player_blocked_hits":  blockedHits(playerBlocked),
+			"logs":                 logs,
+			"result":               result,
+		},
+	}, time.Duration(max(cooldown, 1) * float64(time.Second)), nil
+}
+
+func blockedHits(blocked map[string]int) map[string]int {
+	hits := map[string]int{"total": 0}
+	for _, element := range elements {
+		hits[element] = blocked[element]
+		hits["total"] += blocked[element]
+	}
+	return hits
+}
+
+func (s *Server) gather(char *Character, _ *http.Request) (map[string]interface{}, time.Duration, error) {
+	content, err := s.requireContent(char, "resource")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resource, ok := s.resources[content.Code]
+	if !ok {
+		return nil, 0, errorf(598, "Resource not found on this map.")
+	}
+
+	level, _, _ := char.skill(resource.Skill)
+	if *level < resource.Level {
+		return nil, 0, errorf(493, "Not skill level required.")
+	}
+
+	if char.inventoryCount() >= char.InventoryMaxItems {
+		return nil, 0, errorf(497, "Character inventory is full.")
+	}
+
+	items := []SimpleItem{}
+	for _, drop := range s.rollDrops(resource.Drops) {
+		if char.addItem(drop.Code, drop.Quantity) == nil {
+			items = append(items, drop)
+		}
+	}
+
+	xp := 0
+	// No XP once you're 10 levels above the resource
+	if *level-resource.Level < 10 {
+		xp = 10 + resource.Level*2
+		char.addXp(resource.Skill, xp)
+	}
+
+	cooldown := 30.0 * (1 - float64(s.gatheringBonus(char, resource.Skill))/100)
+
+	return map[string]interface{}{
+		"details": map[string]interface{}{
+			"xp":    xp,
+			"items": items,
+		},
+	}, time.Duration(cooldown * float64(time.Second)), nil
+}
+
+func (s *Server) craft(char *Character, r *http.Request) (map[string]interface{}, time.Duration, error) {
+	var body struct {
+		Code     string `json:"code"`
+		Quantity int    `json:"quantity"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		return nil, 0, err
+	}
+	if body.Quantity <= 0 {
+		body.Quantity = 1
+	}
+
+	item, ok := s.items[body.Code]
+	if !ok {
+		return nil, 0, errorf(404, "Craft not found.")
+	}
+	if item.Craft == nil {
+		return nil, 0, errorf(404, "Craft not found.")
+	}
+
+	content, err := s.requireContent(char, "workshop")
+	if err != nil {
+		return nil, 0, err
+	}
+	if content.Code != item.Craft.Skill {
+		return nil, 0, errorf(598, "Workshop not found on this map.")
+	}
+
+	level, _, _ := char.skill(item.Craft.Skill)
+	if *level < item.Craft.Level {
+		return nil, 0, errorf(493, "Not skill level required.")
+	}
+
+	materials := 0
+	for _, material := range item.Craft.Items {
+		if char.quantity(material.Code) < material.Quantity*body.Quantity {
+			return nil, 0, errorf(478, "Missing item or insufficient quantity.")
+		}
+		materials += material.Quantity * body.Quantity
+	}
+
+	produced := max(item.Craft.Quantity, 1) * body.Quantity
+	if char.inventoryCount()-materials+produced > char.InventoryMaxItems {
+		return nil, 0, errorf(497, "Character inventory is full.")
+	}
+
+	for _, material := range item.Craft.Items {
+		_ = char.removeItem(material.Code, material.Quantity*body.Quantity)
+	}
+	_ = char.addItem(item.Code, produced)
+
+	xp := 0
+	if *level-item.Craft.Level < 10 {
+		xp = (10 + item.Craft.Level*5) * body.Quantity
+		char.addXp(item.Craft.Skill, xp)
+	}
+
+	return map[string]interface{}{
+		"details": map[string]interface{}{
+			"xp":    xp,
+			"items": []SimpleItem{{Code: item.Code, Quantity: produced}},
+		},
+	}, time.Duration(5*body.Quantity) * time.Second, nil
+}
+
+func (s *Server) decodeItemQuantity(r *http.Request) (*Item, int, error) {
+	var body struct {
+		Code     string `json:"code"`
+		Quantity int    `json:"quantity"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		return nil, 0, err
+	}
+	if body.Quantity <= 0 {
+		return nil, 0, errorf(422, "Quantity must be positive.")
+	}
+
+	item, ok := s.items[body.Code]
+	if !ok {
+		return nil, 0, errorf(404, "Item not found.")
+	}
+
+	return item, body.Quantity, nil
+}
+
+func (s *Server) deposit(char *Character, r *http.Request) (map[string]interface{}, time.Duration, error) {
+	item, quantity, err := s.decodeItemQuantity(r)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if _, err = s.requireContent(char, "bank"); err != nil {
+		return nil, 0, err
+	}
+
+	if err = char.removeItem(item.Code, quantity); err != nil {
+		return nil, 0, err
+	}
+	s.world.Bank[item.Code] += quantity
+
+	return map[string]interface{}{
+		"item": item,
+		"bank": s.bankItems(),
+	}, 3 * time.Second, nil
+}
+
+func (s *Server) withdraw(char *Character, r *http.Request) (map[string]interface{}, time.Duration, error) {
+	item, quantity, err := s.decodeItemQuantity(r)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if _, err = s.requireContent(char, "bank"); err != nil {
+		return nil, 0, err
+	}
+
+	have := s.world.Bank[item.Code]
+	if have == 0 {
+		return nil, 0, errorf(404, "Item not found.")
+	}
+	if have < quantity {
+		return nil, 0, errorf(478, "Missing item or insufficient quantity.")
+	}
+
+	if err = char.addItem(item.Code, quantity); err != nil {
+		return nil, 0, err
+	}
+	s.world.Bank[item.Code] -= quantity
+	if s.world.Bank[item.Code] == 0 {
+		delete(s.world.Bank, item.Code)
+	}
+
+	return map[string]interface{}{
+		"item": item,
+		"bank": s.bankItems(),
+	}, 3 * time.Second, nil
+}
+
+func (s *Server) decodeGoldQuantity(r *http.Request) (int, error) {
+	var body struct {
+		Quantity int `json:"quantity"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		return 0, err
+	}
+	if body.Quantity <= 0 {
+		return 0, errorf(422, "Quantity must be positive.")
+	}
+	return body.Quantity, nil
+}
+
+func (s *Server) depositGold(char *Character, r *http.Request) (map[string]interface{}, time.Duration, error) {
+	quantity, err := s.decodeGoldQuantity(r)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if _, err = s.requireContent(char, "bank"); err != nil {
+		return nil, 0, err
+	}
+
+	if char.Gold < quantity {
+		return nil, 0, errorf(492, "Insufficient gold on your character.")
+	}
+	char.Gold -= quantity
+	s.world.BankGold += quantity
+
+	return map[string]interface{}{
+		"bank": map[string]int{"quantity": s.world.BankGold},
+	}, 3 * time.Second, nil
+}
+
+func (s *Server) withdrawGold(char *Character, r *http.Request) (map[string]interface{}, time.Duration, error) {
+	quantity, err := s.decodeGoldQuantity(r)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if _, err = s.requireContent(char, "bank"); err != nil {
+		return nil, 0, err
+	}
+
+	if s.world.BankGold < quantity {
+		return nil, 0, errorf(460, "Insufficient gold in your bank.")
+	}
+	s.world.BankGold -= quantity
+	char.Gold += quantity
+
+	return map[string]interface{}{
+		"bank": map[string]int{"quantity": s.world.BankGold},
+	}, 3 * time.Second, nil
+}
+
+func (s *Server) decodeGETrade(r *http.Request) (*GEItem, int, int, error) {
+	var body struct {
+		Code     string `json:"code"`
+		Quantity int    `json:"quantity"`
+		Price    int    `json:"price"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		return nil, 0, 0, err
+	}
+
+	geItem := s.geItem(body.Code)
+	if geItem == nil {
+		return nil, 0, 0, errorf(404, "Item not found.")
+	}
+	if body.Quantity <= 0 || body.Quantity > geItem.MaxQuantity {
+		return nil, 0, 0, errorf(422, "Invalid quantity.")
+	}
+
+	return geItem, body.Quantity, body.Price, nil
+}
+
+func geTransaction(code string, quantity int, price int) map[string]interface{} {
+	return map[string]interface{}{
+		"transaction": map[string]interface{}{
+			"code":        code,
+			"quantity":    quantity,
+			"price":       price,
+			"total_price": quantity * price,
+		},
+	}
+}
+
+func (s *Server) buyGE(char *Character, r *http.Request) (map[string]interface{}, time.Duration, error) {
+	geItem, quantity, price, err := s.decodeGETrade(r)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if _, err = s.requireContent(char, "grand_exchange"); err != nil {
+		return nil, 0, err
+	}
+
+	if geItem.BuyPrice == nil || *geItem.BuyPrice != price {
+		return nil, 0, errorf(482, "The item price has changed.")
+	}
+	if geItem.Stock < quantity {
+		return nil, 0, errorf(480, "Insufficient stock.")
+	}
+	if char.Gold < quantity*price {
+		return nil, 0, errorf(492, "Insufficient gold on your character.")
+	}
+
+	if err = char.addItem(geItem.Code, quantity); err != nil {
+		return nil, 0, err
+	}
+	char.Gold -= quantity * price
+	geItem.Stock -= quantity
+
+	return geTransaction(geItem.Code, quantity, price), 3 * time.Second, nil
+}
+
+func (s *Server) sellGE(char *Character, r *http.Request) (map[string]interface{}, time.Duration, error) {
+	geItem, quantity, price, err := s.decodeGETrade(r)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if _, err = s.requireContent(char, "grand_exchange"); err != nil {
+		return nil, 0, err
+	}
+
+	if geItem.SellPrice == nil || *geItem.SellPrice != price {
+		return nil, 0, errorf(482, "The item price has changed.")
+	}
+
+	if err = char.removeItem(geItem.Code, quantity); err != nil {
+		return nil, 0, err
+	}
+	char.Gold += quantity * price
+	geItem.Stock += quantity
+
+	return geTransaction(geItem.Code, quantity, price), 3 * time.Second, nil
+}
+
+func (s *Server) equip(char *Character, r *http.Request) (map[string]interface{}, time.Duration, error) {
+	var body struct {
+		Code string `json:"code"`
+		Slot string `json:"slot"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		return nil, 0, err
+	}
+
+	item, ok := s.items[body.Code]
+	if !ok {
+		return nil, 0, errorf(404, "Item not found.")
+	}
+
+	slot := char.slot(body.Slot)
+	if slot == nil || !slotAccepts(body.Slot, item.Type) {
+		return nil, 0, errorf(422, "Invalid slot.")
+	}
+	if *slot == item.Code {
+		return nil, 0, errorf(485, "This item is already equipped.")
+	}
+	if *slot != "" {
+		return nil, 0, errorf(491, "Slot is not empty.")
+	}
+	if char.Level < item.Level {
+		return nil, 0, errorf(496, "Character level is insufficient.")
+	}
+
+	quantity := 1
+	if item.Type == "consumable" {
+		quantity = max(1, char.quantity(item.Code))
+	}
+	if err := char.removeItem(item.Code, quantity); err != nil {
+		return nil, 0, err
+	}
+	*slot = item.Code
+
+	// Consumables are stacked into their slot along with everything else we're holding
+	switch body.Slot {
+	case "consumable1":
+		char.Consumable1SlotQuantity = quantity
+	case "consumable2":
+		char.Consumable2SlotQuantity = quantity
+	}
+
+	return map[string]interface{}{
+		"slot": body.Slot,
+		"item": item,
+	}, 3 * time.Second, nil
+}
+
+func (s *Server) unequip(char *Character, r *http.Request) (map[string]interface{}, time.Duration, error) {
+	var body struct {
+		Slot string `json:"slot"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		return nil, 0, err
+	}
+
+	slot := char.slot(body.Slot)
+	if slot == nil {
+		return nil, 0, errorf(422, "Invalid slot.")
+	}
+	if *slot == "" {
+		return nil, 0, errorf(491, "Slot is empty.")
+	}
+
+	quantity := 1
+	switch body.Slot {
+	case "consumable1":
+		quantity = char.Consumable1SlotQuantity
+		char.Consumable1SlotQuantity = 0
+	case "consumable2":
+		quantity = char.Consumable2SlotQuantity
+		char.Consumable2SlotQuantity = 0
+	}
+
+	item := s.items[*slot]
+	if err := char.addItem(*slot, max(quantity, 1)); err != nil {
+		return nil, 0, err
+	}
+	*slot = ""
+
+	return map[string]interface{}{
+		"slot": body.Slot,
+		"item": item,
+	}, 3 * time.Second, nil
+}
+
+func (s *Server) recycle(char *Character, r *http.Request) (map[string]interface{}, time.Duration, error) {
+	item, quantity, err := s.decodeItemQuantity(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	if item.Craft == nil || len(item.Craft.Items) == 0 {
+		return nil, 0, errorf(473, "This item cannot be recycled.")
+	}
+
+	content, err := s.requireContent(char, "workshop")
+	if err != nil {
+		return nil, 0, err
+	}
+	if content.Code != item.Craft.Skill {
+		return nil, 0, errorf(598, "Workshop not found on this map.")
+	}
+
+	if err = char.removeItem(item.Code, quantity); err != nil {
+		return nil, 0, err
+	}
+
+	// Each recycled item gives back one random unit of its recipe
+	recovered := map[string]int{}
+	for i := 0; i < quantity; i++ {
+		material := item.Craft.Items[s.rng.Intn(len(item.Craft.Items))]
+		recovered[material.Code]++
+	}
+
+	items := []SimpleItem{}
+	for code, q := range recovered {
+		_ = char.addItem(code, q)
+		items = append(items, SimpleItem{Code: code, Quantity: q})
+	}
+
+	return map[string]interface{}{
+		"details": map[string]interface{}{
+			"items": items,
+		},
+	}, time.Duration(5*quantity) * time.Second, nil
+}
+
+func (s *Server) newTask(char *Character, _ *http.Request) (map[string]interface{}, time.Duration, error) {
+	if _, err := s.requireContent(char, "tasks_master"); err != nil {
+		return nil, 0, err
+	}
+	if char.Task != "" {
+		return nil, 0, errorf(489, "Character already has a task.")
+	}
+
+	var candidates []*Monster
+	for _, monster := range s.monsters {
+		if monster.Level <= char.Level && monster.Level+10 >= char.Level {
+			candidates = append(candidates, monster)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, 0, errorf(404, "No task available.")
+	}
+
+	// Map iteration order is random, so sort to keep seeded runs reproducible
+	slices.SortFunc(candidates, func(a, b *Monster) int {
+		return strings.Compare(a.Code, b.Code)
+	})
+	monster := candidates[s.rng.Intn(len(candidates))]
+
+	char.Task = monster.Code
+	char.TaskType = "monsters"
+	char.TaskProgress = 0
+	char.TaskTotal = s.randBetween(10, 30)
+
+	return map[string]interface{}{
+		"task": map[string]interface{}{
+			"code":  char.Task,
+			"type":  char.TaskType,
+			"total": char.TaskTotal,
+		},
+	}, 3 * time.Second, nil
+}
+
+func (s *Server) completeTask(char *Character, _ *http.Request) (map[string]interface{}, time.Duration, error) {
+	if _, err := s.requireContent(char, "tasks_master"); err != nil {
+		return nil, 0, err
+	}
+	if char.Task == "" {
+		return nil, 0, errorf(487, "Character has no task.")
+	}
+	if char.TaskProgress < char.TaskTotal {
+		return nil, 0, errorf(488, "Character has not completed the task.")
+	}
+
+	quantity := s.randBetween(1, 3)
+	if err := char.addItem(tasksCoinItemCode, quantity); err != nil {
+		return nil, 0, err
+	}
+
+	char.Task = ""
+	char.TaskType = ""
+	char.TaskProgress = 0
+	char.TaskTotal = 0
+
+	return map[string]interface{}{
+		"reward": SimpleItem{Code: tasksCoinItemCode, Quantity: quantity},
+	}, 3 * time.Second, nil
+}
+
+func (s *Server) exchangeTask(char *Character, _ *http.Request) (map[string]interface{}, time.Duration, error) {
+	if _, err := s.requireContent(char, "tasks_master"); err != nil {
+		return nil, 0, err
+	}
+	if len(s.world.TaskRewards) == 0 {
+		return nil, 0, errorf(404, "No task rewards available.")
+	}
+	if err := char.removeItem(tasksCoinItemCode, 3); err != nil {
+		return nil, 0, err
+	}
+
+	reward := SimpleItem{
+		Code:     s.world.TaskRewards[s.rng.Intn(len(s.world.TaskRewards))],
+		Quantity: 1,
+	}
+	if err := char.addItem(reward.Code, reward.Quantity); err != nil {
+		return nil, 0, err
+	}
+
+	return map[string]interface{}{
+		"reward": reward,
+	}, 3 * time.Second, nil
+}
+
+func (s *Server) cancelTask(char *Character, _ *http.Request) (map[string]interface{}, time.Duration, error) {
+	if _, err := s.requireContent(char, "tasks_master"); err != nil {
+		return nil, 0, err
+	}
+	if char.Task == "" {
+		return nil, 0, errorf(487, "Character has no task.")
+	}
+	if err := char.removeItem(tasksCoinItemCode, 1); err != nil {
+		return nil, 0, err
+	}
+
+	char.Task = ""
+	char.TaskType = ""
+	char.TaskProgress = 0
+	char.TaskTotal = 0
+
+	return nil, 3 * time.Second, nil
+}
@@ -0,0 +1,239 @@
+package fakeapi
+
+import (
+	"strings"
+)
+
+var skills = []string{
+	"mining",
+	"woodcutting",
+	"fishing",
+	"weaponcrafting",
+	"gearcrafting",
+	"jewelrycrafting",
+	"cooking",
+}
+
+func maxXpForLevel(level int) int {
+	return 150 + 100*(level-1)
+}
+
+// skill returns pointers to the level, xp and max xp fields for a skill. Combat is the character level.
+func (c *Character) skill(name string) (level *int, xp *int, maxXp *int) {
+	switch name {
+	case "mining":
+		return &c.MiningLevel, &c.MiningXp, &c.MiningMaxXp
+	case "woodcutting":
+		return &c.WoodcuttingLevel, &c.WoodcuttingXp, &c.WoodcuttingMaxXp
+	case "fishing":
+		return &c.FishingLevel, &c.FishingXp, &c.FishingMaxXp
+	case "weaponcrafting":
+		return &c.WeaponcraftingLevel, &c.WeaponcraftingXp, &c.WeaponcraftingMaxXp
+	case "gearcrafting":
+		return &c.GearcraftingLevel, &c.GearcraftingXp, &c.GearcraftingMaxXp
+	case "jewelrycrafting":
+		return &c.JewelrycraftingLevel, &c.JewelrycraftingXp, &c.JewelrycraftingMaxXp
+	case "cooking":
+		return &c.CookingLevel, &c.CookingXp, &c.CookingMaxXp
+	default:
+		return &c.Level, &c.Xp, &c.MaxXp
+	}
+}
+
+func (c *Character) addXp(skill string, amount int) {
+	level, xp, maxXp := c.skill(skill)
+	*xp += amount
+	if skill == "combat" {
+		c.TotalXp += amount
+	}
+	for *xp >= *maxXp {
+		*xp -= *maxXp
+		*level++
+		*maxXp = maxXpForLevel(*level)
+	}
+}
+
+// slot returns a pointer to the equipment slot field, or nil if there's no such slot
+func (c *Character) slot(name string) *string {
+	switch name {
+	case "weapon":
+		return &c.WeaponSlot
+	case "shield":
+		return &c.ShieldSlot
+	case "helmet":
+		return &c.HelmetSlot
+	case "body_armor":
+		return &c.BodyArmorSlot
+	case "leg_armor":
+		return &c.LegArmorSlot
+	case "boots":
+		return &c.BootsSlot
+	case "ring1":
+		return &c.Ring1Slot
+	case "ring2":
+		return &c.Ring2Slot
+	case "amulet":
+		return &c.AmuletSlot
+	case "artifact1":
+		return &c.Artifact1Slot
+	case "artifact2":
+		return &c.Artifact2Slot
+	case "artifact3":
+		return &c.Artifact3Slot
+	case "consumable1":
+		return &c.Consumable1Slot
+	case "consumable2":
+		return &c.Consumable2Slot
+	default:
+		return nil
+	}
+}
+
+func (c *Character) equipped() []string {
+	return []string{
+		c.WeaponSlot, c.ShieldSlot, c.HelmetSlot, c.BodyArmorSlot, c.LegArmorSlot, c.BootsSlot,
+		c.Ring1Slot, c.Ring2Slot, c.AmuletSlot, c.Artifact1Slot, c.Artifact2Slot, c.Artifact3Slot,
+	}
+}
+
+// slotAccepts reports whether an item of the given type can go in the slot
+func slotAccepts(slot string, itemType string) bool {
+	return strings.TrimRight(slot, "123") == itemType
+}
+
+func (c *Character) inventoryCount() int {
+	count := 0
+	for _, slot := range c.Inventory {
+		count += slot.Quantity
+	}
+	return count
+}
+
+func (c *Character) quantity(code string) int {
+	for _, slot := range c.Inventory {
+		if slot.Code == code {
+			return slot.Quantity
+		}
+	}
+	return 0
+}
+
+func (c *Character) hasSpaceFor(quantity int) bool {
+	return c.inventoryCount()+quantity <= c.InventoryMaxItems
+}
+
+func (c *Character) addItem(code string, quantity int) error {
+	if !c.hasSpaceFor(quantity) {
+		return errorf(497, "Character inventory is full.")
+	}
+	for i := range c.Inventory {
+		if c.Inventory[i].Code == code {
+			c.Inventory[i].Quantity += quantity
+			return nil
+		}
+	}
+	c.Inventory = append(c.Inventory, InventorySlot{
+		Slot:     len(c.Inventory) + 1,
+		Code:     code,
+		Quantity: quantity,
+	})
+	return nil
+}
+
+func (c *Character) removeItem(code string, quantity int) error {
+	for i := range c.Inventory {
+		if c.Inventory[i].Code != code {
+			continue
+		}
+		if c.Inventory[i].Quantity < quantity {
+			break
+		}
+		c.Inventory[i].Quantity -= quantity
+		if c.Inventory[i].Quantity == 0 {
+			c.Inventory = append(c.Inventory[:i], c.Inventory[i+1:]...)
+			for j := range c.Inventory {
+				c.Inventory[j].Slot = j + 1
+			}
+		}
+		return nil
+	}
+	return errorf(478, "Missing item or insufficient quantity.")
+}
+
+// fighter is the subset of stats that matter in a fight, for either side
+type fighter struct {
+	hp     int
+	haste  int
+	attack map[string]int
+	damage map[string]int
+	resist map[string]int
+}
+
+var elements = []string{"fire", "earth", "water", "air"}
+
+// characterFighter's hp is the character's max HP, not what they have right now
+func (s *Server) characterFighter(c *Character) fighter {
+	f := fighter{
+		hp:     115 + 5*c.Level,
+		attack: map[string]int{},
+		damage: map[string]int{},
+		resist: map[string]int{},
+	}
+
+	for _, code := range c.equipped() {
+		item, ok := s.items[code]
+		if code == "" || !ok {
+			continue
+		}
+		for _, effect := range item.Effects {
+			switch {
+			case effect.Name == "hp":
+				f.hp += effect.Value
+			case effect.Name == "haste":
+				f.haste += effect.Value
+			case strings.HasPrefix(effect.Name, "attack_"):
+				f.attack[strings.TrimPrefix(effect.Name, "attack_")] += effect.Value
+			case strings.HasPrefix(effect.Name, "dmg_"):
+				f.damage[strings.TrimPrefix(effect.Name, "dmg_")] += effect.Value
+			case strings.HasPrefix(effect.Name, "res_"):
+				f.resist[strings.TrimPrefix(effect.Name, "res_")] += effect.Value
+			}
+		}
+	}
+
+	return f
+}
+
+func monsterFighter(m *Monster) fighter {
+	return fighter{
+		hp: m.Hp,
+		attack: map[string]int{
+			"fire":  m.AttackFire,
+			"earth": m.AttackEarth,
+			"water": m.AttackWater,
+			"air":   m.AttackAir,
+		},
+		damage: map[string]int{},
+		resist: map[string]int{
+			"fire":  m.ResFire,
+			"earth": m.ResEarth,
+			"water": m.ResWater,
+			"air":   m.ResAir,
+		},
+	}
+}
+
+// gatheringBonus is the percentage cooldown reduction the equipped weapon gives for a skill
+func (s *Server) gatheringBonus(c *Character, skill string) int {
+	item, ok := s.items[c.WeaponSlot]
+	if !ok {
+		return 0
+	}
+	for _, effect := range item.Effects {
+		if effect.Name == skill {
+			// Tools have negative values, e.g. -10 for 10% faster
+			return -effect.Value
+		}
+	}
+	return 0
+}
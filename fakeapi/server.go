@@ -0,0 +1,404 @@
+package fakeapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type Options struct {
+	// CooldownScale multiplies every cooldown before it's applied. Zero means 1 (real time).
+	// Tests will want something tiny like 0.001 so they don't spend minutes waiting on movement.
+	CooldownScale float64
+
+	// Now defaults to time.Now
+	Now func() time.Time
+
+	// Seed for drops, blocks and anything else random
+	Seed int64
+}
+
+// Server serves the subset of the ArtifactsMMO API that the bot uses from an in-memory World.
+type Server struct {
+	opts Options
+	rng  *rand.Rand
+
+	world      *World
+	items      map[string]*Item
+	monsters   map[string]*Monster
+	resources  map[string]*Resource
+	characters map[string]*Character
+
+	mux sync.Mutex
+}
+
+func New(world *World, opts Options) *Server {
+	if opts.CooldownScale == 0 {
+		opts.CooldownScale = 1
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if world.Bank == nil {
+		world.Bank = map[string]int{}
+	}
+
+	s := &Server{
+		opts:       opts,
+		rng:        rand.New(rand.NewSource(opts.Seed)),
+		world:      world,
+		items:      map[string]*Item{},
+		monsters:   map[string]*Monster{},
+		resources:  map[string]*Resource{},
+		characters: map[string]*Character{},
+	}
+
+	for i := range world.Items {
+		s.items[world.Items[i].Code] = &world.Items[i]
+	}
+	for i := range world.Monsters {
+		s.monsters[world.Monsters[i].Code] = &world.Monsters[i]
+	}
+	for i := range world.Resources {
+		s.resources[world.Resources[i].Code] = &world.Resources[i]
+	}
+	for i := range world.Characters {
+		char := &world.Characters[i]
+		if char.Inventory == nil {
+			// The client dereferences the inventory, so it can't be null
+			char.Inventory = []InventorySlot{}
+		}
+		if char.InventoryMaxItems == 0 {
+			char.InventoryMaxItems = 100
+		}
+		if char.Level == 0 {
+			char.Level = 1
+		}
+		if char.MaxXp == 0 {
+			char.MaxXp = maxXpForLevel(char.Level)
+		}
+		for _, skill := range skills {
+			level, _, maxXp := char.skill(skill)
+			if *level == 0 {
+				*level = 1
+			}
+			if *maxXp == 0 {
+				*maxXp = maxXpForLevel(*level)
+			}
+		}
+		if char.Hp == 0 {
+			char.Hp = s.characterFighter(char).hp
+		}
+		s.characters[char.Name] = char
+	}
+
+	return s
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /characters/{name}", s.getCharacter)
+	mux.HandleFunc("GET /my/bank/items", s.getBankItems)
+	mux.HandleFunc("GET /my/bank/gold", s.getBankGold)
+
+	mux.HandleFunc("GET /items", func(w http.ResponseWriter, r *http.Request) {
+		writePage(w, r, s.world.Items)
+	})
+	mux.HandleFunc("GET /monsters", func(w http.ResponseWriter, r *http.Request) {
+		writePage(w, r, s.world.Monsters)
+	})
+	mux.HandleFunc("GET /resources", func(w http.ResponseWriter, r *http.Request) {
+		writePage(w, r, s.world.Resources)
+	})
+	mux.HandleFunc("GET /maps", func(w http.ResponseWriter, r *http.Request) {
+		writePage(w, r, s.world.Maps)
+	})
+	mux.HandleFunc("GET /ge", s.getGEItems)
+	mux.HandleFunc("GET /events", func(w http.ResponseWriter, r *http.Request) {
+		writePage(w, r, s.world.Events)
+	})
+
+	mux.HandleFunc("POST /my/{name}/action/move", s.action("movement", s.move))
+	mux.HandleFunc("POST /my/{name}/action/fight", s.action("fight", s.fight))
+	mux.HandleFunc("POST /my/{name}/action/gathering", s.action("gathering", s.gather))
+	mux.HandleFunc("POST /my/{name}/action/crafting", s.action("crafting", s.craft))
+	mux.HandleFunc("POST /my/{name}/action/bank/deposit", s.action("deposit_bank", s.deposit))
+	mux.HandleFunc("POST /my/{name}/action/bank/withdraw", s.action("withdraw_bank", s.withdraw))
+	mux.HandleFunc("POST /my/{name}/action/bank/deposit/gold", s.action("deposit_bank", s.depositGold))
+	mux.HandleFunc("POST /my/{name}/action/bank/withdraw/gold", s.action("withdraw_bank", s.withdrawGold))
+	mux.HandleFunc("POST /my/{name}/action/ge/buy", s.action("buy_ge", s.buyGE))
+	mux.HandleFunc("POST /my/{name}/action/ge/sell", s.action("sell_ge", s.sellGE))
+	mux.HandleFunc("POST /my/{name}/action/equip", s.action("equip", s.equip))
+	mux.HandleFunc("POST /my/{name}/action/unequip", s.action("unequip", s.unequip))
+	mux.HandleFunc("POST /my/{name}/action/recycling", s.action("recycling", s.recycle))
+	mux.HandleFunc("POST /my/{name}/action/task/new", s.action("task", s.newTask))
+	mux.HandleFunc("POST /my/{name}/action/task/complete", s.action("task", s.completeTask))
+	mux.HandleFunc("POST /my/{name}/action/task/exchange", s.action("task", s.exchangeTask))
+	mux.HandleFunc("POST /my/{name}/action/task/cancel", s.action("task", s.cancelTask))
+
+	return mux
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, s.Handler())
+}
+
+// Character returns a copy of the named character as the server currently sees it
+func (s *Server) Character(name string) (Character, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	char, ok := s.characters[name]
+	if !ok {
+		return Character{}, false
+	}
+	return *char, true
+}
+
+// Bank returns a copy of the bank contents
+func (s *Server) Bank() map[string]int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	bank := map[string]int{}
+	for code, quantity := range s.world.Bank {
+		bank[code] = quantity
+	}
+	return bank
+}
+
+// apiError is written as the API's error envelope, which httperror knows how to parse
+type apiError struct {
+	Code    int
+	Message string
+}
+
+func (e apiError) Error() string {
+	return fmt.Sprintf("[%d] %s", e.Code, e.Message)
+}
+
+func errorf(code int, format string, args ...interface{}) apiError {
+	return apiError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, err apiError) {
+	body := map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    err.Code,
+			"message": err.Message,
+		},
+	}
+	writeJSON(w, err.Code, body)
+}
+
+func writePage[T any](w http.ResponseWriter, r *http.Request, all []T) {
+	page := queryInt(r, "page", 1)
+	size := queryInt(r, "size", 50)
+	if page < 1 || size < 1 {
+		writeError(w, errorf(422, "Invalid pagination."))
+		return
+	}
+
+	start := min((page-1)*size, len(all))
+	end := min(start+size, len(all))
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"data":  all[start:end],
+		"total": len(all),
+		"page":  page,
+		"size":  size,
+		"pages": int(math.Ceil(float64(len(all)) / float64(size))),
+	})
+}
+
+func queryInt(r *http.Request, key string, fallback int) int {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return fallback
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return -1
+	}
+	return i
+}
+
+func (s *Server) getCharacter(w http.ResponseWriter, r *http.Request) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	char, ok := s.characters[r.PathValue("name")]
+	if !ok {
+		writeError(w, errorf(404, "Character not found."))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": char})
+}
+
+func (s *Server) getBankItems(w http.ResponseWriter, r *http.Request) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	writePage(w, r, s.bankItems())
+}
+
+func (s *Server) getBankGold(w http.ResponseWriter, r *http.Request) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]int{"quantity": s.world.BankGold}})
+}
+
+func (s *Server) getGEItems(w http.ResponseWriter, r *http.Request) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	writePage(w, r, s.world.GrandExchange)
+}
+
+func (s *Server) geItem(code string) *GEItem {
+	for i := range s.world.GrandExchange {
+		if s.world.GrandExchange[i].Code == code {
+			return &s.world.GrandExchange[i]
+		}
+	}
+	return nil
+}
+
+func (s *Server) bankItems() []SimpleItem {
+	items := []SimpleItem{}
+	for code, quantity := range s.world.Bank {
+		if quantity > 0 {
+			items = append(items, SimpleItem{Code: code, Quantity: quantity})
+		}
+	}
+	return items
+}
+
+// actionFunc performs an action for a character and returns any data to include in the response
+// alongside the cooldown and character, and the cooldown duration the action incurs.
+type actionFunc func(char *Character, r *http.Request) (map[string]interface{}, time.Duration, error)
+
+func (s *Server) action(reason string, f actionFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mux.Lock()
+		defer s.mux.Unlock()
+
+		char, ok := s.characters[r.PathValue("name")]
+		if !ok {
+			writeError(w, errorf(498, "Character not found."))
+			return
+		}
+
+		now := s.opts.Now()
+		if now.Before(char.CooldownExpiration) {
+			writeError(w, errorf(499, "Character in cooldown: %.2f seconds left.", char.CooldownExpiration.Sub(now).Seconds()))
+			return
+		}
+
+		data, cooldown, err := f(char, r)
+		if err != nil {
+			var apiErr apiError
+			if e, ok := err.(apiError); ok {
+				apiErr = e
+			} else {
+				apiErr = errorf(422, err.Error())
+			}
+			writeError(w, apiErr)
+			return
+		}
+
+		scaled := time.Duration(float64(cooldown) * s.opts.CooldownScale)
+		char.Cooldown = int(math.Ceil(cooldown.Seconds()))
+		char.CooldownExpiration = now.Add(scaled)
+
+		if data == nil {
+			data = map[string]interface{}{}
+		}
+		data["cooldown"] = map[string]interface{}{
+			"total_seconds":     char.Cooldown,
+			"remaining_seconds": char.Cooldown,
+			"started_at":        now,
+			"expiration":        char.CooldownExpiration,
+			"reason":            reason,
+		}
+		data["character"] = char
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": data})
+	}
+}
+
+func decodeBody(r *http.Request, v interface{}) error {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		return errorf(422, "Invalid payload: %s", err)
+	}
+	return nil
+}
+
+// contentAt returns what's on the tile at x, y. Active events take precedence over the base map.
+func (s *Server) contentAt(x, y int) *MapContent {
+	for _, event := range s.world.Events {
+		if event.Map.X == x && event.Map.Y == y && event.Map.Content != nil {
+			return event.Map.Content
+		}
+	}
+	for _, tile := range s.world.Maps {
+		if tile.X == x && tile.Y == y {
+			return tile.Content
+		}
+	}
+	return nil
+}
+
+func (s *Server) tileAt(x, y int) (Tile, bool) {
+	for _, tile := range s.world.Maps {
+		if tile.X == x && tile.Y == y {
+			return tile, true
+		}
+	}
+	return Tile{}, false
+}
+
+func (s *Server) requireContent(char *Character, contentType string) (*MapContent, error) {
+	content := s.contentAt(char.X, char.Y)
+	if content == nil || content.Type != contentType {
+		return nil, errorf(598, "%s not found on this map.", contentType)
+	}
+	return content, nil
+}
+
+func (s *Server) randBetween(lo, hi int) int {
+	if hi <= lo {
+		return lo
+	}
+	return lo + s.rng.Intn(hi-lo+1)
+}
+
+// rollDrops rolls each drop with a 1/rate chance
+func (s *Server) rollDrops(drops []DropRate) []SimpleItem {
+	var rolled []SimpleItem
+	for _, drop := range drops {
+		if drop.Rate <= 0 || s.rng.Intn(drop.Rate) != 0 {
+			continue
+		}
+		rolled = append(rolled, SimpleItem{
+			Code:     drop.Code,
+			Quantity: s.randBetween(drop.MinQuantity, drop.MaxQuantity),
+		})
+	}
+	return rolled
+}
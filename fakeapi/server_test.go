@@ -0,0 +1,196 @@
+package fakeapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ahornerr/artifacts/game"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func newTestServer(t *testing.T, edit func(world *World)) (*Server, *httptest.Server) {
+	t.Helper()
+
+	world, err := DefaultWorld()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edit != nil {
+		edit(world)
+	}
+
+	s := New(world, Options{CooldownScale: 0.0001, Seed: 1})
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+type fightResponse struct {
+	Data struct {
+		Fight struct {
+			Result string   `json:"result"`
+			Logs   []string `json:"logs"`
+		} `json:"fight"`
+		Character Character `json:"character"`
+	} `json:"data"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func postFight(t *testing.T, s *Server, ts *httptest.Server, name string) fightResponse {
+	t.Helper()
+
+	// Skip the cooldown from the last fight rather than sleeping through it
+	s.mux.Lock()
+	s.characters[name].CooldownExpiration = s.opts.Now().Add(-1)
+	s.mux.Unlock()
+
+	resp, err := http.Post(fmt.Sprintf("%s/my/%s/action/fight", ts.URL, name), "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var fight fightResponse
+	if err = json.NewDecoder(resp.Body).Decode(&fight); err != nil {
+		t.Fatal(err)
+	}
+	if fight.Error != nil {
+		t.Fatalf("fight failed: [%d] %s", fight.Error.Code, fight.Error.Message)
+	}
+	return fight
+}
+
+func moveTo(world *World, name string, x, y int) {
+	for i := range world.Characters {
+		if world.Characters[i].Name == name {
+			world.Characters[i].X, world.Characters[i].Y = x, y
+		}
+	}
+}
+
+func TestDefaultWorld(t *testing.T) {
+	s, _ := newTestServer(t, nil)
+
+	char, ok := s.Character("curlyBoy1")
+	if !ok {
+		t.Fatal("curlyBoy1 is missing")
+	}
+	// 115 + 5 for level 1, nothing but a weapon equipped
+	if char.Hp != 120 {
+		t.Errorf("hp = %d, want 120", char.Hp)
+	}
+
+	for _, tile := range s.world.Maps {
+		if tile.Content == nil {
+			continue
+		}
+		switch tile.Content.Type {
+		case "monster":
+			if s.monsters[tile.Content.Code] == nil {
+				t.Errorf("map has unknown monster %s", tile.Content.Code)
+			}
+		case "resource":
+			if s.resources[tile.Content.Code] == nil {
+				t.Errorf("map has unknown resource %s", tile.Content.Code)
+			}
+		}
+	}
+	for _, item := range s.world.Items {
+		if item.Craft == nil {
+			continue
+		}
+		for _, material := range item.Craft.Items {
+			if s.items[material.Code] == nil {
+				t.Errorf("%s is made from unknown item %s", item.Code, material.Code)
+			}
+		}
+	}
+}
+
+func TestFightStartsFromCurrentHp(t *testing.T) {
+	s, ts := newTestServer(t, func(world *World) {
+		moveTo(world, "curlyBoy1", 0, 1)
+		world.Characters[0].Hp = 1
+	})
+
+	fight := postFight(t, s, ts, "curlyBoy1")
+	if !strings.HasPrefix(fight.Data.Fight.Logs[0], "Fight start: Character HP: 1/120") {
+		t.Errorf("fight started with %q", fight.Data.Fight.Logs[0])
+	}
+	if fight.Data.Fight.Result != "lose" {
+		t.Fatalf("result = %s with 1 HP against a chicken", fight.Data.Fight.Result)
+	}
+
+	// Losing respawns at full HP
+	char, _ := s.Character("curlyBoy1")
+	if char.Hp != 120 || char.X != 0 || char.Y != 0 {
+		t.Errorf("after losing hp = %d at %d,%d, want 120 at 0,0", char.Hp, char.X, char.Y)
+	}
+}
+
+func TestFightKeepsHp(t *testing.T) {
+	s, ts := newTestServer(t, func(world *World) {
+		moveTo(world, "curlyBoy1", 0, 1)
+	})
+
+	hp := 120
+	for i := 0; i < 3; i++ {
+		fight := postFight(t, s, ts, "curlyBoy1")
+		if fight.Data.Fight.Result != "win" {
+			if i == 0 {
+				t.Fatal("lost to a chicken at full HP")
+			}
+			break
+		}
+
+		start := fmt.Sprintf("Fight start: Character HP: %d/120", hp)
+		if !strings.HasPrefix(fight.Data.Fight.Logs[0], start) {
+			t.Fatalf("fight %d started with %q, want %q", i+1, fight.Data.Fight.Logs[0], start)
+		}
+		if fight.Data.Character.Hp >= hp {
+			t.Fatalf("fight %d left the character with %d HP from %d", i+1, fight.Data.Character.Hp, hp)
+		}
+		hp = fight.Data.Character.Hp
+	}
+}
+
+var damageLog = regexp.MustCompile(`The (character|monster) used (\w+) attack and dealt (\d+) damage`)
+
+func TestFightDamageMatchesGame(t *testing.T) {
+	s, ts := newTestServer(t, func(world *World) {
+		moveTo(world, "curlyBoy1", 3, -2)
+		world.Characters[0].HelmetSlot = "copper_helmet"
+	})
+
+	slime := s.monsters["green_slime"]
+	want := map[string]int{
+		// Wooden stick's earth attack through the slime's 25% earth resistance
+		"character": game.ElementHit(4, 0, slime.ResEarth),
+		// Slime's earth attack through the helmet's 5% earth resistance
+		"monster": game.ElementHit(slime.AttackEarth, 0, 5),
+	}
+
+	fight := postFight(t, s, ts, "curlyBoy1")
+	hits := 0
+	for _, line := range fight.Data.Fight.Logs {
+		match := damageLog.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		hits++
+		damage, _ := strconv.Atoi(match[3])
+		if damage != want[match[1]] {
+			t.Errorf("%s dealt %d %s damage, want %d", match[1], damage, match[2], want[match[1]])
+		}
+	}
+	if hits == 0 {
+		t.Error("no hits in the fight log")
+	}
+}
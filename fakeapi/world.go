@@ -0,0 +1,229 @@
+package fakeapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// The types in this file mirror the JSON shapes of the ArtifactsMMO API so the generated client
+// can decode our responses without knowing it isn't talking to the real thing.
+
+type Effect struct {
+	Name  string `json:"name"`
+	Value int    `json:"value"`
+}
+
+type SimpleItem struct {
+	Code     string `json:"code"`
+	Quantity int    `json:"quantity"`
+}
+
+type Craft struct {
+	Skill    string       `json:"skill"`
+	Level    int          `json:"level"`
+	Items    []SimpleItem `json:"items"`
+	Quantity int          `json:"quantity"`
+}
+
+type Item struct {
+	Name        string   `json:"name"`
+	Code        string   `json:"code"`
+	Level       int      `json:"level"`
+	Type        string   `json:"type"`
+	Subtype     string   `json:"subtype"`
+	Description string   `json:"description"`
+	Effects     []Effect `json:"effects"`
+	Craft       *Craft   `json:"craft"`
+}
+
+type DropRate struct {
+	Code        string `json:"code"`
+	Rate        int    `json:"rate"`
+	MinQuantity int    `json:"min_quantity"`
+	MaxQuantity int    `json:"max_quantity"`
+}
+
+type Monster struct {
+	Name        string     `json:"name"`
+	Code        string     `json:"code"`
+	Level       int        `json:"level"`
+	Hp          int        `json:"hp"`
+	AttackFire  int        `json:"attack_fire"`
+	AttackEarth int        `json:"attack_earth"`
+	AttackWater int        `json:"attack_water"`
+	AttackAir   int        `json:"attack_air"`
+	ResFire     int        `json:"res_fire"`
+	ResEarth    int        `json:"res_earth"`
+	ResWater    int        `json:"res_water"`
+	ResAir      int        `json:"res_air"`
+	MinGold     int        `json:"min_gold"`
+	MaxGold     int        `json:"max_gold"`
+	Drops       []DropRate `json:"drops"`
+}
+
+type Resource struct {
+	Name  string     `json:"name"`
+	Code  string     `json:"code"`
+	Skill string     `json:"skill"`
+	Level int        `json:"level"`
+	Drops []DropRate `json:"drops"`
+}
+
+type MapContent struct {
+	Type string `json:"type"`
+	Code string `json:"code"`
+}
+
+type Tile struct {
+	Name    string      `json:"name"`
+	Skin    string      `json:"skin"`
+	X       int         `json:"x"`
+	Y       int         `json:"y"`
+	Content *MapContent `json:"content"`
+}
+
+type Event struct {
+	Name         string    `json:"name"`
+	Map          Tile      `json:"map"`
+	PreviousSkin string    `json:"previous_skin"`
+	Duration     int       `json:"duration"`
+	Expiration   time.Time `json:"expiration"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type InventorySlot struct {
+	Slot     int    `json:"slot"`
+	Code     string `json:"code"`
+	Quantity int    `json:"quantity"`
+}
+
+type Character struct {
+	Name                    string          `json:"name"`
+	Skin                    string          `json:"skin"`
+	Level                   int             `json:"level"`
+	Xp                      int             `json:"xp"`
+	MaxXp                   int             `json:"max_xp"`
+	TotalXp                 int             `json:"total_xp"`
+	Gold                    int             `json:"gold"`
+	Speed                   int             `json:"speed"`
+	MiningLevel             int             `json:"mining_level"`
+	MiningXp                int             `json:"mining_xp"`
+	MiningMaxXp             int             `json:"mining_max_xp"`
+	WoodcuttingLevel        int             `json:"woodcutting_level"`
+	WoodcuttingXp           int             `json:"woodcutting_xp"`
+	WoodcuttingMaxXp        int             `json:"woodcutting_max_xp"`
+	FishingLevel            int             `json:"fishing_level"`
+	FishingXp               int             `json:"fishing_xp"`
+	FishingMaxXp            int             `json:"fishing_max_xp"`
+	WeaponcraftingLevel     int             `json:"weaponcrafting_level"`
+	WeaponcraftingXp        int             `json:"weaponcrafting_xp"`
+	WeaponcraftingMaxXp     int             `json:"weaponcrafting_max_xp"`
+	GearcraftingLevel       int             `json:"gearcrafting_level"`
+	GearcraftingXp          int             `json:"gearcrafting_xp"`
+	GearcraftingMaxXp       int             `json:"gearcrafting_max_xp"`
+	JewelrycraftingLevel    int             `json:"jewelrycrafting_level"`
+	JewelrycraftingXp       int             `json:"jewelrycrafting_xp"`
+	JewelrycraftingMaxXp    int             `json:"jewelrycrafting_max_xp"`
+	CookingLevel            int             `json:"cooking_level"`
+	CookingXp               int             `json:"cooking_xp"`
+	CookingMaxXp            int             `json:"cooking_max_xp"`
+	Hp                      int             `json:"hp"`
+	Haste                   int             `json:"haste"`
+	AttackFire              int             `json:"attack_fire"`
+	AttackEarth             int             `json:"attack_earth"`
+	AttackWater             int             `json:"attack_water"`
+	AttackAir               int             `json:"attack_air"`
+	DmgFire                 int             `json:"dmg_fire"`
+	DmgEarth                int             `json:"dmg_earth"`
+	DmgWater                int             `json:"dmg_water"`
+	DmgAir                  int             `json:"dmg_air"`
+	ResFire                 int             `json:"res_fire"`
+	ResEarth                int             `json:"res_earth"`
+	ResWater                int             `json:"res_water"`
+	ResAir                  int             `json:"res_air"`
+	X                       int             `json:"x"`
+	Y                       int             `json:"y"`
+	Cooldown                int             `json:"cooldown"`
+	CooldownExpiration      time.Time       `json:"cooldown_expiration"`
+	WeaponSlot              string          `json:"weapon_slot"`
+	ShieldSlot              string          `json:"shield_slot"`
+	HelmetSlot              string          `json:"helmet_slot"`
+	BodyArmorSlot           string          `json:"body_armor_slot"`
+	LegArmorSlot            string          `json:"leg_armor_slot"`
+	BootsSlot               string          `json:"boots_slot"`
+	Ring1Slot               string          `json:"ring1_slot"`
+	Ring2Slot               string          `json:"ring2_slot"`
+	AmuletSlot              string          `json:"amulet_slot"`
+	Artifact1Slot           string          `json:"artifact1_slot"`
+	Artifact2Slot           string          `json:"artifact2_slot"`
+	Artifact3Slot           string          `json:"artifact3_slot"`
+	Consumable1Slot         string          `json:"consumable1_slot"`
+	Consumable1SlotQuantity int             `json:"consumable1_slot_quantity"`
+	Consumable2Slot         string          `json:"consumable2_slot"`
+	Consumable2SlotQuantity int             `json:"consumable2_slot_quantity"`
+	Task                    string          `json:"task"`
+	TaskType                string          `json:"task_type"`
+	TaskProgress            int             `json:"task_progress"`
+	TaskTotal               int             `json:"task_total"`
+	InventoryMaxItems       int             `json:"inventory_max_items"`
+	Inventory               []InventorySlot `json:"inventory"`
+}
+
+type GEItem struct {
+	Code        string `json:"code"`
+	Stock       int    `json:"stock"`
+	SellPrice   *int   `json:"sell_price"`
+	BuyPrice    *int   `json:"buy_price"`
+	MaxQuantity int    `json:"max_quantity"`
+}
+
+// World is everything the server knows about. It's loaded once and then mutated by actions.
+type World struct {
+	Items      []Item      `json:"items"`
+	Monsters   []Monster   `json:"monsters"`
+	Resources  []Resource  `json:"resources"`
+	Maps       []Tile      `json:"maps"`
+	Events     []Event     `json:"events"`
+	Characters []Character `json:"characters"`
+
+	// Bank item quantities keyed by item code
+	Bank     map[string]int `json:"bank"`
+	BankGold int            `json:"bank_gold"`
+
+	// GrandExchange is what the grand exchange trades. Buying and selling moves Stock but not prices.
+	GrandExchange []GEItem `json:"grand_exchange"`
+
+	// TaskRewards are the item codes handed out when exchanging task coins
+	TaskRewards []string `json:"task_rewards"`
+}
+
+//go:embed world.json
+var defaultWorld []byte
+
+// DefaultWorld is a small world with enough in it for every role: five characters, resources and their workshops,
+// a couple of monsters, the bank, the grand exchange and a tasks master. Every call returns a fresh copy.
+func DefaultWorld() (*World, error) {
+	var world World
+	if err := json.Unmarshal(defaultWorld, &world); err != nil {
+		return nil, err
+	}
+	return &world, nil
+}
+
+func LoadWorld(path string) (*World, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var world World
+	err = json.NewDecoder(f).Decode(&world)
+	if err != nil {
+		return nil, err
+	}
+
+	return &world, nil
+}
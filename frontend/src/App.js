@@ -23,7 +23,7 @@ import ExpandMoreIcon from '@mui/icons-material/ExpandMore';
 
 
 function App() {
-  const [data, setData] = useState({Characters: {}, Bank: []})
+  const [data, setData] = useState({Characters: {}, Bank: [], BankGold: 0})
   const [eventSource, setEventSource] = useState(null);
 
   const connectEventSource = () => {
@@ -51,6 +51,9 @@ function App() {
           if (parsed.Bank) {
             newData.Bank = parsed.Bank
           }
+          if (parsed.BankGold !== undefined && parsed.BankGold !== null) {
+            newData.BankGold = parsed.BankGold
+          }
           return newData
         })
 
@@ -74,6 +77,7 @@ function App() {
   const sortedCharNames = Object.keys(data.Characters).sort((a, b) => a.localeCompare(b))
 
   const numChars = sortedCharNames.length;
+  const accountGold = sortedCharNames.reduce((gold, charName) => gold + data.Characters[charName].Gold, data.BankGold)
   return (
     <div className="App">
       <Box sx={{flexGrow: 1}} m={{xs: 0, sm: 2}}>
@@ -88,7 +92,12 @@ function App() {
           <Grid xs={12} item container spacing={2}>
             <Grid item xs={12} md={6} sx={{display: "flex", flexDirection: "column"}}>
               <Paper sx={{p: 2, height: "100%"}} elevation={4}>
-                <Typography sx={{mb: 2}}>Bank</Typography>
+                <Typography sx={{mb: 2}}>
+                  Bank
+                  <Typography component="span" sx={{color: 'text.secondary', ml: 2}}>
+                    {data.BankGold} gold, {accountGold} across the account
+                  </Typography>
+                </Typography>
                 <Grid container spacing={2} justifyContent="space-evenly">
                   <ItemMap items={data.Bank}/>
                 </Grid>
@@ -233,9 +242,11 @@ function Character({char}) {
                 <Tooltip title={char.Equipment[slot]}>
                   <a href={`https://artifactsmmo.com/encyclopedia/items/${char.Equipment[slot]}`} target="_blank">
                     <Paper sx={{p: 1, pt: 1.5}} elevation={4}>
-                      <Avatar src={getEquipmentIconUrl(char.Equipment, slot)} variant="rounded">
-                        {slot}
-                      </Avatar>
+                      <Badge badgeContent={char.EquipmentQuantity?.[slot]} max={999} color="primary" overlap="circular">
+                        <Avatar src={getEquipmentIconUrl(char.Equipment, slot)} variant="rounded">
+                          {slot}
+                        </Avatar>
+                      </Badge>
                     </Paper>
                   </a>
                 </Tooltip>
@@ -1,89 +1,228 @@
 package game
 
 import (
+	"errors"
+	"fmt"
 	"math"
+	"time"
 )
 
-func Cost(itemCode string) int {
-	item := Items.Get(itemCode)
+// Cooldowns of the actions that go into getting an item, before any haste or gathering bonuses
+const (
+	MoveCooldownPerTile = 5 * time.Second
+	GatherCooldown      = 30 * time.Second
+	CraftCooldown       = 5 * time.Second
+	turnCooldown        = 2 * time.Second
+)
 
-	if item.Crafting != nil {
-		return fromCrafting(item.Crafting)
+// tripItems is how many items a character brings back per trip to the bank, roughly a starting inventory
+const tripItems = 100
+
+var ErrNoSource = errors.New("no known way to get item")
+
+// Prices are somewhere items can be bought with gold, like the grand exchange
+type Prices interface {
+	BuyPrice(itemCode string) (int, bool)
+}
+
+// FightTime is how long killing the monster takes on average, counting fights that are lost.
+// It returns false if the monster can't be beaten.
+type FightTime func(monster *Monster) (time.Duration, bool)
+
+// SetPrices lets Cost consider buying items, where each gold is worth goldTime
+func (c *Catalog) SetPrices(prices Prices, goldTime time.Duration) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.prices = prices
+	c.goldTime = goldTime
+}
+
+// SetFightTime replaces the guess at how long fights take, which goes by monster level, with something like the fight simulator
+func (c *Catalog) SetFightTime(fightTime FightTime) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.fightTime = fightTime
+}
+
+// Cost is the expected time it takes to get one of the item starting from the bank, whichever way is quickest
+func (c *Catalog) Cost(itemCode string) (time.Duration, error) {
+	item := c.Items.Get(itemCode)
+	if item == nil {
+		return 0, fmt.Errorf("%w: unknown item %s", ErrNoSource, itemCode)
 	}
 
-	resources := Resources.ResourcesForItem(item)
-	if len(resources) > 0 {
-		return fromResources(item, resources)
+	cost, err := c.obtainCost(item)
+
+	// Buying can be quicker than making or farming the item, and it's the only way to get some
+	if buyCost, ok := c.buyCost(item); ok && (err != nil || buyCost < cost) {
+		return buyCost, nil
+	}
+
+	return cost, err
+}
+
+// CostFrom is the time it takes to get quantity more of the item when stock is already on hand. Stock is used up for
+// materials at every level of the recipe, not the item itself, so something we can finish from stock costs little more
+// than crafting it. The caller's stock isn't changed.
+func (c *Catalog) CostFrom(itemCode string, quantity int, stock map[string]int) (time.Duration, error) {
+	return c.costFrom(itemCode, quantity, copyStock(stock))
+}
+
+func (c *Catalog) costFrom(itemCode string, quantity int, stock map[string]int) (time.Duration, error) {
+	if quantity <= 0 {
+		return 0, nil
 	}
 
-	monsters := Monsters.MonstersForItem(item)
-	if len(monsters) > 0 {
-		return fromMonsters(item, monsters)
+	item := c.Items.Get(itemCode)
+	if item == nil {
+		return 0, fmt.Errorf("%w: unknown item %s", ErrNoSource, itemCode)
 	}
 
-	if item.SubType == "task" {
-		// TODO
-		// Some arbitrarily high number for now
-		return 30000
+	if item.Crafting == nil {
+		cost, err := c.Cost(itemCode)
+		return cost * time.Duration(quantity), err
 	}
 
-	if item.Type == "currency" {
-		return 5000
+	// Only use up stock for crafting if that's what we end up doing
+	craftStock := copyStock(stock)
+	cost := (CraftCooldown + c.tripCost(c.Maps.GetWorkshops(item.Crafting.Skill))) * time.Duration(quantity)
+	var err error
+	for material, perCraft := range item.Crafting.Items {
+		need := perCraft * quantity
+		used := min(need, craftStock[material.Code])
+		craftStock[material.Code] -= used
+
+		materialCost, materialErr := c.costFrom(material.Code, need-used, craftStock)
+		if materialErr != nil {
+			err = fmt.Errorf("crafting needs %s: %w", material.Code, materialErr)
+			break
+		}
+		cost += materialCost
 	}
 
-	if item.Code == "wooden_stick" {
-		// Weird edge case
-		return 5000
+	if buyCost, canBuy := c.buyCost(item); canBuy && (err != nil || buyCost*time.Duration(quantity) < cost) {
+		return buyCost * time.Duration(quantity), nil
+	}
+	if err != nil {
+		return 0, err
 	}
 
-	panic("How did we get here?")
+	for code, left := range craftStock {
+		stock[code] = left
+	}
+	return cost, nil
 }
 
-func fromCrafting(crafting *Crafting) int {
-	var cost int
-	for material, quantity := range crafting.Items {
-		cost += Cost(material.Code) * quantity
+func copyStock(stock map[string]int) map[string]int {
+	c := make(map[string]int, len(stock))
+	for itemCode, quantity := range stock {
+		c[itemCode] = quantity
 	}
-	return cost
+	return c
 }
 
-func fromResources(item *Item, resources []*Resource) int {
-	lowestResourceCost := math.MaxInt32
-	for _, resource := range resources {
-		drop := resource.Loot[item]
-		avgDropQuantity := float64(drop.MinQuantity+drop.MaxQuantity) / 2.0
-		cost := avgDropQuantity * float64(drop.Rate)
+// obtainCost is the time it takes to get the item without buying it
+func (c *Catalog) obtainCost(item *Item) (time.Duration, error) {
+	if item.Crafting != nil {
+		return c.fromCrafting(item.Crafting)
+	}
+
+	cost := time.Duration(math.MaxInt64)
+	found := false
+
+	for _, resource := range c.Resources.ResourcesForItem(item) {
+		if resourceCost, ok := c.fromResource(item, resource); ok && resourceCost < cost {
+			cost = resourceCost
+			found = true
+		}
+	}
+
+	for _, monster := range c.Monsters.MonstersForItem(item) {
+		if monsterCost, ok := c.fromMonster(item, monster); ok && monsterCost < cost {
+			cost = monsterCost
+			found = true
+		}
+	}
 
-		// Some multiplier based on roughly how long it takes to harvest the resource
-		cost *= float64(resource.Level)
+	if !found {
+		return 0, fmt.Errorf("%w: %s", ErrNoSource, item.Code)
+	}
+	return cost, nil
+}
 
-		if int(cost) < lowestResourceCost {
-			lowestResourceCost = int(cost)
+func (c *Catalog) fromCrafting(crafting *Crafting) (time.Duration, error) {
+	cost := CraftCooldown + c.tripCost(c.Maps.GetWorkshops(crafting.Skill))
+	for material, quantity := range crafting.Items {
+		materialCost, err := c.Cost(material.Code)
+		if err != nil {
+			return 0, fmt.Errorf("crafting needs %s: %w", material.Code, err)
 		}
+		cost += materialCost * time.Duration(quantity)
+	}
+	return cost, nil
+}
+
+func (c *Catalog) fromResource(item *Item, resource *Resource) (time.Duration, bool) {
+	locations := c.Maps.GetResources(resource.Code)
+	if len(locations) == 0 {
+		return 0, false
 	}
-	return lowestResourceCost
+
+	actions := resource.Loot[item.Code].ExpectedActions()
+	return time.Duration(actions*float64(GatherCooldown)) + c.tripCost(locations), true
 }
 
-func fromMonsters(item *Item, monsters []*Monster) int {
-	lowestMonsterCost := math.MaxInt32
-	for _, monster := range monsters {
-		drop := monster.Loot[item]
-		avgDropQuantity := float64(drop.MinQuantity+drop.MaxQuantity) / 2.0
+func (c *Catalog) fromMonster(item *Item, monster *Monster) (time.Duration, bool) {
+	locations := c.Maps.GetMonsters(monster.Code)
+	if len(locations) == 0 {
+		return 0, false
+	}
+
+	c.mux.Lock()
+	fightTime := c.fightTime
+	c.mux.Unlock()
+
+	// Without a simulator, guess that higher level monsters take more turns
+	perFight := time.Duration(2*monster.Level) * turnCooldown
+	if fightTime != nil {
+		var ok bool
+		perFight, ok = fightTime(monster)
+		if !ok {
+			return 0, false
+		}
+	}
 
-		// Expected kills, via https://www.reddit.com/r/2007scape/comments/gpz0dw/drop_rates_and_probabilities_very_long_post/
-		confidence := 0.75
-		pDrop := 1.0 / float64(drop.Rate)
-		pNoDrop := 1.0 - pDrop
-		expectedKills := math.Log(1.0-confidence) / math.Log(pNoDrop)
+	kills := monster.Loot[item.Code].ExpectedActions()
+	return time.Duration(kills*float64(perFight)) + c.tripCost(locations), true
+}
 
-		cost := avgDropQuantity * expectedKills
+func (c *Catalog) buyCost(item *Item) (time.Duration, bool) {
+	c.mux.Lock()
+	prices, goldTime := c.prices, c.goldTime
+	c.mux.Unlock()
 
-		// Some multiplier based on roughly how long it takes to kill the monster
-		cost *= float64(monster.Level) * 2
+	if prices == nil {
+		return 0, false
+	}
+	price, ok := prices.BuyPrice(item.Code)
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(price)*goldTime + c.tripCost(c.Maps.GetGrandExchanges()), true
+}
 
-		if int(cost) < lowestMonsterCost {
-			lowestMonsterCost = int(cost)
+// tripCost is the walk from the closest bank to the closest location and back, shared by a trip's worth of items
+func (c *Catalog) tripCost(locations []Location) time.Duration {
+	shortest := math.Inf(1)
+	for _, bank := range c.Maps.GetBanks() {
+		for _, location := range locations {
+			shortest = min(shortest, bank.DistanceTo(location))
 		}
 	}
-	return lowestMonsterCost
+	if math.IsInf(shortest, 1) {
+		return 0
+	}
+	return time.Duration(2*shortest*float64(MoveCooldownPerTile)) / tripItems
 }
@@ -0,0 +1,134 @@
+package game
+
+import (
+	"github.com/promiseofcake/artifactsmmo-go-client/client"
+	"log"
+	"slices"
+	"sync"
+)
+
+// EffectHandler applies an item effect's value to stats
+type EffectHandler func(stats *Stats, value int)
+
+// defaultEffectHandlers are every effect the game had when this was written. Catalogs start with these.
+var defaultEffectHandlers = map[string]EffectHandler{
+	"hp":      func(s *Stats, v int) { s.Hp = v },
+	"restore": func(s *Stats, v int) { s.Restore = v },
+	"haste":   func(s *Stats, v int) { s.Haste = v },
+
+	"boost_hp":        func(s *Stats, v int) { s.BoostHp = v },
+	"critical_strike": func(s *Stats, v int) { s.CriticalStrike = v },
+	"wisdom":          func(s *Stats, v int) { s.Wisdom = v },
+	"prospecting":     func(s *Stats, v int) { s.Prospecting = v },
+	"inventory_space": func(s *Stats, v int) { s.InventorySpace = v },
+
+	// Tool values are an integer that reduces cooldown time by Value%, so they're negative
+	"woodcutting": func(s *Stats, v int) { s.AttackWoodcutting = -v },
+	"fishing":     func(s *Stats, v int) { s.AttackFishing = -v },
+	"mining":      func(s *Stats, v int) { s.AttackMining = -v },
+	"alchemy":     func(s *Stats, v int) { s.AttackAlchemy = -v },
+
+	"attack_fire":  func(s *Stats, v int) { s.AttackFire = v },
+	"attack_earth": func(s *Stats, v int) { s.AttackEarth = v },
+	"attack_water": func(s *Stats, v int) { s.AttackWater = v },
+	"attack_air":   func(s *Stats, v int) { s.AttackAir = v },
+
+	"dmg_fire":  func(s *Stats, v int) { s.DamageFire = v },
+	"dmg_earth": func(s *Stats, v int) { s.DamageEarth = v },
+	"dmg_water": func(s *Stats, v int) { s.DamageWater = v },
+	"dmg_air":   func(s *Stats, v int) { s.DamageAir = v },
+
+	"res_fire":  func(s *Stats, v int) { s.ResistFire = v },
+	"res_earth": func(s *Stats, v int) { s.ResistEarth = v },
+	"res_water": func(s *Stats, v int) { s.ResistWater = v },
+	"res_air":   func(s *Stats, v int) { s.ResistAir = v },
+
+	// Food boosts are a percentage (0-100) that lasts the whole fight
+	"boost_dmg_fire":  func(s *Stats, v int) { s.BoostDamageFire = v },
+	"boost_dmg_earth": func(s *Stats, v int) { s.BoostDamageEarth = v },
+	"boost_dmg_water": func(s *Stats, v int) { s.BoostDamageWater = v },
+	"boost_dmg_air":   func(s *Stats, v int) { s.BoostDamageAir = v },
+
+	"boost_res_fire":  func(s *Stats, v int) { s.BoostResistFire = v },
+	"boost_res_earth": func(s *Stats, v int) { s.BoostResistEarth = v },
+	"boost_res_water": func(s *Stats, v int) { s.BoostResistWater = v },
+	"boost_res_air":   func(s *Stats, v int) { s.BoostResistAir = v },
+}
+
+// effects are the handlers a catalog builds item stats with, and the effects items had that it couldn't handle
+type effects struct {
+	handlers map[string]EffectHandler
+
+	// unknown maps effect names we have no handler for to the items that have them
+	unknown map[string][]string
+
+	mux sync.RWMutex
+}
+
+func newEffects() *effects {
+	e := &effects{
+		handlers: map[string]EffectHandler{},
+		unknown:  map[string][]string{},
+	}
+	for name, handler := range defaultEffectHandlers {
+		e.handlers[name] = handler
+	}
+	return e
+}
+
+// Register adds or replaces the handler for an effect. Items loaded afterward, e.g. by Catalog.Update, will use it.
+func (e *effects) Register(name string, handler EffectHandler) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	e.handlers[name] = handler
+	delete(e.unknown, name)
+}
+
+// Unknown returns the effects items had that we don't know how to handle, along with the items that had them
+func (e *effects) Unknown() map[string][]string {
+	e.mux.RLock()
+	defer e.mux.RUnlock()
+
+	unknown := map[string][]string{}
+	for name, itemCodes := range e.unknown {
+		unknown[name] = slices.Clone(itemCodes)
+	}
+	return unknown
+}
+
+func (e *effects) apply(stats *Stats, itemCode, name string, value int) {
+	e.mux.RLock()
+	handler, ok := e.handlers[name]
+	e.mux.RUnlock()
+
+	if ok {
+		handler(stats, value)
+		return
+	}
+
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	if _, seen := e.unknown[name]; !seen {
+		log.Printf("Ignoring unrecognized effect %q with value %d on %s\n", name, value, itemCode)
+	}
+	if !slices.Contains(e.unknown[name], itemCode) {
+		e.unknown[name] = append(e.unknown[name], itemCode)
+	}
+}
+
+// statsFromItem is nil for items without effects
+func (e *effects) statsFromItem(itemSchema client.ItemSchema) *Stats {
+	if itemSchema.Effects == nil || len(*itemSchema.Effects) == 0 {
+		return nil
+	}
+
+	stats := &Stats{}
+
+	for _, effect := range *itemSchema.Effects {
+		e.apply(stats, itemSchema.Code, effect.Name, effect.Value)
+	}
+
+	return stats
+}
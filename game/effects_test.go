@@ -0,0 +1,52 @@
+package game
+
+import (
+	"github.com/promiseofcake/artifactsmmo-go-client/client"
+	"reflect"
+	"testing"
+)
+
+func itemWithEffects(code string, effects ...client.ItemEffectSchema) client.ItemSchema {
+	return client.ItemSchema{Code: code, Name: code, Type: "artifact", Level: 1, Effects: &effects}
+}
+
+func TestUnknownEffects(t *testing.T) {
+	data := Data{Items: []client.ItemSchema{
+		itemWithEffects("lucky_charm", client.ItemEffectSchema{Name: "luck", Value: 5}, client.ItemEffectSchema{Name: "hp", Value: 10}),
+		itemWithEffects("four_leaf_clover", client.ItemEffectSchema{Name: "luck", Value: 10}),
+	}}
+
+	catalog, err := NewCatalog(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{"luck": {"lucky_charm", "four_leaf_clover"}}
+	if unknown := catalog.Effects.Unknown(); !reflect.DeepEqual(unknown, want) {
+		t.Errorf("unknown effects = %v, want %v", unknown, want)
+	}
+	if hp := catalog.Items.Get("lucky_charm").Stats.Hp; hp != 10 {
+		t.Errorf("hp = %d, want 10 from the effect we do know", hp)
+	}
+
+	// Registering a handler takes the effect off the unknown list and applies to items loaded afterward
+	catalog.Effects.Register("luck", func(s *Stats, v int) { s.Prospecting = v })
+	if err = catalog.Update(data); err != nil {
+		t.Fatal(err)
+	}
+	if unknown := catalog.Effects.Unknown(); len(unknown) != 0 {
+		t.Errorf("unknown effects = %v after registering luck", unknown)
+	}
+	if prospecting := catalog.Items.Get("four_leaf_clover").Stats.Prospecting; prospecting != 10 {
+		t.Errorf("prospecting = %d, want 10 from the registered handler", prospecting)
+	}
+
+	// Catalogs don't share handlers
+	other, err := NewCatalog(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unknown := other.Effects.Unknown(); !reflect.DeepEqual(unknown, want) {
+		t.Errorf("another catalog's unknown effects = %v, want %v", unknown, want)
+	}
+}
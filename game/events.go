@@ -4,72 +4,121 @@ import (
 	"context"
 	"github.com/ahornerr/artifacts/httperror"
 	"github.com/promiseofcake/artifactsmmo-go-client/client"
+	"slices"
+	"sync"
 )
 
 type events struct {
-	client *client.ClientWithResponses
 	events map[string]map[string][]Location
+
+	// version goes up every time the events change
+	version int
+
+	mux sync.Mutex
 }
 
-func newEvents(c *client.ClientWithResponses) *events {
+func newEvents() *events {
 	return &events{
-		client: c,
 		events: map[string]map[string][]Location{},
 	}
 }
 
 func (e *events) Events() map[string]map[string][]Location {
+	e.mux.Lock()
+	defer e.mux.Unlock()
 	return e.events
 }
 
-func (e *events) load(ctx context.Context) error {
+// Version goes up every time the events change, so it's cheap to tell whether they have
+func (e *events) Version() int {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	return e.version
+}
+
+func fetchEvents(ctx context.Context, c *client.ClientWithResponses) ([]client.ActiveEventSchema, error) {
 	page := 1
 	size := 100
 
-	e.events = map[string]map[string][]Location{}
+	var activeEvents []client.ActiveEventSchema
 
 	for {
-		resp, err := e.client.GetAllEventsEventsGetWithResponse(ctx, &client.GetAllEventsEventsGetParams{
+		resp, err := c.GetAllEventsEventsGetWithResponse(ctx, &client.GetAllEventsEventsGetParams{
 			Page: &page,
 			Size: &size,
 		})
 		if err != nil {
-			return err
+			return nil, err
 		} else if resp.JSON200 == nil {
-			return httperror.NewHTTPError(resp.StatusCode(), resp.Body)
+			return nil, httperror.NewHTTPError(resp.StatusCode(), resp.Body)
 		}
 
-		for _, event := range resp.JSON200.Data {
-			content, err := event.Map.Content.AsMapContentSchema()
-			if err != nil {
-				return err
-			}
+		activeEvents = append(activeEvents, resp.JSON200.Data...)
 
-			if content.Type == "" || content.Code == "" {
-				continue
-			}
+		if len(resp.JSON200.Data) < size {
+			break
+		}
 
-			if _, ok := e.events[content.Type]; !ok {
-				e.events[content.Type] = map[string][]Location{}
-			}
+		page++
+	}
 
-			if _, ok := e.events[content.Type][content.Code]; !ok {
-				e.events[content.Type][content.Code] = []Location{}
-			}
+	return activeEvents, nil
+}
 
-			e.events[content.Type][content.Code] = append(e.events[content.Type][content.Code], Location{
-				Name: content.Code,
-				X:    event.Map.X,
-				Y:    event.Map.Y,
-			})
+func buildEvents(activeEvents []client.ActiveEventSchema) (map[string]map[string][]Location, error) {
+	newEvents := map[string]map[string][]Location{}
+
+	for _, event := range activeEvents {
+		content, err := event.Map.Content.AsMapContentSchema()
+		if err != nil {
+			return nil, err
 		}
 
-		if len(resp.JSON200.Data) < size {
-			break
+		if content.Type == "" || content.Code == "" {
+			continue
 		}
 
-		page++
+		if _, ok := newEvents[content.Type]; !ok {
+			newEvents[content.Type] = map[string][]Location{}
+		}
+
+		if _, ok := newEvents[content.Type][content.Code]; !ok {
+			newEvents[content.Type][content.Code] = []Location{}
+		}
+
+		newEvents[content.Type][content.Code] = append(newEvents[content.Type][content.Code], Location{
+			Name: content.Code,
+			X:    event.Map.X,
+			Y:    event.Map.Y,
+		})
 	}
 
-	return nil
+	return newEvents, nil
+}
+
+func (e *events) set(newEvents map[string]map[string][]Location) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	if !sameEvents(e.events, newEvents) {
+		e.version++
+	}
+	e.events = newEvents
+}
+
+func sameEvents(a, b map[string]map[string][]Location) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for contentType, byCode := range a {
+		if len(byCode) != len(b[contentType]) {
+			return false
+		}
+		for code, locations := range byCode {
+			if !slices.Equal(locations, b[contentType][code]) {
+				return false
+			}
+		}
+	}
+	return true
 }
@@ -2,47 +2,211 @@ package game
 
 import (
 	"context"
-	"github.com/ahornerr/artifacts/client"
-	"log"
+	"encoding/json"
+	"errors"
+	"github.com/ahornerr/artifacts/atomicfile"
+	"github.com/ahornerr/artifacts/periodic"
+	"github.com/promiseofcake/artifactsmmo-go-client/client"
+	"os"
+	"sync"
 	"time"
 )
 
-var gameClient, _ = client.New("")
+// Catalog is all the game data we know about. Build one with LoadCatalog (from the API),
+// LoadCatalogFile (from a file written by Data.WriteFile) or NewCatalog (from fixtures).
+type Catalog struct {
+	Items     *items
+	Monsters  *monsters
+	Resources *resources
+	Maps      *maps
+	Events    *events
+	Effects   *effects
 
-var Items = newItems(gameClient)
-var Maps = newMaps(gameClient) // TODO: May need to reload these occasionally
-var Monsters = newMonsters(gameClient)
-var Resources = newResources(gameClient)
-var Events = newEvents(gameClient)
+	client *client.ClientWithResponses
 
-func init() {
-	ctx := context.Background()
+	// prices is nil unless SetPrices was called
+	prices   Prices
+	goldTime time.Duration
 
-	if err := Items.load(ctx); err != nil {
-		log.Fatal("Loading items failed: ", err)
+	// fightTime is nil unless SetFightTime was called
+	fightTime FightTime
+
+	refresh periodic.Refresher
+	mux     sync.Mutex
+}
+
+// Data is the raw API data a Catalog is built from
+type Data struct {
+	Items     []client.ItemSchema        `json:"items"`
+	Monsters  []client.MonsterSchema     `json:"monsters"`
+	Resources []client.ResourceSchema    `json:"resources"`
+	Maps      []client.MapSchema         `json:"maps"`
+	Events    []client.ActiveEventSchema `json:"events"`
+}
+
+var ErrNoClient = errors.New("catalog has no API client to refresh from")
+
+func NewCatalog(data Data) (*Catalog, error) {
+	effects := newEffects()
+	c := &Catalog{
+		Items:     newItems(effects),
+		Monsters:  newMonsters(),
+		Resources: newResources(),
+		Maps:      newMaps(),
+		Events:    newEvents(),
+		Effects:   effects,
+	}
+
+	if err := c.Update(data); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Update swaps new data into the catalog while it's in use. Everything is built before anything is swapped,
+// and swapped while holding every lock, so readers see either the old data or the new. Items, monsters and
+// resources looked up before the update keep their old values, and drops are keyed by item code so they
+// still work with the new data.
+func (c *Catalog) Update(data Data) error {
+	items, err := c.Items.build(data.Items)
+	if err != nil {
+		return err
+	}
+	monsters, monsterDrops := buildMonsters(data.Monsters)
+	resources, resourceDrops := buildResources(data.Resources)
+	tiles, err := buildMaps(data.Maps, monsters, resources)
+	if err != nil {
+		return err
+	}
+	events, err := buildEvents(data.Events)
+	if err != nil {
+		return err
+	}
+
+	c.Items.mux.Lock()
+	c.Monsters.mux.Lock()
+	c.Resources.mux.Lock()
+	c.Maps.mux.Lock()
+
+	c.Items.items = items
+	c.Monsters.monsters, c.Monsters.drops = monsters, monsterDrops
+	c.Resources.resources, c.Resources.drops = resources, resourceDrops
+	c.Maps.maps = tiles
+
+	c.Maps.mux.Unlock()
+	c.Resources.mux.Unlock()
+	c.Monsters.mux.Unlock()
+	c.Items.mux.Unlock()
+
+	c.Events.set(events)
+	return nil
+}
+
+// FetchData pages through every endpoint the Catalog needs
+func FetchData(ctx context.Context, c *client.ClientWithResponses) (Data, error) {
+	var data Data
+	var err error
+
+	if data.Items, err = fetchItems(ctx, c); err != nil {
+		return Data{}, err
+	}
+	if data.Monsters, err = fetchMonsters(ctx, c); err != nil {
+		return Data{}, err
+	}
+	if data.Resources, err = fetchResources(ctx, c); err != nil {
+		return Data{}, err
+	}
+	if data.Maps, err = fetchMaps(ctx, c); err != nil {
+		return Data{}, err
+	}
+	if data.Events, err = fetchEvents(ctx, c); err != nil {
+		return Data{}, err
+	}
+
+	return data, nil
+}
+
+func LoadCatalog(ctx context.Context, c *client.ClientWithResponses) (*Catalog, error) {
+	data, err := FetchData(ctx, c)
+	if err != nil {
+		return nil, err
 	}
-	if err := Monsters.load(ctx); err != nil {
-		log.Fatal("Loading monsters failed: ", err)
+
+	catalog, err := NewCatalog(data)
+	if err != nil {
+		return nil, err
 	}
-	if err := Resources.load(ctx); err != nil {
-		log.Fatal("Loading resources failed: ", err)
+	catalog.client = c
+
+	return catalog, nil
+}
+
+func LoadCatalogFile(path string) (*Catalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
 	}
-	if err := Maps.load(ctx); err != nil {
-		log.Fatal("Loading maps failed: ", err)
+	defer f.Close()
+
+	var data Data
+	err = json.NewDecoder(f).Decode(&data)
+	if err != nil {
+		return nil, err
 	}
-	if err := Events.load(ctx); err != nil {
-		log.Fatal("Loading events failed: ", err)
+
+	return NewCatalog(data)
+}
+
+func (d Data) WriteFile(path string) error {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return err
 	}
-	go func() {
-		ticker := time.NewTicker(time.Minute)
-		for {
-			<-ticker.C
-			if err := Events.load(ctx); err != nil {
-				log.Fatal("Loading events failed: ", err)
-			}
-			if err := Maps.load(ctx); err != nil {
-				log.Fatal("Loading maps failed: ", err)
-			}
-		}
-	}()
+	return atomicfile.WriteFile(path, b)
+}
+
+// SetClient attaches a client to refresh maps and events from, e.g. for a catalog loaded from a file
+func (c *Catalog) SetClient(client *client.ClientWithResponses) {
+	c.client = client
+}
+
+// Refresh reloads the parts of the game data that change while we're running: maps and events
+func (c *Catalog) Refresh(ctx context.Context) error {
+	if c.client == nil {
+		return ErrNoClient
+	}
+
+	activeEvents, err := fetchEvents(ctx, c.client)
+	if err != nil {
+		return err
+	}
+	events, err := buildEvents(activeEvents)
+	if err != nil {
+		return err
+	}
+	c.Events.set(events)
+
+	mapSchemas, err := fetchMaps(ctx, c.client)
+	if err != nil {
+		return err
+	}
+	tiles, err := buildMaps(mapSchemas, c.Monsters.GetAll(), c.Resources.GetAll())
+	if err != nil {
+		return err
+	}
+
+	c.Maps.mux.Lock()
+	defer c.Maps.mux.Unlock()
+	c.Maps.maps = tiles
+	return nil
+}
+
+// StartRefresh refreshes maps and events every interval until StopRefresh is called or ctx is done
+func (c *Catalog) StartRefresh(ctx context.Context, interval time.Duration) {
+	c.refresh.Start(ctx, interval, "game data", c.Refresh)
+}
+
+func (c *Catalog) StopRefresh() {
+	c.refresh.Stop()
 }
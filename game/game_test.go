@@ -0,0 +1,41 @@
+package game
+
+import (
+	"github.com/promiseofcake/artifactsmmo-go-client/client"
+	"testing"
+)
+
+func TestUpdateKeepsDropsForOldItems(t *testing.T) {
+	data := Data{
+		Items: []client.ItemSchema{{Code: "copper_ore", Name: "Copper Ore", Type: "resource", Level: 1}},
+		Resources: []client.ResourceSchema{{
+			Code:  "copper_rocks",
+			Name:  "Copper Rocks",
+			Skill: "mining",
+			Level: 1,
+			Drops: []client.DropRateSchema{{Code: "copper_ore", Rate: 1, MinQuantity: 1, MaxQuantity: 1}},
+		}},
+	}
+
+	catalog, err := NewCatalog(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := catalog.Items.Get("copper_ore")
+
+	data.Resources[0].Drops[0].Rate = 2
+	if err = catalog.Update(data); err != nil {
+		t.Fatal(err)
+	}
+	if catalog.Items.Get("copper_ore") == old {
+		t.Fatal("update kept the old item")
+	}
+
+	resources := catalog.Resources.ResourcesForItem(old)
+	if len(resources) != 1 {
+		t.Fatalf("resources for an item from before the update = %v, want copper_rocks", resources)
+	}
+	if rate := resources[0].Loot[old.Code].Rate; rate != 2 {
+		t.Errorf("drop rate = %d, want 2 from the update", rate)
+	}
+}
@@ -5,31 +5,37 @@ import (
 	"github.com/ahornerr/artifacts/httperror"
 	"github.com/promiseofcake/artifactsmmo-go-client/client"
 	"slices"
+	"sync"
 )
 
 type items struct {
-	client *client.ClientWithResponses
-	items  map[string]*Item
+	items   map[string]*Item
+	effects *effects
+	mux     sync.RWMutex
 }
 
-func newItems(c *client.ClientWithResponses) *items {
+func newItems(effects *effects) *items {
 	return &items{
-		client: c,
-		items:  map[string]*Item{},
+		items:   map[string]*Item{},
+		effects: effects,
 	}
 }
 
 func (i *items) GetAll() map[string]*Item {
+	i.mux.RLock()
+	defer i.mux.RUnlock()
 	return i.items
 }
 
 func (i *items) Get(itemCode string) *Item {
+	i.mux.RLock()
+	defer i.mux.RUnlock()
 	return i.items[itemCode]
 }
 
 func (i *items) ForTrainingCraftingSkill(skill string, charLevel int) []*Item {
 	var items []*Item
-	for _, item := range i.items {
+	for _, item := range i.GetAll() {
 		if item.Crafting == nil {
 			continue
 		}
@@ -51,7 +57,7 @@ func (i *items) ForTrainingCraftingSkill(skill string, charLevel int) []*Item {
 // ForLevel returns items that are craftable and usable at the supplied combat level
 func (i *items) ForLevel(level int) []*Item {
 	var items []*Item
-	for _, item := range i.items {
+	for _, item := range i.GetAll() {
 		if item.Crafting == nil {
 			continue
 		}
@@ -63,40 +69,24 @@ func (i *items) ForLevel(level int) []*Item {
 	return items
 }
 
-func (i *items) load(ctx context.Context) error {
+func fetchItems(ctx context.Context, c *client.ClientWithResponses) ([]client.ItemSchema, error) {
 	page := 1
 	size := 100
 
-	i.items = map[string]*Item{}
+	var itemSchemas []client.ItemSchema
 
 	for {
-		resp, err := i.client.GetAllItemsItemsGetWithResponse(ctx, &client.GetAllItemsItemsGetParams{
+		resp, err := c.GetAllItemsItemsGetWithResponse(ctx, &client.GetAllItemsItemsGetParams{
 			Page: &page,
 			Size: &size,
 		})
 		if err != nil {
-			return err
+			return nil, err
 		} else if resp.JSON200 == nil {
-			return httperror.NewHTTPError(resp.StatusCode(), resp.Body)
+			return nil, httperror.NewHTTPError(resp.StatusCode(), resp.Body)
 		}
 
-		for _, itemSchema := range resp.JSON200.Data {
-			crafting, err := craftingFromSchema(itemSchema.Craft)
-			if err != nil {
-				return err
-			}
-
-			i.items[itemSchema.Code] = &Item{
-				Code:     itemSchema.Code,
-				Name:     itemSchema.Name,
-				Type:     itemSchema.Type,
-				SubType:  itemSchema.Subtype,
-				Level:    itemSchema.Level,
-				Effects:  itemSchema.Effects,
-				Stats:    StatsFromItem(itemSchema),
-				Crafting: crafting,
-			}
-		}
+		itemSchemas = append(itemSchemas, resp.JSON200.Data...)
 
 		if len(resp.JSON200.Data) < size {
 			break
@@ -105,8 +95,33 @@ func (i *items) load(ctx context.Context) error {
 		page++
 	}
 
+	return itemSchemas, nil
+}
+
+// build creates new items from the schemas without swapping them in, see Catalog.Update
+func (i *items) build(itemSchemas []client.ItemSchema) (map[string]*Item, error) {
+	newItems := map[string]*Item{}
+
+	for _, itemSchema := range itemSchemas {
+		crafting, err := craftingFromSchema(itemSchema.Craft)
+		if err != nil {
+			return nil, err
+		}
+
+		newItems[itemSchema.Code] = &Item{
+			Code:     itemSchema.Code,
+			Name:     itemSchema.Name,
+			Type:     itemSchema.Type,
+			SubType:  itemSchema.Subtype,
+			Level:    itemSchema.Level,
+			Effects:  itemSchema.Effects,
+			Stats:    i.effects.statsFromItem(itemSchema),
+			Crafting: crafting,
+		}
+	}
+
 	// 2 pass approach to populate crafting items
-	for _, item := range i.items {
+	for _, item := range newItems {
 		if item.Crafting == nil {
 			continue
 		}
@@ -114,12 +129,28 @@ func (i *items) load(ctx context.Context) error {
 		craftingItems := map[*Item]int{}
 
 		for craftingItem, quantity := range item.Crafting.Items {
-			resolvedItem := i.items[craftingItem.Code]
+			resolvedItem := newItems[craftingItem.Code]
 			craftingItems[resolvedItem] = quantity
 		}
 
 		item.Crafting.Items = craftingItems
 	}
 
-	return nil
+	return newItems, nil
+}
+
+// AccumulatedStats sums the stats of the equipped item codes, keyed by slot
+func (i *items) AccumulatedStats(itemCodes map[string]string) *Stats {
+	items := map[string]*Item{}
+	for slot, itemCode := range itemCodes {
+		if itemCode == "" {
+			continue
+		}
+		item := i.Get(itemCode)
+		if item == nil {
+			continue
+		}
+		items[slot] = item
+	}
+	return AccumulatedStats(items)
 }
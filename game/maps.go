@@ -9,15 +9,13 @@ import (
 )
 
 type maps struct {
-	client *client.ClientWithResponses
-	maps   map[string]map[string][]Location
-	mux    sync.Mutex
+	maps map[string]map[string][]Location
+	mux  sync.Mutex
 }
 
-func newMaps(c *client.ClientWithResponses) *maps {
+func newMaps() *maps {
 	return &maps{
-		client: c,
-		maps:   map[string]map[string][]Location{},
+		maps: map[string]map[string][]Location{},
 	}
 }
 
@@ -27,6 +25,12 @@ func (m *maps) GetBanks() []Location {
 	return m.maps["bank"]["bank"]
 }
 
+func (m *maps) GetGrandExchanges() []Location {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return m.maps["grand_exchange"]["grand_exchange"]
+}
+
 func (m *maps) GetResources(resourceCode string) []Location {
 	m.mux.Lock()
 	defer m.mux.Unlock()
@@ -51,75 +55,101 @@ func (m *maps) GetTaskMasters(taskType string) []Location {
 	return m.maps["tasks_master"][taskType]
 }
 
-func (m *maps) load(ctx context.Context) error {
+// MonsterAt returns the code of the monster at the location, or empty if there isn't one
+func (m *maps) MonsterAt(location Location) string {
 	m.mux.Lock()
 	defer m.mux.Unlock()
+	for monsterCode, locations := range m.maps["monster"] {
+		for _, l := range locations {
+			if l.X == location.X && l.Y == location.Y {
+				return monsterCode
+			}
+		}
+	}
+	return ""
+}
 
+func fetchMaps(ctx context.Context, c *client.ClientWithResponses) ([]client.MapSchema, error) {
 	page := 1
 	size := 100
 
-	m.maps = map[string]map[string][]Location{}
+	var tiles []client.MapSchema
 
 	for {
-		resp, err := m.client.GetAllMapsMapsGetWithResponse(ctx, &client.GetAllMapsMapsGetParams{
+		resp, err := c.GetAllMapsMapsGetWithResponse(ctx, &client.GetAllMapsMapsGetParams{
 			Page: &page,
 			Size: &size,
 		})
 		if err != nil {
-			return err
+			return nil, err
 		} else if resp.JSON200 == nil {
-			return httperror.NewHTTPError(resp.StatusCode(), resp.Body)
+			return nil, httperror.NewHTTPError(resp.StatusCode(), resp.Body)
 		}
 
-		for _, tile := range resp.JSON200.Data {
-			content, err := tile.Content.AsMapContentSchema()
-			if err != nil {
-				return err
-			}
+		tiles = append(tiles, resp.JSON200.Data...)
 
-			if content.Type == "" || content.Code == "" {
-				continue
-			}
+		if len(resp.JSON200.Data) < size {
+			break
+		}
 
-			if _, ok := m.maps[content.Type]; !ok {
-				m.maps[content.Type] = map[string][]Location{}
-			}
+		page++
+	}
 
-			if _, ok := m.maps[content.Type][content.Code]; !ok {
-				m.maps[content.Type][content.Code] = []Location{}
-			}
+	return tiles, nil
+}
 
-			locationName := ""
-			switch content.Type {
-			case "monster":
-				locationName = Monsters.Get(content.Code).Name
-			case "resource":
-				locationName = Resources.Get(content.Code).Name
-			case "workshop":
-				locationName = fmt.Sprintf("%s workshop", content.Code)
-			case "bank":
-				locationName = "bank"
-			case "grand_exchange":
-				locationName = "grand exchange"
-			case "tasks_master":
-				locationName = fmt.Sprintf("%s task master", content.Code)
-			default:
-				locationName = content.Code
-			}
+// buildMaps groups the tiles by content type and code, naming them after their monster or resource
+func buildMaps(tiles []client.MapSchema, monsters map[string]*Monster, resources map[string]*Resource) (map[string]map[string][]Location, error) {
+	newMaps := map[string]map[string][]Location{}
 
-			m.maps[content.Type][content.Code] = append(m.maps[content.Type][content.Code], Location{
-				Name: locationName,
-				X:    tile.X,
-				Y:    tile.Y,
-			})
+	for _, tile := range tiles {
+		content, err := tile.Content.AsMapContentSchema()
+		if err != nil {
+			return nil, err
 		}
 
-		if len(resp.JSON200.Data) < size {
-			break
+		if content.Type == "" || content.Code == "" {
+			continue
 		}
 
-		page++
+		if _, ok := newMaps[content.Type]; !ok {
+			newMaps[content.Type] = map[string][]Location{}
+		}
+
+		if _, ok := newMaps[content.Type][content.Code]; !ok {
+			newMaps[content.Type][content.Code] = []Location{}
+		}
+
+		locationName := ""
+		switch content.Type {
+		case "monster":
+			locationName = content.Code
+			if monster := monsters[content.Code]; monster != nil {
+				locationName = monster.Name
+			}
+		case "resource":
+			locationName = content.Code
+			if resource := resources[content.Code]; resource != nil {
+				locationName = resource.Name
+			}
+		case "workshop":
+			locationName = fmt.Sprintf("%s workshop", content.Code)
+		case "bank":
+			locationName = "bank"
+		case "grand_exchange":
+			locationName = "grand exchange"
+		case "tasks_master":
+			locationName = fmt.Sprintf("%s task master", content.Code)
+		default:
+			locationName = content.Code
+		}
+
+		newMaps[content.Type][content.Code] = append(newMaps[content.Type][content.Code], Location{
+			Name: locationName,
+			X:    tile.X,
+			Y:    tile.Y,
+		})
 	}
 
-	return nil
+	return newMaps, nil
 }
@@ -7,7 +7,7 @@ type Monster struct {
 	Level   int
 	MaxGold int
 	MinGold int
-	Loot    map[*Item]Drop
+	Loot    map[string]Drop // keyed by item code
 }
 
 func (m Monster) String() string {
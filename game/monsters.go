@@ -4,87 +4,97 @@ import (
 	"context"
 	"github.com/ahornerr/artifacts/httperror"
 	"github.com/promiseofcake/artifactsmmo-go-client/client"
+	"sync"
 )
 
 type monsters struct {
-	client   *client.ClientWithResponses
 	monsters map[string]*Monster
-	drops    map[*Item][]*Monster
+	drops    map[string][]*Monster // keyed by item code so items from before an update still find their monsters
+	mux      sync.RWMutex
 }
 
-func newMonsters(c *client.ClientWithResponses) *monsters {
+func newMonsters() *monsters {
 	return &monsters{
-		client:   c,
 		monsters: map[string]*Monster{},
-		drops:    map[*Item][]*Monster{},
+		drops:    map[string][]*Monster{},
 	}
 }
 
 func (m *monsters) Get(monsterCode string) *Monster {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
 	return m.monsters[monsterCode]
 }
 
 func (m *monsters) GetAll() map[string]*Monster {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
 	return m.monsters
 }
 
 func (m *monsters) MonstersForItem(item *Item) []*Monster {
-	return m.drops[item]
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	return m.drops[item.Code]
 }
 
-func (m *monsters) load(ctx context.Context) error {
+func fetchMonsters(ctx context.Context, c *client.ClientWithResponses) ([]client.MonsterSchema, error) {
 	page := 1
 	size := 100
 
-	m.monsters = map[string]*Monster{}
+	var monsterSchemas []client.MonsterSchema
 
 	for {
-		resp, err := m.client.GetAllMonstersMonstersGetWithResponse(ctx, &client.GetAllMonstersMonstersGetParams{
+		resp, err := c.GetAllMonstersMonstersGetWithResponse(ctx, &client.GetAllMonstersMonstersGetParams{
 			Page: &page,
 			Size: &size,
 		})
 		if err != nil {
-			return err
+			return nil, err
 		} else if resp.JSON200 == nil {
-			return httperror.NewHTTPError(resp.StatusCode(), resp.Body)
+			return nil, httperror.NewHTTPError(resp.StatusCode(), resp.Body)
 		}
 
-		for _, monsterSchema := range resp.JSON200.Data {
-			monster := &Monster{
-				Code:    monsterSchema.Code,
-				Name:    monsterSchema.Name,
-				Stats:   StatsFromMonster(monsterSchema),
-				Level:   monsterSchema.Level,
-				MaxGold: monsterSchema.MaxGold,
-				MinGold: monsterSchema.MinGold,
-				Loot:    map[*Item]Drop{},
-			}
-
-			for _, drop := range monsterSchema.Drops {
-				item := Items.Get(drop.Code)
+		monsterSchemas = append(monsterSchemas, resp.JSON200.Data...)
 
-				monster.Loot[item] = Drop{
-					MaxQuantity: drop.MaxQuantity,
-					MinQuantity: drop.MinQuantity,
-					Rate:        drop.Rate,
-				}
+		if len(resp.JSON200.Data) < size {
+			break
+		}
 
-				if _, ok := m.drops[item]; !ok {
-					m.drops[item] = []*Monster{}
-				}
+		page++
+	}
 
-				m.drops[item] = append(m.drops[item], monster)
-			}
+	return monsterSchemas, nil
+}
 
-			m.monsters[monsterSchema.Code] = monster
+// buildMonsters creates monsters and what they drop, keyed by item code
+func buildMonsters(monsterSchemas []client.MonsterSchema) (map[string]*Monster, map[string][]*Monster) {
+	newMonsters := map[string]*Monster{}
+	drops := map[string][]*Monster{}
+
+	for _, monsterSchema := range monsterSchemas {
+		monster := &Monster{
+			Code:    monsterSchema.Code,
+			Name:    monsterSchema.Name,
+			Stats:   StatsFromMonster(monsterSchema),
+			Level:   monsterSchema.Level,
+			MaxGold: monsterSchema.MaxGold,
+			MinGold: monsterSchema.MinGold,
+			Loot:    map[string]Drop{},
 		}
 
-		if len(resp.JSON200.Data) < size {
-			break
+		for _, drop := range monsterSchema.Drops {
+			monster.Loot[drop.Code] = Drop{
+				MaxQuantity: drop.MaxQuantity,
+				MinQuantity: drop.MinQuantity,
+				Rate:        drop.Rate,
+			}
+
+			drops[drop.Code] = append(drops[drop.Code], monster)
 		}
 
-		page++
+		newMonsters[monsterSchema.Code] = monster
 	}
 
-	return nil
+	return newMonsters, drops
 }
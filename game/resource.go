@@ -3,7 +3,7 @@ package game
 type Resource struct {
 	Code  string
 	Name  string
-	Loot  map[*Item]Drop
+	Loot  map[string]Drop // keyed by item code
 	Skill string
 	Level int
 }
@@ -17,3 +17,9 @@ type Drop struct {
 	MinQuantity int
 	Rate        int
 }
+
+// ExpectedActions is how many gathers or kills it takes on average to get one of the item. A drop rate is 1 in Rate.
+func (d Drop) ExpectedActions() float64 {
+	avgDropQuantity := float64(d.MinQuantity+d.MaxQuantity) / 2.0
+	return float64(max(d.Rate, 1)) / max(avgDropQuantity, 1)
+}
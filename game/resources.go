@@ -5,33 +5,43 @@ import (
 	"github.com/ahornerr/artifacts/httperror"
 	"github.com/promiseofcake/artifactsmmo-go-client/client"
 	"slices"
+	"sync"
 )
 
 type resources struct {
-	client    *client.ClientWithResponses
 	resources map[string]*Resource
-	drops     map[*Item][]*Resource
+	drops     map[string][]*Resource // keyed by item code like monsters.drops
+	mux       sync.RWMutex
 }
 
-func newResources(c *client.ClientWithResponses) *resources {
+func newResources() *resources {
 	return &resources{
-		client:    c,
 		resources: map[string]*Resource{},
-		drops:     map[*Item][]*Resource{},
+		drops:     map[string][]*Resource{},
 	}
 }
 
 func (r *resources) Get(resourceCode string) *Resource {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
 	return r.resources[resourceCode]
 }
 
+func (r *resources) GetAll() map[string]*Resource {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	return r.resources
+}
+
 func (r *resources) ResourcesForItem(item *Item) []*Resource {
-	return r.drops[item]
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	return r.drops[item.Code]
 }
 
 func (r *resources) ResourcesForSkill(skill string, charLevel int) []*Resource {
 	var resources []*Resource
-	for _, resource := range r.resources {
+	for _, resource := range r.GetAll() {
 		if resource.Skill != skill {
 			continue
 		}
@@ -47,58 +57,61 @@ func (r *resources) ResourcesForSkill(skill string, charLevel int) []*Resource {
 	return resources
 }
 
-func (r *resources) load(ctx context.Context) error {
+func fetchResources(ctx context.Context, c *client.ClientWithResponses) ([]client.ResourceSchema, error) {
 	page := 1
 	size := 100
 
-	r.resources = map[string]*Resource{}
-	r.drops = map[*Item][]*Resource{}
+	var resourceSchemas []client.ResourceSchema
 
 	for {
-		resp, err := r.client.GetAllResourcesResourcesGetWithResponse(ctx, &client.GetAllResourcesResourcesGetParams{
+		resp, err := c.GetAllResourcesResourcesGetWithResponse(ctx, &client.GetAllResourcesResourcesGetParams{
 			Page: &page,
 			Size: &size,
 		})
 		if err != nil {
-			return err
+			return nil, err
 		} else if resp.JSON200 == nil {
-			return httperror.NewHTTPError(resp.StatusCode(), resp.Body)
+			return nil, httperror.NewHTTPError(resp.StatusCode(), resp.Body)
 		}
 
-		for _, resourceSchema := range resp.JSON200.Data {
-			resource := &Resource{
-				Code:  resourceSchema.Code,
-				Name:  resourceSchema.Name,
-				Skill: string(resourceSchema.Skill),
-				Level: resourceSchema.Level,
-				Loot:  map[*Item]Drop{},
-			}
-
-			for _, drop := range resourceSchema.Drops {
-				item := Items.Get(drop.Code)
+		resourceSchemas = append(resourceSchemas, resp.JSON200.Data...)
 
-				resource.Loot[item] = Drop{
-					MaxQuantity: drop.MaxQuantity,
-					MinQuantity: drop.MinQuantity,
-					Rate:        drop.Rate,
-				}
+		if len(resp.JSON200.Data) < size {
+			break
+		}
 
-				if _, ok := r.drops[item]; !ok {
-					r.drops[item] = []*Resource{}
-				}
+		page++
+	}
 
-				r.drops[item] = append(r.drops[item], resource)
-			}
+	return resourceSchemas, nil
+}
 
-			r.resources[resource.Code] = resource
+// buildResources creates resources and what they drop, keyed by item code
+func buildResources(resourceSchemas []client.ResourceSchema) (map[string]*Resource, map[string][]*Resource) {
+	newResources := map[string]*Resource{}
+	drops := map[string][]*Resource{}
+
+	for _, resourceSchema := range resourceSchemas {
+		resource := &Resource{
+			Code:  resourceSchema.Code,
+			Name:  resourceSchema.Name,
+			Skill: string(resourceSchema.Skill),
+			Level: resourceSchema.Level,
+			Loot:  map[string]Drop{},
 		}
 
-		if len(resp.JSON200.Data) < size {
-			break
+		for _, drop := range resourceSchema.Drops {
+			resource.Loot[drop.Code] = Drop{
+				MaxQuantity: drop.MaxQuantity,
+				MinQuantity: drop.MinQuantity,
+				Rate:        drop.Rate,
+			}
+
+			drops[drop.Code] = append(drops[drop.Code], resource)
 		}
 
-		page++
+		newResources[resource.Code] = resource
 	}
 
-	return nil
+	return newResources, drops
 }
@@ -0,0 +1,290 @@
+package game
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ahornerr/artifacts/atomicfile"
+	"github.com/promiseofcake/artifactsmmo-go-client/client"
+	"log"
+	"os"
+	"slices"
+	"time"
+)
+
+// Bump this whenever the shape of Data changes so old snapshots get ignored instead of half-loaded
+const snapshotVersion = 1
+
+var ErrSnapshotVersion = errors.New("snapshot version mismatch")
+
+type Snapshot struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	Data    Data      `json:"data"`
+}
+
+func NewSnapshot(data Data) *Snapshot {
+	return &Snapshot{
+		Version: snapshotVersion,
+		Created: time.Now(),
+		Data:    data,
+	}
+}
+
+func LoadSnapshot(path string) (*Snapshot, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot Snapshot
+	err = json.Unmarshal(b, &snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	if snapshot.Version != snapshotVersion {
+		return nil, fmt.Errorf("%w: have %d, want %d", ErrSnapshotVersion, snapshot.Version, snapshotVersion)
+	}
+
+	return &snapshot, nil
+}
+
+// Save writes the snapshot atomically so a crash never leaves a partial snapshot behind
+func (s *Snapshot) Save(path string) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.WriteFile(path, b)
+}
+
+// LoadCatalogCached builds the catalog from the snapshot at path if there is a usable one, with live maps and events
+// since those change too often for a snapshot to be trusted. Everything else is fetched in the background and swapped
+// into the catalog if it changed, logging what did and saving it as the new snapshot.
+// Without a snapshot it loads from the API and writes one.
+func LoadCatalogCached(ctx context.Context, c *client.ClientWithResponses, path string) (*Catalog, error) {
+	snapshot, err := LoadSnapshot(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Println("Ignoring game data snapshot:", err)
+		}
+
+		data, err := FetchData(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = NewSnapshot(data).Save(path); err != nil {
+			log.Println("Saving game data snapshot failed:", err)
+		}
+
+		catalog, err := NewCatalog(data)
+		if err != nil {
+			return nil, err
+		}
+		catalog.client = c
+		return catalog, nil
+	}
+
+	catalog, err := NewCatalog(snapshot.Data)
+	if err != nil {
+		return nil, err
+	}
+	catalog.client = c
+
+	if err = catalog.Refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		data, changes, err := RefreshSnapshot(ctx, c, path, snapshot)
+		if err != nil {
+			log.Println("Refreshing game data snapshot failed:", err)
+			return
+		}
+		if len(changes) == 0 {
+			return
+		}
+
+		log.Printf("Game data changed since %s:\n", snapshot.Created.Format(time.RFC3339))
+		for _, change := range changes {
+			log.Println("  ", change)
+		}
+
+		if err = catalog.Update(data); err != nil {
+			log.Println("Updating game data failed:", err)
+		}
+	}()
+
+	return catalog, nil
+}
+
+// RefreshSnapshot fetches the latest data, saves it over the old snapshot if anything changed and returns it along
+// with the changes
+func RefreshSnapshot(ctx context.Context, c *client.ClientWithResponses, path string, old *Snapshot) (Data, []Change, error) {
+	data, err := FetchData(ctx, c)
+	if err != nil {
+		return Data{}, nil, err
+	}
+
+	changes := DiffData(old.Data, data)
+	if len(changes) == 0 {
+		return data, nil, nil
+	}
+
+	return data, changes, NewSnapshot(data).Save(path)
+}
+
+type Change struct {
+	// Kind is one of item, recipe, monster, resource or drop
+	Kind string
+
+	// Code of the item, monster or resource. For drops, the monster or resource dropping the item.
+	Code string
+
+	// Action is one of added, removed or changed
+	Action string
+
+	Detail string
+}
+
+func (c Change) String() string {
+	if c.Detail == "" {
+		return fmt.Sprintf("%s %s %s", c.Kind, c.Code, c.Action)
+	}
+	return fmt.Sprintf("%s %s %s: %s", c.Kind, c.Code, c.Action, c.Detail)
+}
+
+// DiffData compares the static parts of two data sets: items, recipes, monsters, resources and their drops.
+// Maps and events are expected to change and aren't compared.
+func DiffData(old, new Data) []Change {
+	var changes []Change
+
+	changes = append(changes, diffByCode(old.Items, new.Items, "item", func(i client.ItemSchema) string {
+		return i.Code
+	}, func(a, b client.ItemSchema) []Change {
+		var changes []Change
+		if !jsonEqual(a.Craft, b.Craft) {
+			changes = append(changes, Change{Kind: "recipe", Code: a.Code, Action: "changed"})
+		}
+		a.Craft, b.Craft = nil, nil
+		if !jsonEqual(a, b) {
+			changes = append(changes, Change{Kind: "item", Code: a.Code, Action: "changed"})
+		}
+		return changes
+	})...)
+
+	changes = append(changes, diffByCode(old.Monsters, new.Monsters, "monster", func(m client.MonsterSchema) string {
+		return m.Code
+	}, func(a, b client.MonsterSchema) []Change {
+		changes := diffDrops(a.Code, a.Drops, b.Drops)
+		a.Drops, b.Drops = nil, nil
+		if !jsonEqual(a, b) {
+			changes = append(changes, Change{Kind: "monster", Code: a.Code, Action: "changed"})
+		}
+		return changes
+	})...)
+
+	changes = append(changes, diffByCode(old.Resources, new.Resources, "resource", func(r client.ResourceSchema) string {
+		return r.Code
+	}, func(a, b client.ResourceSchema) []Change {
+		changes := diffDrops(a.Code, a.Drops, b.Drops)
+		a.Drops, b.Drops = nil, nil
+		if !jsonEqual(a, b) {
+			changes = append(changes, Change{Kind: "resource", Code: a.Code, Action: "changed"})
+		}
+		return changes
+	})...)
+
+	return changes
+}
+
+func diffByCode[T any](old, new []T, kind string, code func(T) string, compare func(a, b T) []Change) []Change {
+	var changes []Change
+
+	oldByCode := map[string]T{}
+	for _, o := range old {
+		oldByCode[code(o)] = o
+	}
+	newByCode := map[string]T{}
+	for _, n := range new {
+		newByCode[code(n)] = n
+	}
+
+	for c, o := range oldByCode {
+		n, ok := newByCode[c]
+		if !ok {
+			changes = append(changes, Change{Kind: kind, Code: c, Action: "removed"})
+			continue
+		}
+		changes = append(changes, compare(o, n)...)
+	}
+	for c := range newByCode {
+		if _, ok := oldByCode[c]; !ok {
+			changes = append(changes, Change{Kind: kind, Code: c, Action: "added"})
+		}
+	}
+
+	slices.SortFunc(changes, func(a, b Change) int {
+		if a.Code != b.Code {
+			if a.Code < b.Code {
+				return -1
+			}
+			return 1
+		}
+		if a.Kind < b.Kind {
+			return -1
+		}
+		if a.Kind > b.Kind {
+			return 1
+		}
+		return 0
+	})
+
+	return changes
+}
+
+func diffDrops(code string, old, new []client.DropRateSchema) []Change {
+	var changes []Change
+
+	oldDrops := map[string]client.DropRateSchema{}
+	for _, drop := range old {
+		oldDrops[drop.Code] = drop
+	}
+
+	for _, n := range new {
+		o, ok := oldDrops[n.Code]
+		delete(oldDrops, n.Code)
+		if !ok {
+			changes = append(changes, Change{Kind: "drop", Code: code, Action: "added", Detail: n.Code})
+			continue
+		}
+		if o != n {
+			changes = append(changes, Change{
+				Kind:   "drop",
+				Code:   code,
+				Action: "changed",
+				Detail: fmt.Sprintf("%s rate 1/%d -> 1/%d, quantity %d-%d -> %d-%d", n.Code, o.Rate, n.Rate, o.MinQuantity, o.MaxQuantity, n.MinQuantity, n.MaxQuantity),
+			})
+		}
+	}
+
+	for itemCode := range oldDrops {
+		changes = append(changes, Change{Kind: "drop", Code: code, Action: "removed", Detail: itemCode})
+	}
+
+	return changes
+}
+
+func jsonEqual(a, b interface{}) bool {
+	aj, errA := json.Marshal(a)
+	bj, errB := json.Marshal(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return bytes.Equal(aj, bj)
+}
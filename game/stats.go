@@ -2,45 +2,66 @@ package game
 
 import (
 	"github.com/promiseofcake/artifactsmmo-go-client/client"
-	"log"
 	"math"
-	"strings"
 )
 
+// Stats are plain ints. A full set of end-game gear sums to well over what the smaller types we used to use can hold.
 type Stats struct {
-	Hp      uint16
-	Restore int8
-	Haste   int8
-	BoostHp int8
-
-	AttackFire  int8
-	AttackWater int8
-	AttackEarth int8
-	AttackAir   int8
-
-	AttackWoodcutting int8
-	AttackMining      int8
-	AttackFishing     int8
-
-	ResistFire  int8
-	ResistWater int8
-	ResistEarth int8
-	ResistAir   int8
-
-	ResistWoodcutting int8
-	ResistMining      int8
-	ResistFishing     int8
-
-	DamageFire  int8
-	DamageWater int8
-	DamageEarth int8
-	DamageAir   int8
+	Hp      int
+	Restore int
+	Haste   int
+	BoostHp int
+
+	AttackFire  int
+	AttackWater int
+	AttackEarth int
+	AttackAir   int
+
+	AttackWoodcutting int
+	AttackMining      int
+	AttackFishing     int
+	AttackAlchemy     int
+
+	ResistFire  int
+	ResistWater int
+	ResistEarth int
+	ResistAir   int
+
+	ResistWoodcutting int
+	ResistMining      int
+	ResistFishing     int
+	ResistAlchemy     int
+
+	DamageFire  int
+	DamageWater int
+	DamageEarth int
+	DamageAir   int
+
+	// BoostDamage comes from food and is a percentage added to Damage for the whole fight
+	BoostDamageFire  int
+	BoostDamageWater int
+	BoostDamageEarth int
+	BoostDamageAir   int
+
+	// BoostResist also comes from food and adds to Resist for the whole fight
+	BoostResistFire  int
+	BoostResistWater int
+	BoostResistEarth int
+	BoostResistAir   int
+
+	// CriticalStrike is the percent chance for a turn's attacks to deal 50% more damage
+	CriticalStrike int
+
+	Wisdom         int
+	Prospecting    int
+	InventorySpace int
 
 	IsResource bool
-
-	// TODO: BoostDamage for each element
 }
 
+// Elements in the order the API resolves attacks within a turn
+var Elements = []string{"fire", "earth", "water", "air"}
+
 func (s *Stats) Add(other *Stats) {
 	s.Hp += other.Hp
 	s.Restore += other.Restore
@@ -55,6 +76,7 @@ func (s *Stats) Add(other *Stats) {
 	s.AttackWoodcutting += other.AttackWoodcutting
 	s.AttackFishing += other.AttackFishing
 	s.AttackMining += other.AttackMining
+	s.AttackAlchemy += other.AttackAlchemy
 
 	s.ResistFire += other.ResistFire
 	s.ResistWater += other.ResistWater
@@ -64,34 +86,207 @@ func (s *Stats) Add(other *Stats) {
 	s.ResistWoodcutting += other.ResistWoodcutting
 	s.ResistFishing += other.ResistFishing
 	s.ResistMining += other.ResistMining
+	s.ResistAlchemy += other.ResistAlchemy
 
 	s.DamageFire += other.DamageFire
 	s.DamageWater += other.DamageWater
 	s.DamageEarth += other.DamageEarth
 	s.DamageAir += other.DamageAir
+
+	s.BoostDamageFire += other.BoostDamageFire
+	s.BoostDamageWater += other.BoostDamageWater
+	s.BoostDamageEarth += other.BoostDamageEarth
+	s.BoostDamageAir += other.BoostDamageAir
+
+	s.BoostResistFire += other.BoostResistFire
+	s.BoostResistWater += other.BoostResistWater
+	s.BoostResistEarth += other.BoostResistEarth
+	s.BoostResistAir += other.BoostResistAir
+
+	s.CriticalStrike += other.CriticalStrike
+
+	s.Wisdom += other.Wisdom
+	s.Prospecting += other.Prospecting
+	s.InventorySpace += other.InventorySpace
 }
 
-func AccumulatedStats(items map[string]*Item) *Stats {
-	accumulated := &Stats{}
-	for _, item := range items {
-		accumulated.Add(item.Stats)
+// Max raises every stat to other's value where other's is higher.
+// Every int stat is better for whoever has it when it's higher, so this and Dominates can go field by field.
+// They're in the optimizer's inner loop, which is why they spell out every field instead of using reflection.
+func (s *Stats) Max(other *Stats) {
+	s.Hp = max(s.Hp, other.Hp)
+	s.Restore = max(s.Restore, other.Restore)
+	s.Haste = max(s.Haste, other.Haste)
+	s.BoostHp = max(s.BoostHp, other.BoostHp)
+	s.AttackFire = max(s.AttackFire, other.AttackFire)
+	s.AttackWater = max(s.AttackWater, other.AttackWater)
+	s.AttackEarth = max(s.AttackEarth, other.AttackEarth)
+	s.AttackAir = max(s.AttackAir, other.AttackAir)
+	s.AttackWoodcutting = max(s.AttackWoodcutting, other.AttackWoodcutting)
+	s.AttackMining = max(s.AttackMining, other.AttackMining)
+	s.AttackFishing = max(s.AttackFishing, other.AttackFishing)
+	s.AttackAlchemy = max(s.AttackAlchemy, other.AttackAlchemy)
+	s.ResistFire = max(s.ResistFire, other.ResistFire)
+	s.ResistWater = max(s.ResistWater, other.ResistWater)
+	s.ResistEarth = max(s.ResistEarth, other.ResistEarth)
+	s.ResistAir = max(s.ResistAir, other.ResistAir)
+	s.ResistWoodcutting = max(s.ResistWoodcutting, other.ResistWoodcutting)
+	s.ResistMining = max(s.ResistMining, other.ResistMining)
+	s.ResistFishing = max(s.ResistFishing, other.ResistFishing)
+	s.ResistAlchemy = max(s.ResistAlchemy, other.ResistAlchemy)
+	s.DamageFire = max(s.DamageFire, other.DamageFire)
+	s.DamageWater = max(s.DamageWater, other.DamageWater)
+	s.DamageEarth = max(s.DamageEarth, other.DamageEarth)
+	s.DamageAir = max(s.DamageAir, other.DamageAir)
+	s.BoostDamageFire = max(s.BoostDamageFire, other.BoostDamageFire)
+	s.BoostDamageWater = max(s.BoostDamageWater, other.BoostDamageWater)
+	s.BoostDamageEarth = max(s.BoostDamageEarth, other.BoostDamageEarth)
+	s.BoostDamageAir = max(s.BoostDamageAir, other.BoostDamageAir)
+	s.BoostResistFire = max(s.BoostResistFire, other.BoostResistFire)
+	s.BoostResistWater = max(s.BoostResistWater, other.BoostResistWater)
+	s.BoostResistEarth = max(s.BoostResistEarth, other.BoostResistEarth)
+	s.BoostResistAir = max(s.BoostResistAir, other.BoostResistAir)
+	s.CriticalStrike = max(s.CriticalStrike, other.CriticalStrike)
+	s.Wisdom = max(s.Wisdom, other.Wisdom)
+	s.Prospecting = max(s.Prospecting, other.Prospecting)
+	s.InventorySpace = max(s.InventorySpace, other.InventorySpace)
+}
+
+// Dominates is true when every stat is at least as high as other's
+func (s *Stats) Dominates(other *Stats) bool {
+	return s.Hp >= other.Hp &&
+		s.Restore >= other.Restore &&
+		s.Haste >= other.Haste &&
+		s.BoostHp >= other.BoostHp &&
+		s.AttackFire >= other.AttackFire &&
+		s.AttackWater >= other.AttackWater &&
+		s.AttackEarth >= other.AttackEarth &&
+		s.AttackAir >= other.AttackAir &&
+		s.AttackWoodcutting >= other.AttackWoodcutting &&
+		s.AttackMining >= other.AttackMining &&
+		s.AttackFishing >= other.AttackFishing &&
+		s.AttackAlchemy >= other.AttackAlchemy &&
+		s.ResistFire >= other.ResistFire &&
+		s.ResistWater >= other.ResistWater &&
+		s.ResistEarth >= other.ResistEarth &&
+		s.ResistAir >= other.ResistAir &&
+		s.ResistWoodcutting >= other.ResistWoodcutting &&
+		s.ResistMining >= other.ResistMining &&
+		s.ResistFishing >= other.ResistFishing &&
+		s.ResistAlchemy >= other.ResistAlchemy &&
+		s.DamageFire >= other.DamageFire &&
+		s.DamageWater >= other.DamageWater &&
+		s.DamageEarth >= other.DamageEarth &&
+		s.DamageAir >= other.DamageAir &&
+		s.BoostDamageFire >= other.BoostDamageFire &&
+		s.BoostDamageWater >= other.BoostDamageWater &&
+		s.BoostDamageEarth >= other.BoostDamageEarth &&
+		s.BoostDamageAir >= other.BoostDamageAir &&
+		s.BoostResistFire >= other.BoostResistFire &&
+		s.BoostResistWater >= other.BoostResistWater &&
+		s.BoostResistEarth >= other.BoostResistEarth &&
+		s.BoostResistAir >= other.BoostResistAir &&
+		s.CriticalStrike >= other.CriticalStrike &&
+		s.Wisdom >= other.Wisdom &&
+		s.Prospecting >= other.Prospecting &&
+		s.InventorySpace >= other.InventorySpace
+}
+
+// HasBoost is true for food with effects that last the whole fight
+func (s *Stats) HasBoost() bool {
+	return s.BoostHp != 0 ||
+		s.BoostDamageFire != 0 || s.BoostDamageEarth != 0 || s.BoostDamageWater != 0 || s.BoostDamageAir != 0 ||
+		s.BoostResistFire != 0 || s.BoostResistEarth != 0 || s.BoostResistWater != 0 || s.BoostResistAir != 0
+}
+
+// AddBoosts adds only the whole-fight boosts from other, e.g. from food eaten at the start of a fight
+func (s *Stats) AddBoosts(other *Stats) {
+	s.BoostHp += other.BoostHp
+
+	s.BoostDamageFire += other.BoostDamageFire
+	s.BoostDamageWater += other.BoostDamageWater
+	s.BoostDamageEarth += other.BoostDamageEarth
+	s.BoostDamageAir += other.BoostDamageAir
+
+	s.BoostResistFire += other.BoostResistFire
+	s.BoostResistWater += other.BoostResistWater
+	s.BoostResistEarth += other.BoostResistEarth
+	s.BoostResistAir += other.BoostResistAir
+}
+
+func (s *Stats) Attack(element string) int {
+	switch element {
+	case "fire":
+		return s.AttackFire
+	case "earth":
+		return s.AttackEarth
+	case "water":
+		return s.AttackWater
+	case "air":
+		return s.AttackAir
 	}
-	return accumulated
+	return 0
 }
 
-func AccumulatedStatsItemCodes(itemCodes map[string]string) *Stats {
-	items := map[string]*Item{}
-	for slot, itemCode := range itemCodes {
-		if itemCode == "" {
-			continue
-		}
-		item := Items.Get(itemCode)
-		if item == nil {
-			continue
+// Resist is the percentage resistance to an element, including any boost from food
+func (s *Stats) Resist(element string) int {
+	switch element {
+	case "fire":
+		return s.ResistFire + s.BoostResistFire
+	case "earth":
+		return s.ResistEarth + s.BoostResistEarth
+	case "water":
+		return s.ResistWater + s.BoostResistWater
+	case "air":
+		return s.ResistAir + s.BoostResistAir
+	}
+	return 0
+}
+
+// Damage is the percentage bonus to an element's attack, including any boost from food
+func (s *Stats) Damage(element string) int {
+	switch element {
+	case "fire":
+		return s.DamageFire + s.BoostDamageFire
+	case "earth":
+		return s.DamageEarth + s.BoostDamageEarth
+	case "water":
+		return s.DamageWater + s.BoostDamageWater
+	case "air":
+		return s.DamageAir + s.BoostDamageAir
+	}
+	return 0
+}
+
+// BlockChance is the chance (0-1) that other blocks an attack of this element
+func (s *Stats) BlockChance(element string) float64 {
+	return BlockChance(s.Resist(element))
+}
+
+// BlockChance is the chance (0-1) that an attack is blocked by resist% resistance. Each 1% of resistance is a 0.1% chance.
+func BlockChance(resist int) float64 {
+	return max(0, float64(resist)/1000.0)
+}
+
+// ElementHit is the damage an attack does when it isn't blocked, with damage% bonus and resist% resistance
+func ElementHit(attack, damage, resist int) int {
+	return roundToInt(elementHit(attack, damage, resist))
+}
+
+func elementHit(attack, damage, resist int) float64 {
+	return float64(attack) *
+		(1 + float64(damage)/100.0) *
+		(1 - float64(resist)/100.0)
+}
+
+func AccumulatedStats(items map[string]*Item) *Stats {
+	accumulated := &Stats{}
+	for _, item := range items {
+		if item != nil && item.Stats != nil {
+			accumulated.Add(item.Stats)
 		}
-		items[slot] = item
 	}
-	return AccumulatedStats(items)
+	return accumulated
 }
 
 func roundToInt(x float64) int {
@@ -119,133 +314,60 @@ func (s Stats) GetDamageAgainst(other *Stats) int {
 				(1 - float64(other.ResistFishing)/100.0) *
 				(1 - float64(other.ResistFishing)/1000.0))
 		}
-	} else {
-		if s.AttackAir > 0 {
-			totalDamage += roundToInt(float64(s.AttackAir) *
-				(1 + float64(s.DamageAir)/100.0) *
-				(1 - float64(other.ResistAir)/100.0) *
-				(1 - float64(other.ResistAir)/1000.0))
-		}
-
-		if s.AttackFire > 0 {
-			totalDamage += roundToInt(float64(s.AttackFire) *
-				(1 + float64(s.DamageFire)/100.0) *
-				(1 - float64(other.ResistFire)/100.0) *
-				(1 - float64(other.ResistFire)/1000.0))
-		}
 
-		if s.AttackWater > 0 {
-			totalDamage += roundToInt(float64(s.AttackWater) *
-				(1 + float64(s.DamageWater)/100.0) *
-				(1 - float64(other.ResistWater)/100.0) *
-				(1 - float64(other.ResistWater)/1000.0))
+		if s.AttackAlchemy > 0 && other.ResistAlchemy < 0 {
+			totalDamage += roundToInt(float64(s.AttackAlchemy) *
+				(1 - float64(other.ResistAlchemy)/100.0) *
+				(1 - float64(other.ResistAlchemy)/1000.0))
 		}
-
-		if s.AttackEarth > 0 {
-			totalDamage += roundToInt(float64(s.AttackEarth) *
-				(1 + float64(s.DamageEarth)/100.0) *
-				(1 - float64(other.ResistEarth)/100.0) *
-				(1 - float64(other.ResistEarth)/1000.0))
+	} else {
+		for _, element := range Elements {
+			totalDamage += s.ElementDamageAgainst(element, other)
 		}
 	}
 
 	return totalDamage
 }
 
-func StatsFromMonster(monsterSchema client.MonsterSchema) *Stats {
-	return &Stats{
-		Hp: uint16(monsterSchema.Hp),
+// ElementDamageAgainst is the average damage of a non-critical attack of one element, with blocks already accounted
+// for by (1 - resist/1000)
+func (s Stats) ElementDamageAgainst(element string, other *Stats) int {
+	attack := s.Attack(element)
+	if attack <= 0 {
+		return 0
+	}
 
-		AttackFire:  int8(monsterSchema.AttackFire),
-		AttackWater: int8(monsterSchema.AttackWater),
-		AttackEarth: int8(monsterSchema.AttackEarth),
-		AttackAir:   int8(monsterSchema.AttackAir),
+	resist := other.Resist(element)
+	return roundToInt(elementHit(attack, s.Damage(element), resist) *
+		(1 - float64(resist)/1000.0))
+}
 
-		ResistFire:  int8(monsterSchema.ResFire),
-		ResistWater: int8(monsterSchema.ResWater),
-		ResistEarth: int8(monsterSchema.ResEarth),
-		ResistAir:   int8(monsterSchema.ResAir),
+// ElementHitAgainst is the damage of a non-critical attack of one element that isn't blocked
+func (s Stats) ElementHitAgainst(element string, other *Stats) int {
+	attack := s.Attack(element)
+	if attack <= 0 {
+		return 0
 	}
+
+	return ElementHit(attack, s.Damage(element), other.Resist(element))
 }
 
-func StatsFromItem(itemSchema client.ItemSchema) *Stats {
-	if itemSchema.Effects == nil || len(*itemSchema.Effects) == 0 {
-		return nil
-	}
+func StatsFromMonster(monsterSchema client.MonsterSchema) *Stats {
+	return &Stats{
+		Hp: monsterSchema.Hp,
 
-	stats := &Stats{}
-
-	for _, effect := range *itemSchema.Effects {
-		value := int8(effect.Value)
-		switch {
-		case effect.Name == "hp":
-			stats.Hp = uint16(effect.Value)
-		case effect.Name == "restore":
-			stats.Restore = value
-		case effect.Name == "boost_hp":
-			stats.BoostHp = value
-		case effect.Name == "haste":
-			// For food
-			stats.Haste = value
-		case effect.Name == "woodcutting":
-			// Value is an integer that reduces cooldown time by Value%
-			stats.AttackWoodcutting = int8(-effect.Value)
-		case effect.Name == "fishing":
-			stats.AttackFishing = int8(-effect.Value)
-		case effect.Name == "mining":
-			stats.AttackMining = int8(-effect.Value)
-		case strings.HasPrefix(effect.Name, "dmg_"):
-			element := strings.TrimPrefix(effect.Name, "dmg_")
-			switch element {
-			case "air":
-				stats.DamageAir = value
-			case "water":
-				stats.DamageWater = value
-			case "fire":
-				stats.DamageFire = value
-			case "earth":
-				stats.DamageEarth = value
-			default:
-				panic(element)
-			}
-		case strings.HasPrefix(effect.Name, "attack_"):
-			element := strings.TrimPrefix(effect.Name, "attack_")
-			switch element {
-			case "air":
-				stats.AttackAir = value
-			case "water":
-				stats.AttackWater = value
-			case "fire":
-				stats.AttackFire = value
-			case "earth":
-				stats.AttackEarth = value
-			default:
-				panic(element)
-			}
-		case strings.HasPrefix(effect.Name, "res_"):
-			element := strings.TrimPrefix(effect.Name, "res_")
-			switch element {
-			case "air":
-				stats.ResistAir = value
-			case "water":
-				stats.ResistWater = value
-			case "fire":
-				stats.ResistFire = value
-			case "earth":
-				stats.ResistEarth = value
-			default:
-				panic(element)
-			}
-		case strings.HasPrefix(effect.Name, "boost_dmg_"):
-			// Value is a percentage (0-100), comes from food
-			//element := strings.TrimPrefix(effect.Name, "boost_dmg_")
-			//stats.BoostDamage[element] = effect.Value
-		default:
-			log.Fatal("Unrecognized effect named ", effect.Name, " with value ", value)
-		}
-	}
+		AttackFire:  monsterSchema.AttackFire,
+		AttackWater: monsterSchema.AttackWater,
+		AttackEarth: monsterSchema.AttackEarth,
+		AttackAir:   monsterSchema.AttackAir,
+
+		ResistFire:  monsterSchema.ResFire,
+		ResistWater: monsterSchema.ResWater,
+		ResistEarth: monsterSchema.ResEarth,
+		ResistAir:   monsterSchema.ResAir,
 
-	return stats
+		CriticalStrike: monsterSchema.CriticalStrike,
+	}
 }
 
 //func (s Stats) GetAttacks() map[string]float64 {
@@ -0,0 +1,150 @@
+package game
+
+import (
+	"github.com/promiseofcake/artifactsmmo-go-client/client"
+	"reflect"
+	"testing"
+)
+
+func effect(name string, value int) client.ItemEffectSchema {
+	return client.ItemEffectSchema{Name: name, Value: value}
+}
+
+func gearItem(code, itemType string, effects ...client.ItemEffectSchema) client.ItemSchema {
+	return client.ItemSchema{Code: code, Name: code, Type: itemType, Level: 40, Effects: &effects}
+}
+
+// endGameCatalog has a full set of level 40 gear. Damage and HP both add up to well past what an int8 holds.
+func endGameCatalog(t *testing.T) (*Catalog, map[string]string) {
+	t.Helper()
+
+	catalog, err := NewCatalog(Data{
+		Items: []client.ItemSchema{
+			gearItem("abyssal_sword", "weapon", effect("attack_fire", 45), effect("attack_water", 45), effect("critical_strike", 5)),
+			gearItem("gold_shield", "shield", effect("res_fire", 15), effect("res_earth", 15), effect("res_water", 15), effect("res_air", 15)),
+			gearItem("lich_crown", "helmet", effect("hp", 90), effect("dmg_fire", 20), effect("dmg_water", 20)),
+			gearItem("royal_platebody", "body_armor", effect("hp", 120), effect("dmg_fire", 25), effect("dmg_water", 25), effect("res_earth", 10)),
+			gearItem("royal_legs", "leg_armor", effect("hp", 110), effect("dmg_fire", 22), effect("dmg_water", 22)),
+			gearItem("royal_boots", "boots", effect("hp", 80), effect("dmg_fire", 15), effect("dmg_water", 15), effect("haste", 8)),
+			gearItem("ruby_ring", "ring", effect("dmg_fire", 15), effect("dmg_water", 15), effect("critical_strike", 5)),
+			gearItem("dragon_amulet", "amulet", effect("hp", 60), effect("dmg_fire", 20), effect("dmg_water", 20), effect("wisdom", 60), effect("prospecting", 40)),
+			gearItem("fire_totem", "artifact", effect("dmg_fire", 10), effect("dmg_water", 10), effect("wisdom", 25)),
+			gearItem("water_totem", "artifact", effect("dmg_fire", 10), effect("dmg_water", 10), effect("wisdom", 25)),
+			gearItem("sage_totem", "artifact", effect("dmg_fire", 10), effect("dmg_water", 10), effect("wisdom", 25)),
+		},
+		Monsters: []client.MonsterSchema{
+			{Code: "lich", Name: "Lich", Level: 40, Hp: 5000, AttackEarth: 150, ResFire: 30, ResWater: 30, CriticalStrike: 10},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	equipment := map[string]string{
+		"weapon":     "abyssal_sword",
+		"shield":     "gold_shield",
+		"helmet":     "lich_crown",
+		"body_armor": "royal_platebody",
+		"leg_armor":  "royal_legs",
+		"boots":      "royal_boots",
+		"ring1":      "ruby_ring",
+		"ring2":      "ruby_ring",
+		"amulet":     "dragon_amulet",
+		"artifact1":  "fire_totem",
+		"artifact2":  "water_totem",
+		"artifact3":  "sage_totem",
+	}
+
+	return catalog, equipment
+}
+
+func TestAccumulatedStatsEndGameGear(t *testing.T) {
+	catalog, equipment := endGameCatalog(t)
+
+	stats := catalog.Items.AccumulatedStats(equipment)
+
+	for _, tc := range []struct {
+		name      string
+		got, want int
+	}{
+		{"hp", stats.Hp, 460},
+		{"attack fire", stats.AttackFire, 45},
+		{"dmg fire", stats.DamageFire, 162},
+		{"dmg water", stats.DamageWater, 162},
+		{"res earth", stats.ResistEarth, 25},
+		{"critical strike", stats.CriticalStrike, 15},
+		{"haste", stats.Haste, 8},
+		{"wisdom", stats.Wisdom, 135},
+		{"prospecting", stats.Prospecting, 40},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("%s = %d, want %d", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestDamageEndGameGear(t *testing.T) {
+	catalog, equipment := endGameCatalog(t)
+
+	player := catalog.Items.AccumulatedStats(equipment)
+	monster := catalog.Monsters.Get("lich").Stats
+
+	if monster.CriticalStrike != 10 {
+		t.Errorf("monster critical strike = %d, want 10", monster.CriticalStrike)
+	}
+
+	// 45 * (1 + 162/100) * (1 - 30/100) * (1 - 30/1000) = 80.05
+	if damage := player.ElementDamageAgainst("fire", monster); damage != 80 {
+		t.Errorf("fire damage = %d, want 80", damage)
+	}
+	if damage := player.GetDamageAgainst(monster); damage != 160 {
+		t.Errorf("total damage = %d, want 160 from fire and water", damage)
+	}
+
+	// 150 * (1 - 25/100) = 112.5 when it isn't blocked, and 2.5% of hits are
+	if hit := monster.ElementHitAgainst("earth", player); hit != 113 {
+		t.Errorf("monster earth hit = %d, want 113", hit)
+	}
+	if damage := monster.ElementDamageAgainst("earth", player); damage != 110 {
+		t.Errorf("monster earth damage = %d, want 110", damage)
+	}
+	if chance := player.BlockChance("earth"); chance != 0.025 {
+		t.Errorf("block chance = %f, want 0.025", chance)
+	}
+}
+
+func TestAddDoesNotWrap(t *testing.T) {
+	catalog, _ := endGameCatalog(t)
+	ring := catalog.Items.Get("ruby_ring").Stats
+
+	total := &Stats{}
+	for i := 0; i < 10000; i++ {
+		total.Add(ring)
+	}
+
+	if total.DamageFire != 150000 || total.CriticalStrike != 50000 {
+		t.Errorf("10000 rings add up to %d dmg fire and %d critical strike, want 150000 and 50000", total.DamageFire, total.CriticalStrike)
+	}
+}
+
+// Max and Dominates list every field by hand, this catches a new stat that isn't in them
+func TestMaxAndDominatesCoverEveryField(t *testing.T) {
+	fields := reflect.TypeOf(Stats{})
+	for i := 0; i < fields.NumField(); i++ {
+		if fields.Field(i).Type.Kind() != reflect.Int {
+			continue
+		}
+
+		higher := &Stats{}
+		reflect.ValueOf(higher).Elem().Field(i).SetInt(5)
+
+		s := &Stats{}
+		if s.Dominates(higher) {
+			t.Errorf("Dominates ignores %s", fields.Field(i).Name)
+		}
+		s.Max(higher)
+		if reflect.ValueOf(s).Elem().Field(i).Int() != 5 {
+			t.Errorf("Max ignores %s", fields.Field(i).Name)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package ge
+
+import (
+	"context"
+	"github.com/ahornerr/artifacts/httperror"
+	"github.com/ahornerr/artifacts/periodic"
+	"github.com/promiseofcake/artifactsmmo-go-client/client"
+	"sync"
+	"time"
+)
+
+// Price is what the grand exchange pays for an item and what it charges, per item.
+// Zero means it isn't buying or selling.
+type Price struct {
+	Code      string
+	Stock     int
+	BuyPrice  int
+	SellPrice int
+
+	// MaxQuantity is the most that can be bought or sold in one transaction
+	MaxQuantity int
+}
+
+// Exchange keeps the latest grand exchange prices
+type Exchange struct {
+	prices map[string]Price
+
+	client  *client.ClientWithResponses
+	refresh periodic.Refresher
+	mux     sync.Mutex
+}
+
+func NewExchange(c *client.ClientWithResponses) *Exchange {
+	return &Exchange{
+		prices: map[string]Price{},
+		client: c,
+	}
+}
+
+func (e *Exchange) Load(ctx context.Context) error {
+	page := 1
+	size := 100
+
+	prices := map[string]Price{}
+
+	for {
+		resp, err := e.client.GetAllGeItemsGeGetWithResponse(ctx, &client.GetAllGeItemsGeGetParams{
+			Page: &page,
+			Size: &size,
+		})
+		if err != nil {
+			return err
+		} else if resp.JSON200 == nil {
+			return httperror.NewHTTPError(resp.StatusCode(), resp.Body)
+		}
+
+		for _, geItem := range resp.JSON200.Data {
+			price := Price{
+				Code:        geItem.Code,
+				Stock:       geItem.Stock,
+				MaxQuantity: geItem.MaxQuantity,
+			}
+			if geItem.BuyPrice != nil {
+				price.BuyPrice = *geItem.BuyPrice
+			}
+			if geItem.SellPrice != nil {
+				price.SellPrice = *geItem.SellPrice
+			}
+			prices[geItem.Code] = price
+		}
+
+		if len(resp.JSON200.Data) < size {
+			break
+		}
+
+		page++
+	}
+
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	e.prices = prices
+
+	return nil
+}
+
+func (e *Exchange) Price(itemCode string) (Price, bool) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	price, ok := e.prices[itemCode]
+	return price, ok
+}
+
+// BuyPrice is what it costs to buy one of the item, if the grand exchange has any to sell
+func (e *Exchange) BuyPrice(itemCode string) (int, bool) {
+	price, ok := e.Price(itemCode)
+	if !ok || price.BuyPrice <= 0 || price.Stock <= 0 {
+		return 0, false
+	}
+	return price.BuyPrice, true
+}
+
+// SellPrice is what the grand exchange pays for one of the item, if it buys them
+func (e *Exchange) SellPrice(itemCode string) (int, bool) {
+	price, ok := e.Price(itemCode)
+	if !ok || price.SellPrice <= 0 {
+		return 0, false
+	}
+	return price.SellPrice, true
+}
+
+// StartRefresh reloads prices every interval until StopRefresh is called or ctx is done
+func (e *Exchange) StartRefresh(ctx context.Context, interval time.Duration) {
+	e.refresh.Start(ctx, interval, "grand exchange prices", e.Load)
+}
+
+func (e *Exchange) StopRefresh() {
+	e.refresh.Stop()
+}
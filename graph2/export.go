@@ -0,0 +1,207 @@
+package graph2
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ahornerr/artifacts/game"
+	"io"
+	"slices"
+	"strings"
+)
+
+const (
+	FormatDOT     = "dot"
+	FormatMermaid = "mermaid"
+	FormatJSON    = "json"
+)
+
+var ErrUnknownFormat = errors.New("unknown format, want dot, mermaid or json")
+
+// Tree is an item along with what it's crafted from and what drops it, all the way down
+type Tree struct {
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+
+	// Owned is only set when the tree was built with what we own
+	Owned *int `json:"owned,omitempty"`
+
+	// Skill and Level are what it takes to craft the item
+	Skill string `json:"skill,omitempty"`
+	Level int    `json:"level,omitempty"`
+
+	Inputs    []*Tree  `json:"inputs,omitempty"`
+	Resources []Source `json:"resources,omitempty"`
+	Monsters  []Source `json:"monsters,omitempty"`
+}
+
+// Source is a resource or monster that drops the item, 1 in Rate times
+type Source struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Rate        int    `json:"rate"`
+	MinQuantity int    `json:"min_quantity"`
+	MaxQuantity int    `json:"max_quantity"`
+}
+
+// NewTree builds the tree for quantity of the item. Owned can be nil, otherwise every node says how many we have.
+func NewTree(catalog *game.Catalog, item *game.Item, quantity int, owned map[string]int) *Tree {
+	tree := &Tree{Code: item.Code, Name: item.Name, Quantity: quantity}
+	if owned != nil {
+		have := owned[item.Code]
+		tree.Owned = &have
+	}
+
+	if item.Crafting != nil {
+		tree.Skill = item.Crafting.Skill
+		tree.Level = item.Crafting.Level
+
+		crafts := (quantity + max(item.Crafting.Quantity, 1) - 1) / max(item.Crafting.Quantity, 1)
+		for _, material := range sortedMaterials(item.Crafting) {
+			tree.Inputs = append(tree.Inputs, NewTree(catalog, material, item.Crafting.Items[material]*crafts, owned))
+		}
+	}
+
+	for _, resource := range catalog.Resources.ResourcesForItem(item) {
+		tree.Resources = append(tree.Resources, newSource(resource.Code, resource.Name, resource.Loot[item.Code]))
+	}
+	for _, monster := range catalog.Monsters.MonstersForItem(item) {
+		tree.Monsters = append(tree.Monsters, newSource(monster.Code, monster.Name, monster.Loot[item.Code]))
+	}
+	slices.SortFunc(tree.Resources, compareSources)
+	slices.SortFunc(tree.Monsters, compareSources)
+
+	return tree
+}
+
+func newSource(code, name string, drop game.Drop) Source {
+	return Source{Code: code, Name: name, Rate: drop.Rate, MinQuantity: drop.MinQuantity, MaxQuantity: drop.MaxQuantity}
+}
+
+func compareSources(a, b Source) int {
+	return strings.Compare(a.Code, b.Code)
+}
+
+// Write the tree in one of the formats
+func (t *Tree) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatDOT:
+		return t.WriteDOT(w)
+	case FormatMermaid:
+		return t.WriteMermaid(w)
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(t)
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+// WriteDOT writes a Graphviz digraph. Items that show up more than once, like bars, are drawn as one node.
+func (t *Tree) WriteDOT(w io.Writer) error {
+	lines := []string{"digraph {"}
+	t.walk(func(node string, label string) {
+		lines = append(lines, fmt.Sprintf("\t%q [label=%q];", node, label))
+	}, func(from, to, label string) {
+		lines = append(lines, fmt.Sprintf("\t%q -> %q [label=%q];", from, to, label))
+	})
+	lines = append(lines, "}")
+
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// WriteMermaid writes a flowchart like the ones in plan.md
+func (t *Tree) WriteMermaid(w io.Writer) error {
+	lines := []string{"graph TD;"}
+	t.walk(func(node string, label string) {
+		lines = append(lines, fmt.Sprintf(`    %s["%s"]`, mermaidID(node), strings.ReplaceAll(label, "\n", "<br/>")))
+	}, func(from, to, label string) {
+		lines = append(lines, fmt.Sprintf(`    %s -- "%s" --> %s`, mermaidID(from), label, mermaidID(to)))
+	})
+
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// walk calls node once for every item, resource and monster and edge once for every input and drop, in a stable order
+func (t *Tree) walk(node func(id, label string), edge func(from, to, label string)) {
+	seen := map[string]bool{}
+	edge = onceEach(edge)
+	var visit func(tree *Tree, isRoot bool)
+	visit = func(tree *Tree, isRoot bool) {
+		id := "item:" + tree.Code
+		if !seen[id] {
+			seen[id] = true
+			label := tree.Name
+			if isRoot {
+				label = fmt.Sprintf("%d %s", tree.Quantity, tree.Name)
+			}
+			if tree.Skill != "" {
+				label += fmt.Sprintf("\n%s %d", tree.Skill, tree.Level)
+			}
+			if tree.Owned != nil {
+				label += fmt.Sprintf("\nowned %d", *tree.Owned)
+			}
+			node(id, label)
+		}
+
+		for _, input := range tree.Inputs {
+			visit(input, false)
+			edge(id, "item:"+input.Code, fmt.Sprintf("craft x%d", input.Quantity))
+		}
+		for _, resource := range tree.Resources {
+			sourceNode(seen, node, "resource:"+resource.Code, resource.Name)
+			edge(id, "resource:"+resource.Code, dropLabel("gather", resource))
+		}
+		for _, monster := range tree.Monsters {
+			sourceNode(seen, node, "monster:"+monster.Code, monster.Name)
+			edge(id, "monster:"+monster.Code, dropLabel("fight", monster))
+		}
+	}
+	visit(t, true)
+}
+
+// onceEach skips edges that were already drawn, which happens when an item's subtree shows up more than once
+func onceEach(edge func(from, to, label string)) func(from, to, label string) {
+	drawn := map[[3]string]bool{}
+	return func(from, to, label string) {
+		key := [3]string{from, to, label}
+		if !drawn[key] {
+			drawn[key] = true
+			edge(from, to, label)
+		}
+	}
+}
+
+func sourceNode(seen map[string]bool, node func(id, label string), id, label string) {
+	if !seen[id] {
+		seen[id] = true
+		node(id, label)
+	}
+}
+
+func dropLabel(action string, source Source) string {
+	quantity := fmt.Sprintf("%d", source.MinQuantity)
+	if source.MaxQuantity != source.MinQuantity {
+		quantity = fmt.Sprintf("%d-%d", source.MinQuantity, source.MaxQuantity)
+	}
+	return fmt.Sprintf("%s %s at 1/%d", action, quantity, source.Rate)
+}
+
+// mermaidID makes a node ID out of letters, digits and underscores, which is all Mermaid accepts unquoted
+func mermaidID(id string) string {
+	return strings.NewReplacer(":", "_", "-", "_").Replace(id)
+}
+
+func sortedMaterials(crafting *game.Crafting) []*game.Item {
+	materials := make([]*game.Item, 0, len(crafting.Items))
+	for material := range crafting.Items {
+		materials = append(materials, material)
+	}
+	slices.SortFunc(materials, func(a, b *game.Item) int {
+		return strings.Compare(a.Code, b.Code)
+	})
+	return materials
+}
@@ -3,145 +3,342 @@ package graph2
 import (
 	"fmt"
 	"github.com/ahornerr/artifacts/game"
-	"github.com/dominikbraun/graph"
-	"github.com/dominikbraun/graph/draw"
-	"log"
-	"os"
-	"strconv"
+	"math"
+	"strings"
+	"time"
 )
 
-func FromItem(item *game.Item, quantity int) {
-	stringerHash := func(stringer fmt.Stringer) string {
-		return stringer.String()
-	}
+// withdrawCooldown is the bank's cooldown, the rest come from game
+const withdrawCooldown = 3 * time.Second
 
-	//g := graph.New(stringerHash, graph.Weighted(), graph.Directed(), graph.Acyclic())
-	g := graph.New(stringerHash, graph.Weighted(), graph.Acyclic())
+type Action string
 
-	graphItem(g, item, quantity)
+const (
+	ActionMove     Action = "move"
+	ActionWithdraw Action = "withdraw"
+	ActionGather   Action = "gather"
+	ActionFight    Action = "fight"
+	ActionCraft    Action = "craft"
+)
 
-	var err error
+// Actor is what the planner needs to know about the character the plan is for
+type Actor struct {
+	Location game.Location
+	Levels   map[string]int
+	Bank     map[string]int
 
-	//g, err = graph.TransitiveReduction(g)
-	//if err != nil {
-	//	log.Fatal(err)
-	//}
+	// FightTime is how long killing the monster takes the character, false if they can't
+	FightTime game.FightTime
+}
 
-	//g, err = graph.MinimumSpanningTree(g)
-	//if err != nil {
-	//	log.Fatal(err)
-	//}
+// ItemNode needs Quantity of Item. Every way to get it is an option, Best is the cheapest or nil if there isn't one.
+type ItemNode struct {
+	Item     *game.Item
+	Quantity int
+	Options  []*ActionNode
+	Best     *ActionNode
+}
 
-	g, err = graph.MaximumSpanningTree(g)
-	if err != nil {
-		log.Fatal(err)
+// ActionNode is one way to get an item. Inputs are met first, in order, then the character moves to Location
+// and does the action.
+type ActionNode struct {
+	Action   Action
+	Item     *game.Item
+	Quantity int
+	Location game.Location
+	Resource *game.Resource
+	Monster  *game.Monster
+	Inputs   []*ItemNode
+
+	// Move is the walk to Location from wherever the inputs left off, Cost is that plus the action itself
+	Move time.Duration
+	Cost time.Duration
+
+	// Total includes the inputs
+	Total time.Duration
+
+	// bank is what's left in the bank after this option
+	bank map[string]int
+}
+
+// Step is one thing for the character to do, in order
+type Step struct {
+	Action   Action
+	Item     *game.Item
+	Quantity int
+	Location game.Location
+	Resource *game.Resource
+	Monster  *game.Monster
+	Cost     time.Duration
+}
+
+func (s Step) String() string {
+	switch s.Action {
+	case ActionMove:
+		return fmt.Sprintf("Move to %s (%d, %d)", s.Location.Name, s.Location.X, s.Location.Y)
+	case ActionWithdraw:
+		return fmt.Sprintf("Withdraw %d %s", s.Quantity, s.Item.Name)
+	case ActionGather:
+		return fmt.Sprintf("Gather %d %s from %s", s.Quantity, s.Item.Name, s.Resource.Name)
+	case ActionFight:
+		return fmt.Sprintf("Fight %s for %d %s", s.Monster.Name, s.Quantity, s.Item.Name)
+	default:
+		return fmt.Sprintf("Craft %d %s", s.Quantity, s.Item.Name)
 	}
+}
 
-	file, _ := os.Create("./mygraph.dot")
-	err = draw.DOT(g, file)
-	if err != nil {
-		log.Fatal(err)
+// Plan is the whole option graph for an item along with the cheapest way through it
+type Plan struct {
+	Root  *ItemNode
+	Steps []Step
+	Cost  time.Duration
+}
+
+func (p *Plan) String() string {
+	var sb strings.Builder
+	for i, step := range p.Steps {
+		fmt.Fprintf(&sb, "%d. %s (%s)\n", i+1, step, step.Cost)
 	}
+	fmt.Fprintf(&sb, "Total %s\n", p.Cost)
+	return sb.String()
 }
 
-type Action string
+type planner struct {
+	catalog *game.Catalog
+	actor   Actor
+}
 
-func (a Action) String() string {
-	return string(a)
+// Build plans the cheapest way for the actor to get quantity of the item, in expected time. The bank, resources,
+// monsters and workshops are all options, and moving between them costs time based on distance.
+func Build(catalog *game.Catalog, actor Actor, item *game.Item, quantity int) (*Plan, error) {
+	p := &planner{catalog: catalog, actor: actor}
+
+	bank := map[string]int{}
+	for itemCode, quantity := range actor.Bank {
+		bank[itemCode] = quantity
+	}
+
+	root, _ := p.need(item, quantity, actor.Location, bank)
+	if root.Best == nil {
+		return nil, fmt.Errorf("%w: %s", game.ErrNoSource, item.Code)
+	}
+
+	plan := &Plan{Root: root, Cost: root.Best.Total}
+	location := actor.Location
+	plan.Steps = steps(root.Best, &location, nil)
+
+	return plan, nil
 }
 
-func graphItem(g graph.Graph[string, fmt.Stringer], item *game.Item, quantity int) {
-	err := g.AddVertex(item)
-	if err != nil {
-		log.Fatal(err)
+// need finds every option for the item starting from location, with bank as it is at that point in the plan.
+// It returns where the best option leaves the character and uses up the bank the way the best option does.
+func (p *planner) need(item *game.Item, quantity int, from game.Location, bank map[string]int) (*ItemNode, game.Location) {
+	node := &ItemNode{Item: item, Quantity: quantity}
+
+	node.Options = append(node.Options, p.withdraw(item, quantity, from, bank)...)
+	node.Options = append(node.Options, p.gather(item, quantity, from, bank)...)
+	node.Options = append(node.Options, p.fight(item, quantity, from, bank)...)
+	node.Options = append(node.Options, p.craft(item, quantity, from, bank)...)
+
+	for _, option := range node.Options {
+		if node.Best == nil || option.Total < node.Best.Total {
+			node.Best = option
+		}
+	}
+	if node.Best == nil {
+		return node, from
 	}
 
-	withdraw := Action("Withdraw " + item.Name)
-	err = g.AddVertex(withdraw)
-	if err != nil {
-		log.Fatal(err)
+	for itemCode := range bank {
+		delete(bank, itemCode)
+	}
+	for itemCode, left := range node.Best.bank {
+		bank[itemCode] = left
 	}
-	err = g.AddEdge(item.Name, withdraw.String(), graph.EdgeWeight(20), graph.EdgeAttribute("label", strconv.Itoa(quantity)))
-	if err != nil {
-		log.Fatal(err)
+	return node, node.Best.Location
+}
+
+// withdraw takes what the bank has and gets the rest some other way first
+func (p *planner) withdraw(item *game.Item, quantity int, from game.Location, bank map[string]int) []*ActionNode {
+	take := min(quantity, bank[item.Code])
+	if take <= 0 {
+		return nil
 	}
 
-	if item.Crafting != nil {
-		craft := Action("Craft " + item.Name)
-		err = g.AddVertex(craft)
-		if err != nil {
-			log.Fatal(err)
+	option := &ActionNode{Action: ActionWithdraw, Item: item, Quantity: take, bank: copyBank(bank)}
+	option.bank[item.Code] -= take
+
+	location := from
+	if rest := quantity - take; rest > 0 {
+		input, end := p.need(item, rest, from, option.bank)
+		if input.Best == nil {
+			return nil
 		}
-		err = g.AddEdge(item.Name, craft.String())
-		if err != nil {
-			log.Fatal(err)
+		option.Inputs = []*ItemNode{input}
+		location = end
+	}
+
+	var ok bool
+	option.Location, ok = closest(location, p.catalog.Maps.GetBanks())
+	if !ok {
+		return nil
+	}
+	option.Move = moveCost(location, option.Location)
+	option.Cost = option.Move + withdrawCooldown
+	option.Total = option.Cost + inputsTotal(option.Inputs)
+
+	return []*ActionNode{option}
+}
+
+func (p *planner) gather(item *game.Item, quantity int, from game.Location, bank map[string]int) []*ActionNode {
+	var options []*ActionNode
+	for _, resource := range p.catalog.Resources.ResourcesForItem(item) {
+		if p.actor.Levels[resource.Skill] < resource.Level {
+			continue
+		}
+		location, ok := closest(from, p.catalog.Maps.GetResources(resource.Code))
+		if !ok {
+			continue
 		}
 
-		for craftingItem, craftingQuantity := range item.Crafting.Items {
-			weight := craftingQuantity * quantity
-			graphItem(g, craftingItem, weight)
-			err = g.AddEdge(craft.String(), craftingItem.Name, graph.EdgeWeight(weight), graph.EdgeAttribute("label", strconv.Itoa(weight)))
-			if err != nil {
-				log.Fatal(err)
-			}
+		option := &ActionNode{
+			Action:   ActionGather,
+			Item:     item,
+			Quantity: quantity,
+			Location: location,
+			Resource: resource,
+			Move:     moveCost(from, location),
+			bank:     copyBank(bank),
 		}
+		actions := float64(quantity) * resource.Loot[item.Code].ExpectedActions()
+		option.Cost = option.Move + time.Duration(actions*float64(game.GatherCooldown))
+		option.Total = option.Cost
+		options = append(options, option)
 	}
+	return options
+}
 
-	for _, resource := range game.Resources.ResourcesForItem(item) {
-		err = g.AddVertex(resource)
-		if err != nil {
-			log.Fatal(err)
+func (p *planner) fight(item *game.Item, quantity int, from game.Location, bank map[string]int) []*ActionNode {
+	if p.actor.FightTime == nil {
+		return nil
+	}
+
+	var options []*ActionNode
+	for _, monster := range p.catalog.Monsters.MonstersForItem(item) {
+		perFight, ok := p.actor.FightTime(monster)
+		if !ok {
+			continue
 		}
-		cooldown := 30.0
-		weight := int(cooldown*1.0/avgDropPerAction(resource.Loot, item.Code)) * quantity
-		err = g.AddEdge(item.Name, resource.Name, graph.EdgeWeight(weight), graph.EdgeAttribute("label", strconv.Itoa(weight)))
-		//err = g.AddEdge(item.Name, resource.Name)
-		if err != nil {
-			log.Fatal(err)
+		location, ok := closest(from, p.catalog.Maps.GetMonsters(monster.Code))
+		if !ok {
+			continue
+		}
+
+		option := &ActionNode{
+			Action:   ActionFight,
+			Item:     item,
+			Quantity: quantity,
+			Location: location,
+			Monster:  monster,
+			Move:     moveCost(from, location),
+			bank:     copyBank(bank),
 		}
+		kills := float64(quantity) * monster.Loot[item.Code].ExpectedActions()
+		option.Cost = option.Move + time.Duration(kills*float64(perFight))
+		option.Total = option.Cost
+		options = append(options, option)
+	}
+	return options
+}
+
+func (p *planner) craft(item *game.Item, quantity int, from game.Location, bank map[string]int) []*ActionNode {
+	crafting := item.Crafting
+	if crafting == nil || p.actor.Levels[crafting.Skill] < crafting.Level {
+		return nil
+	}
+
+	crafts := int(math.Ceil(float64(quantity) / float64(max(crafting.Quantity, 1))))
+	option := &ActionNode{
+		Action:   ActionCraft,
+		Item:     item,
+		Quantity: crafts * max(crafting.Quantity, 1),
+		bank:     copyBank(bank),
 	}
 
-	for _, monster := range game.Monsters.MonstersForItem(item) {
-		err = g.AddVertex(monster)
-		if err != nil {
-			log.Fatal(err)
+	// Go through materials in the same order every time so plans don't change from one run to the next
+	location := from
+	for _, material := range sortedMaterials(crafting) {
+		input, end := p.need(material, crafting.Items[material]*crafts, location, option.bank)
+		if input.Best == nil {
+			return nil
 		}
-		weight := monster.Level
-		err = g.AddEdge(item.Name, monster.Name, graph.EdgeWeight(weight), graph.EdgeAttribute("label", strconv.Itoa(weight)))
-		if err != nil {
-			log.Fatal(err)
+		option.Inputs = append(option.Inputs, input)
+		location = end
+	}
+
+	var ok bool
+	option.Location, ok = closest(location, p.catalog.Maps.GetWorkshops(crafting.Skill))
+	if !ok {
+		return nil
+	}
+	option.Move = moveCost(location, option.Location)
+	option.Cost = option.Move + time.Duration(crafts)*game.CraftCooldown
+	option.Total = option.Cost + inputsTotal(option.Inputs)
+
+	return []*ActionNode{option}
+}
+
+// steps flattens the best options into what the character does, moving only when they aren't already there
+func steps(option *ActionNode, location *game.Location, out []Step) []Step {
+	for _, input := range option.Inputs {
+		out = steps(input.Best, location, out)
+	}
+
+	if location.X != option.Location.X || location.Y != option.Location.Y {
+		out = append(out, Step{Action: ActionMove, Location: option.Location, Cost: moveCost(*location, option.Location)})
+		*location = option.Location
+	}
+
+	return append(out, Step{
+		Action:   option.Action,
+		Item:     option.Item,
+		Quantity: option.Quantity,
+		Location: option.Location,
+		Resource: option.Resource,
+		Monster:  option.Monster,
+		Cost:     option.Cost - option.Move,
+	})
+}
+
+func inputsTotal(inputs []*ItemNode) time.Duration {
+	var total time.Duration
+	for _, input := range inputs {
+		total += input.Best.Total
+	}
+	return total
+}
+
+func moveCost(from, to game.Location) time.Duration {
+	return time.Duration(from.DistanceTo(to)) * game.MoveCooldownPerTile
+}
+
+func closest(from game.Location, locations []game.Location) (game.Location, bool) {
+	if len(locations) == 0 {
+		return game.Location{}, false
+	}
+	best := locations[0]
+	for _, location := range locations[1:] {
+		if from.DistanceTo(location) < from.DistanceTo(best) {
+			best = location
 		}
 	}
+	return best, true
 }
 
-func avgDropPerAction(drops []game.Drop, itemCode string) float64 {
-	// Weight calculation here is pretty simple.
-	// We want some quantity of items from the resource, and we know its drop rate.
-	// From here we can calculate the average number of drops per harvest.
-	// Weight is roughly correlated with time, so lower weights are more desirable.
-	// Take the quantity and divide by the average drop per harvest (lower drop rate increases weight).
-	// This also means that higher quantity increases rate too.
-	var avgDrop float64
-	//for _, drop := range drops {
-	//if drop.Item.Code == itemCode {
-	//	avgDropQuantity := float64(drop.MinQuantity+drop.MaxQuantity) / 2.0
-	//	avgDrop = avgDropQuantity * 1 / float64(drop.Rate)
-	//	break
-	//}
-	//}
-
-	return avgDrop
-
-	//if avgDropPerHarvest == 0 {
-	//	return 0
-	//}
-	//
-	//// Don't divide by zero if the resource doesn't drop it (for some reason)
-	//weight := math.MaxFloat64
-	//if avgDropPerHarvest > 0 {
-	//	//weight = float64(quantity) / avgDropPerHarvest
-	//	weight = 1.0 / avgDropPerHarvest
-	//}
-	//
-	//return weight
+func copyBank(bank map[string]int) map[string]int {
+	c := make(map[string]int, len(bank))
+	for itemCode, quantity := range bank {
+		c[itemCode] = quantity
+	}
+	return c
 }
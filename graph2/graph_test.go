@@ -0,0 +1,128 @@
+package graph2
+
+import (
+	"github.com/ahornerr/artifacts/game"
+	"github.com/promiseofcake/artifactsmmo-go-client/client"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func craftedItem(t *testing.T, code, skill string, materials map[string]int) client.ItemSchema {
+	t.Helper()
+
+	var items []client.SimpleItemSchema
+	for material, quantity := range materials {
+		items = append(items, client.SimpleItemSchema{Code: material, Quantity: quantity})
+	}
+	craftSkill := client.CraftSchemaSkill(skill)
+	level, quantity := 1, 1
+
+	var craft client.ItemSchema_Craft
+	if err := craft.FromCraftSchema(client.CraftSchema{Skill: &craftSkill, Level: &level, Items: &items, Quantity: &quantity}); err != nil {
+		t.Fatal(err)
+	}
+	return client.ItemSchema{Code: code, Name: code, Type: "weapon", Level: 1, Craft: &craft}
+}
+
+func tile(t *testing.T, x, y int, contentType, code string) client.MapSchema {
+	t.Helper()
+
+	var content client.MapSchema_Content
+	if err := content.FromMapContentSchema(client.MapContentSchema{Type: contentType, Code: code}); err != nil {
+		t.Fatal(err)
+	}
+	return client.MapSchema{X: x, Y: y, Content: content}
+}
+
+// daggerCatalog has a dagger made from bars made from ore, with everything in a row starting at the bank
+func daggerCatalog(t *testing.T) *game.Catalog {
+	t.Helper()
+
+	catalog, err := game.NewCatalog(game.Data{
+		Items: []client.ItemSchema{
+			{Code: "copper_ore", Name: "copper_ore", Type: "resource", Level: 1},
+			craftedItem(t, "copper_bar", "mining", map[string]int{"copper_ore": 6}),
+			craftedItem(t, "copper_dagger", "weaponcrafting", map[string]int{"copper_bar": 2}),
+		},
+		Resources: []client.ResourceSchema{{
+			Code:  "copper_rocks",
+			Name:  "copper_rocks",
+			Skill: "mining",
+			Level: 1,
+			Drops: []client.DropRateSchema{{Code: "copper_ore", Rate: 1, MinQuantity: 1, MaxQuantity: 1}},
+		}},
+		Maps: []client.MapSchema{
+			tile(t, 0, 0, "bank", "bank"),
+			tile(t, 2, 0, "resource", "copper_rocks"),
+			tile(t, 3, 0, "workshop", "mining"),
+			tile(t, 4, 0, "workshop", "weaponcrafting"),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return catalog
+}
+
+func TestBuildWithdrawsOverGathering(t *testing.T) {
+	catalog := daggerCatalog(t)
+	bank := catalog.Maps.GetBanks()[0]
+	weaponcrafting := catalog.Maps.GetWorkshops("weaponcrafting")[0]
+
+	actor := Actor{
+		Location: bank,
+		Levels:   map[string]int{"mining": 1, "weaponcrafting": 1},
+		Bank:     map[string]int{"copper_bar": 2},
+	}
+	p, err := Build(catalog, actor, catalog.Items.Get("copper_dagger"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Step{
+		{Action: ActionWithdraw, Item: catalog.Items.Get("copper_bar"), Quantity: 2, Location: bank, Cost: withdrawCooldown},
+		{Action: ActionMove, Location: weaponcrafting, Cost: 4 * game.MoveCooldownPerTile},
+		{Action: ActionCraft, Item: catalog.Items.Get("copper_dagger"), Quantity: 1, Location: weaponcrafting, Cost: game.CraftCooldown},
+	}
+	if !reflect.DeepEqual(p.Steps, want) {
+		t.Errorf("steps:\n%s\nwant:\n%s", p, &Plan{Steps: want})
+	}
+}
+
+func TestBuildGathersWithEmptyBank(t *testing.T) {
+	catalog := daggerCatalog(t)
+	rocks := catalog.Maps.GetResources("copper_rocks")[0]
+	mining := catalog.Maps.GetWorkshops("mining")[0]
+	weaponcrafting := catalog.Maps.GetWorkshops("weaponcrafting")[0]
+
+	actor := Actor{
+		Location: catalog.Maps.GetBanks()[0],
+		Levels:   map[string]int{"mining": 1, "weaponcrafting": 1},
+	}
+	p, err := Build(catalog, actor, catalog.Items.Get("copper_dagger"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Materials come first, and each move is only to the next place something happens
+	want := []Step{
+		{Action: ActionMove, Location: rocks, Cost: 2 * game.MoveCooldownPerTile},
+		{Action: ActionGather, Item: catalog.Items.Get("copper_ore"), Quantity: 12, Location: rocks, Resource: catalog.Resources.Get("copper_rocks"), Cost: 12 * game.GatherCooldown},
+		{Action: ActionMove, Location: mining, Cost: game.MoveCooldownPerTile},
+		{Action: ActionCraft, Item: catalog.Items.Get("copper_bar"), Quantity: 2, Location: mining, Cost: 2 * game.CraftCooldown},
+		{Action: ActionMove, Location: weaponcrafting, Cost: game.MoveCooldownPerTile},
+		{Action: ActionCraft, Item: catalog.Items.Get("copper_dagger"), Quantity: 1, Location: weaponcrafting, Cost: game.CraftCooldown},
+	}
+	if !reflect.DeepEqual(p.Steps, want) {
+		t.Errorf("steps:\n%s\nwant:\n%s", p, &Plan{Steps: want})
+	}
+
+	var total time.Duration
+	for _, step := range want {
+		total += step.Cost
+	}
+	if p.Cost != total {
+		t.Errorf("cost = %s, want %s", p.Cost, total)
+	}
+}
@@ -2,18 +2,31 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/ahornerr/artifacts/bank"
 	"github.com/ahornerr/artifacts/character"
+	"github.com/ahornerr/artifacts/combat"
+	"github.com/ahornerr/artifacts/graph2"
+	"github.com/ahornerr/artifacts/jobs"
 	"github.com/gofiber/fiber/v3"
 	"github.com/gofiber/fiber/v3/middleware/static"
 	"log"
+	"strings"
 	"time"
 )
 
 type Event struct {
 	Character *character.Character
 	Bank      map[string]int
+	BankGold  *int
+}
+
+func newBankEvent(snapshot bank.Snapshot) Event {
+	gold := snapshot.Gold
+	return Event{Bank: snapshot.Items(), BankGold: &gold}
 }
 
 func marshalEvent(event Event) (byteArray []byte, err error) {
@@ -26,7 +39,90 @@ func marshalEvent(event Event) (byteArray []byte, err error) {
 	return json.Marshal(event)
 }
 
-func httpServer(events <-chan Event, onNewClient func()) *fiber.App {
+// Loadout is an equipment set as the HTTP API shows it, item codes by slot
+type Loadout struct {
+	Profile    string
+	Equipment  map[string]string
+	Quantities map[string]int
+	Outcome    combat.Outcome
+}
+
+func newLoadout(objective character.Objective, set *character.EquipmentSet) Loadout {
+	loadout := Loadout{
+		Profile:    objective.Profile,
+		Equipment:  map[string]string{},
+		Quantities: set.Quantities,
+		Outcome:    set.Outcome,
+	}
+	if loadout.Profile == "" {
+		loadout.Profile = character.ProfileFastest
+	}
+	for slot, item := range set.Equipment {
+		loadout.Equipment[slot] = item.Code
+	}
+	return loadout
+}
+
+// objectiveFromQuery reads ?profile=safest&keep=a,b&exclude=c and weights like ?hp_left=2 for the weighted profile
+func objectiveFromQuery(c fiber.Ctx) (character.Objective, error) {
+	objective := character.Objective{
+		Profile: c.Query("profile"),
+		Weights: character.Weights{
+			Turns:       fiber.Query[float64](c, "turns"),
+			HpLeft:      fiber.Query[float64](c, "hp_left"),
+			Haste:       fiber.Query[float64](c, "haste"),
+			Wisdom:      fiber.Query[float64](c, "wisdom"),
+			Prospecting: fiber.Query[float64](c, "prospecting"),
+		},
+	}
+	if keep := c.Query("keep"); keep != "" {
+		objective.Keep = strings.Split(keep, ",")
+	}
+	if exclude := c.Query("exclude"); exclude != "" {
+		objective.Exclude = strings.Split(exclude, ",")
+	}
+	return objective, objective.Validate()
+}
+
+// ledgerQueryFromQuery reads ?character=&action=&item=&since=&until= with times in RFC 3339
+func ledgerQueryFromQuery(c fiber.Ctx) (bank.Query, error) {
+	q := bank.Query{
+		Character: c.Query("character"),
+		Action:    c.Query("action"),
+		Item:      c.Query("item"),
+	}
+	for _, t := range []struct {
+		param string
+		time  *time.Time
+	}{{"since", &q.Since}, {"until", &q.Until}} {
+		if value := c.Query(t.param); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return q, fmt.Errorf("%s: %w", t.param, err)
+			}
+			*t.time = parsed
+		}
+	}
+	return q, nil
+}
+
+// serverDeps is everything the HTTP API reads from the rest of the bot
+type serverDeps struct {
+	Events      <-chan Event
+	OnNewClient func()
+	Calibration func() combat.Report
+
+	// Loadout is the best equipment a character has for a monster or resource code under an objective
+	Loadout func(charName, target string, objective character.Objective) (*character.EquipmentSet, error)
+	Ledger  *bank.Ledger
+
+	// Tree is the recipe and sources for an item, optionally with how many of everything we have
+	Tree           func(itemCode string, quantity int, withOwned bool) (*graph2.Tree, error)
+	Queue          *jobs.Queue
+	UnknownEffects func() map[string][]string
+}
+
+func httpServer(deps serverDeps) *fiber.App {
 	app := fiber.New()
 	//app.Use(pprof.New())
 
@@ -42,7 +138,7 @@ func httpServer(events <-chan Event, onNewClient func()) *fiber.App {
 				clients[c] = true
 			case c := <-deleteClient:
 				delete(clients, c)
-			case ev := <-events:
+			case ev := <-deps.Events:
 				if len(clients) == 0 {
 					continue
 				}
@@ -83,7 +179,7 @@ func httpServer(events <-chan Event, onNewClient func()) *fiber.App {
 			newClient <- clientChan
 
 			go func() {
-				onNewClient()
+				deps.OnNewClient()
 			}()
 
 			for {
@@ -106,6 +202,101 @@ func httpServer(events <-chan Event, onNewClient func()) *fiber.App {
 		return nil
 	})
 
+	app.Get("/calibration", func(c fiber.Ctx) error {
+		c.Set("Access-Control-Allow-Origin", "*")
+		return c.JSON(deps.Calibration())
+	})
+
+	app.Get("/loadout/:character/:target", func(c fiber.Ctx) error {
+		c.Set("Access-Control-Allow-Origin", "*")
+
+		objective, err := objectiveFromQuery(c)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		set, err := deps.Loadout(c.Params("character"), c.Params("target"), objective)
+		if err != nil {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+
+		return c.JSON(newLoadout(objective, set))
+	})
+
+	app.Get("/ledger", func(c fiber.Ctx) error {
+		c.Set("Access-Control-Allow-Origin", "*")
+
+		q, err := ledgerQueryFromQuery(c)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		return c.JSON(deps.Ledger.Find(q))
+	})
+
+	// /ledger/totals?action=withdraw&item=copper_bar is who took the copper bars
+	app.Get("/ledger/totals", func(c fiber.Ctx) error {
+		c.Set("Access-Control-Allow-Origin", "*")
+
+		q, err := ledgerQueryFromQuery(c)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		return c.JSON(deps.Ledger.ByCharacter(q))
+	})
+
+	// /tree/iron_sword?quantity=5&format=mermaid&owned=true is the recipe and sources for 5 iron swords with what we have
+	app.Get("/tree/:item", func(c fiber.Ctx) error {
+		c.Set("Access-Control-Allow-Origin", "*")
+
+		quantity := fiber.Query[int](c, "quantity", 1)
+		if quantity <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("quantity must be a positive number, got %q", c.Query("quantity")))
+		}
+
+		t, err := deps.Tree(c.Params("item"), quantity, fiber.Query[bool](c, "owned"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+
+		format := fiber.Query[string](c, "format", graph2.FormatJSON)
+		var buf bytes.Buffer
+		if err = t.Write(&buf, format); err != nil {
+			if errors.Is(err, graph2.ErrUnknownFormat) {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+			return err
+		}
+
+		switch format {
+		case graph2.FormatJSON:
+			c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		case graph2.FormatDOT:
+			c.Set(fiber.HeaderContentType, "text/vnd.graphviz")
+		default:
+			c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+		}
+		return c.Send(buf.Bytes())
+	})
+
+	// /jobs?status=pending lists jobs in the order harvesters will pick them up
+	app.Get("/jobs", func(c fiber.Ctx) error {
+		c.Set("Access-Control-Allow-Origin", "*")
+
+		status := jobs.Status(c.Query("status"))
+		switch status {
+		case "", jobs.StatusPending, jobs.StatusClaimed, jobs.StatusDone, jobs.StatusFailed:
+		default:
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("unknown status %q", status))
+		}
+		return c.JSON(deps.Queue.Jobs(status))
+	})
+
+	// Item effects the bot doesn't know what to do with, mapped to the items that have them. Their stats are ignored.
+	app.Get("/effects/unknown", func(c fiber.Ctx) error {
+		c.Set("Access-Control-Allow-Origin", "*")
+		return c.JSON(deps.UnknownEffects())
+	})
+
 	app.Get("/*", static.New("./frontend/build"))
 
 	return app
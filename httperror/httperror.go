@@ -80,3 +80,21 @@ func ErrIsNotFoundOnMap(err error) bool {
 	}
 	return httpError.Code == 598
 }
+
+// ErrIsInsufficientGold is when the character doesn't have the gold to deposit or the bank doesn't have it to withdraw
+func ErrIsInsufficientGold(err error) bool {
+	var httpError HTTPError
+	if !errors.As(err, &httpError) {
+		return false
+	}
+	return httpError.Code == 492 || httpError.Code == 460
+}
+
+// ErrIsGEPriceChanged is when the grand exchange price isn't what we offered, or it ran out of stock
+func ErrIsGEPriceChanged(err error) bool {
+	var httpError HTTPError
+	if !errors.As(err, &httpError) {
+		return false
+	}
+	return httpError.Code == 482 || httpError.Code == 480
+}
@@ -0,0 +1,317 @@
+package jobs
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ahornerr/artifacts/atomicfile"
+	"io/fs"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusClaimed Status = "claimed"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+)
+
+// Higher priority jobs are claimed first
+const (
+	PriorityLow    = 0
+	PriorityNormal = 10
+	PriorityHigh   = 20
+)
+
+// MaxAttempts is how many times a job is tried before it's failed for good
+const MaxAttempts = 3
+
+// DefaultLease is how long a claim lasts before the job goes back to pending, in case the claimant got stuck
+const DefaultLease = time.Hour
+
+// maxFinished is how many done and failed jobs are kept around to look at
+const maxFinished = 200
+
+var ErrNoJob = errors.New("no such job")
+
+var ErrNotClaimed = errors.New("job isn't claimed by this claimant")
+
+// Job asks for Quantity of Item. Owner is who wants it and Claimant is who's getting it.
+type Job struct {
+	ID       int    `json:"id"`
+	Item     string `json:"item"`
+	Quantity int    `json:"quantity"`
+	Priority int    `json:"priority"`
+	Owner    string `json:"owner"`
+
+	Status   Status `json:"status"`
+	Claimant string `json:"claimant,omitempty"`
+	Attempts int    `json:"attempts"`
+
+	// LeaseExpires is when a claimed job goes back to pending if the claimant hasn't finished it
+	LeaseExpires time.Time `json:"lease_expires,omitempty"`
+
+	// Error is why the last attempt failed
+	Error string `json:"error,omitempty"`
+
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+func (j Job) finished() bool {
+	return j.Status == StatusDone || j.Status == StatusFailed
+}
+
+// Queue is a job queue saved to a JSON file after every change so nothing is lost on restart
+type Queue struct {
+	// Lease is how long claims last
+	Lease time.Duration
+
+	path   string
+	jobs   map[int]*Job
+	lastID int
+	mux    sync.Mutex
+}
+
+// Open loads the queue at path, creating it if needed. Jobs that were claimed when the bot stopped are pending again.
+func Open(path string) (*Queue, error) {
+	q := &Queue{
+		Lease: DefaultLease,
+		path:  path,
+		jobs:  map[int]*Job{},
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return q, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var jobs []*Job
+	if err = json.Unmarshal(b, &jobs); err != nil {
+		return nil, fmt.Errorf("reading jobs from %s: %w", path, err)
+	}
+	for _, job := range jobs {
+		if job.Status == StatusClaimed {
+			// Stopping the bot isn't the job's fault, don't count it as an attempt
+			job.Status = StatusPending
+			job.Claimant = ""
+			job.LeaseExpires = time.Time{}
+			job.Attempts = max(0, job.Attempts-1)
+		}
+		q.jobs[job.ID] = job
+		q.lastID = max(q.lastID, job.ID)
+	}
+
+	return q, nil
+}
+
+// Add a pending job
+func (q *Queue) Add(owner string, itemCode string, quantity int, priority int) (Job, error) {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	now := time.Now()
+	q.lastID++
+	job := &Job{
+		ID:       q.lastID,
+		Item:     itemCode,
+		Quantity: quantity,
+		Priority: priority,
+		Owner:    owner,
+		Status:   StatusPending,
+		Created:  now,
+		Updated:  now,
+	}
+	q.jobs[job.ID] = job
+
+	return *job, q.save()
+}
+
+// Claim the highest priority pending job that canDo accepts, oldest first among equal priorities
+func (q *Queue) Claim(claimant string, canDo func(Job) bool) (Job, bool, error) {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	expired := q.expireClaims()
+
+	for _, job := range q.sorted() {
+		if job.Status != StatusPending || !canDo(*job) {
+			continue
+		}
+
+		now := time.Now()
+		job.Status = StatusClaimed
+		job.Claimant = claimant
+		job.LeaseExpires = now.Add(q.Lease)
+		job.Attempts++
+		job.Updated = now
+		return *job, true, q.save()
+	}
+
+	if expired {
+		return Job{}, false, q.save()
+	}
+	return Job{}, false, nil
+}
+
+// Pending is true when there's a job that canDo accepts for Claim to claim
+func (q *Queue) Pending(canDo func(Job) bool) bool {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	now := time.Now()
+	for _, job := range q.jobs {
+		claimable := job.Status == StatusPending || (job.Status == StatusClaimed && now.After(job.LeaseExpires))
+		if claimable && canDo(*job) {
+			return true
+		}
+	}
+	return false
+}
+
+// Complete marks a claimed job done
+func (q *Queue) Complete(id int, claimant string) error {
+	return q.update(id, claimant, func(job *Job) {
+		job.Status = StatusDone
+		job.Claimant = ""
+		job.LeaseExpires = time.Time{}
+		job.Error = ""
+	})
+}
+
+// Fail puts the job back to be tried again, unless it's out of attempts
+func (q *Queue) Fail(id int, claimant string, err error) error {
+	return q.update(id, claimant, func(job *Job) {
+		job.Error = err.Error()
+		unclaim(job)
+	})
+}
+
+// Release gives a claimed job back without counting it as an attempt, for when the claimant was interrupted
+func (q *Queue) Release(id int, claimant string) error {
+	return q.update(id, claimant, func(job *Job) {
+		job.Status = StatusPending
+		job.Claimant = ""
+		job.LeaseExpires = time.Time{}
+		job.Attempts = max(0, job.Attempts-1)
+	})
+}
+
+// update changes a job the claimant has claimed. A claim that expired and went to someone else is ErrNotClaimed.
+func (q *Queue) update(id int, claimant string, f func(job *Job)) error {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrNoJob, id)
+	}
+	if job.Status != StatusClaimed || job.Claimant != claimant {
+		return fmt.Errorf("%w: %d %s", ErrNotClaimed, id, claimant)
+	}
+
+	f(job)
+	job.Updated = time.Now()
+
+	return q.save()
+}
+
+// expireClaims fails claims whose lease ran out, true if there were any. It must be called with the lock held.
+func (q *Queue) expireClaims() bool {
+	now := time.Now()
+	expired := false
+	for _, job := range q.jobs {
+		if job.Status == StatusClaimed && now.After(job.LeaseExpires) {
+			job.Error = fmt.Sprintf("%s's claim expired", job.Claimant)
+			job.Updated = now
+			unclaim(job)
+			expired = true
+		}
+	}
+	return expired
+}
+
+// unclaim puts a job back to be tried again, unless it's out of attempts
+func unclaim(job *Job) {
+	job.Claimant = ""
+	job.LeaseExpires = time.Time{}
+	job.Status = StatusPending
+	if job.Attempts >= MaxAttempts {
+		job.Status = StatusFailed
+	}
+}
+
+// Outstanding is how much of the item is pending or claimed for owner
+func (q *Queue) Outstanding(owner string, itemCode string) int {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	quantity := 0
+	for _, job := range q.jobs {
+		if job.Owner == owner && job.Item == itemCode && !job.finished() {
+			quantity += job.Quantity
+		}
+	}
+	return quantity
+}
+
+// Jobs in the order they'd be claimed, optionally only those with the status
+func (q *Queue) Jobs(status Status) []Job {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	var jobs []Job
+	for _, job := range q.sorted() {
+		if status == "" || job.Status == status {
+			jobs = append(jobs, *job)
+		}
+	}
+	return jobs
+}
+
+func (q *Queue) sorted() []*Job {
+	jobs := make([]*Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobs = append(jobs, job)
+	}
+	slices.SortFunc(jobs, func(a, b *Job) int {
+		if a.Priority != b.Priority {
+			return b.Priority - a.Priority
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return jobs
+}
+
+// save prunes old finished jobs and writes the queue. It must be called with the lock held.
+func (q *Queue) save() error {
+	var finished []*Job
+	for _, job := range q.jobs {
+		if job.finished() {
+			finished = append(finished, job)
+		}
+	}
+	if len(finished) > maxFinished {
+		slices.SortFunc(finished, func(a, b *Job) int {
+			return a.Updated.Compare(b.Updated)
+		})
+		for _, job := range finished[:len(finished)-maxFinished] {
+			delete(q.jobs, job.ID)
+		}
+	}
+
+	b, err := json.MarshalIndent(q.sorted(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.WriteFile(q.path, b)
+}
@@ -2,18 +2,36 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/ahornerr/artifacts/bank"
 	"github.com/ahornerr/artifacts/character"
 	"github.com/ahornerr/artifacts/client"
+	"github.com/ahornerr/artifacts/combat"
+	"github.com/ahornerr/artifacts/config"
 	"github.com/ahornerr/artifacts/game"
+	"github.com/ahornerr/artifacts/ge"
+	"github.com/ahornerr/artifacts/graph2"
+	"github.com/ahornerr/artifacts/jobs"
 	"github.com/ahornerr/artifacts/state"
+	"io/fs"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
 func main() {
-	client, err := client.New(os.Getenv("ARTIFACTS_TOKEN"))
+	if len(os.Args) > 1 && os.Args[1] == "tree" {
+		treeCommand(os.Args[2:])
+		return
+	}
+
+	cfg, configPath := loadConfig()
+
+	serverURL, token := account(cfg)
+	client, err := client.NewWithServer(serverURL, token)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -21,7 +39,9 @@ func main() {
 	events := make(chan Event)
 
 	characterUpdates := make(chan *character.Character)
-	bankUpdates := make(chan map[string]int)
+
+	theBank := bank.NewBank(client)
+	bankChanges, _ := theBank.Subscribe()
 
 	go func() {
 		nonBlockingWriteEvent := func(event Event) {
@@ -36,26 +56,62 @@ func main() {
 			case char := <-characterUpdates:
 				char2 := *char
 				nonBlockingWriteEvent(Event{Character: &char2})
-			case bankItems := <-bankUpdates:
-				nonBlockingWriteEvent(Event{Bank: bankItems})
+			case change := <-bankChanges:
+				nonBlockingWriteEvent(newBankEvent(change.Snapshot))
 			}
 		}
 	}()
 
 	ctx := context.Background()
 
-	theBank := bank.NewBank(client, bankUpdates)
+	snapshotPath := os.Getenv("ARTIFACTS_SNAPSHOT")
+	if snapshotPath == "" {
+		snapshotPath = "game_data.json"
+	}
+
+	catalog, err := game.LoadCatalogCached(ctx, client, snapshotPath)
+	if err != nil {
+		log.Fatalf("loading game data: %s", err)
+	}
+	catalog.StartRefresh(ctx, time.Minute)
+
+	exchange := ge.NewExchange(client)
+	if err = exchange.Load(ctx); err != nil {
+		log.Fatalf("loading grand exchange prices: %s", err)
+	}
+	exchange.StartRefresh(ctx, time.Minute)
+	// A gold is worth about a second, roughly what fighting earns
+	catalog.SetPrices(exchange, time.Second)
+
+	ledgerPath := os.Getenv("ARTIFACTS_LEDGER")
+	if ledgerPath == "" {
+		ledgerPath = "bank_ledger.jsonl"
+	}
+
+	ledger, err := bank.OpenLedger(ledgerPath)
+	if err != nil {
+		log.Fatalf("opening bank ledger: %s", err)
+	}
+	theBank.SetLedger(ledger)
 
 	if _, err := theBank.Load(ctx); err != nil {
 		log.Fatalf("loading bank items: %s", err)
 	}
+	if _, err := theBank.LoadGold(ctx); err != nil {
+		log.Fatalf("loading bank gold: %s", err)
+	}
 
-	characterNames := []string{
-		"curlyBoy1", "curlyBoy2", "curlyBoy3", "curlyBoy4", "curlyBoy5",
+	if err = cfg.CheckCatalog(catalog); err != nil {
+		log.Fatalf("config %s: %s", configPath, err)
+	}
+
+	var characterNames []string
+	for _, c := range cfg.Characters {
+		characterNames = append(characterNames, c.Name)
 	}
 	characters := map[string]*character.Character{}
 	for _, charName := range characterNames {
-		char := character.NewCharacter(client, theBank, characterUpdates, charName)
+		char := character.NewCharacter(client, theBank, catalog, characterUpdates, charName)
 		_, err = char.Get(ctx)
 		if err != nil {
 			log.Fatal(err)
@@ -63,6 +119,21 @@ func main() {
 		characters[charName] = char
 	}
 
+	// Fights take as long as they would for whichever character is quickest at killing the monster
+	catalog.SetFightTime(func(monster *game.Monster) (time.Duration, bool) {
+		quickest := time.Duration(0)
+		for _, char := range characters {
+			outcome := combat.Expected(char.Fighter(), combat.NewMonster(monster))
+			if outcome.WinProbability == 0 {
+				continue
+			}
+			if quickest == 0 || outcome.Cooldown < quickest {
+				quickest = outcome.Cooldown
+			}
+		}
+		return quickest, quickest > 0
+	})
+
 	//totalItemQuantity := func(itemCode string) int {
 	//	quantity := theBank.Items[itemCode]
 	//
@@ -111,32 +182,61 @@ func main() {
 	//        Take into account drop rates of items, and estimate how long it will take to kill a given monster.
 	//        We can probably just look at monster HP as an analog for difficulty.
 	//        Likewise with crafted items we should look at the materials required to craft them.
-	//        TODO: How does this work when we have some banked materials already?
+	//        Materials we already have (banked or in inventories) are subtracted, see Catalog.CostFrom.
 
-	crafterWants := make(chan game.ItemQuantity, 100)
+	jobsPath := os.Getenv("ARTIFACTS_JOBS")
+	if jobsPath == "" {
+		jobsPath = "jobs.json"
+	}
 
-	characterStates := map[string]state.Runner{
-		"curlyBoy1": state.RoleCrafter(characters, crafterWants),
-		"curlyBoy2": state.RoleHarvester(crafterWants),
-		"curlyBoy3": state.RoleHarvester(crafterWants),
-		"curlyBoy4": state.RoleHarvester(crafterWants),
-		"curlyBoy5": state.RoleHarvester(crafterWants),
+	queue, err := jobs.Open(jobsPath)
+	if err != nil {
+		log.Fatalf("opening job queue: %s", err)
 	}
 
-	for charName, charState := range characterStates {
-		go func(char *character.Character, charState state.Runner) {
-			char.PushState("Waiting for cooldown")
-			time.Sleep(time.Until(char.CooldownExpires))
-			char.PopState()
+	checkpointsPath := os.Getenv("ARTIFACTS_CHECKPOINTS")
+	if checkpointsPath == "" {
+		checkpointsPath = "checkpoints.json"
+	}
 
-			err := charState(ctx, char)
-			if err != nil {
-				char.PushState("Error: %s", err)
-			} else {
-				char.PushState("Done")
-			}
-		}(characters[charName], charState)
+	checkpoints, err := state.OpenCheckpoints(checkpointsPath)
+	if err != nil {
+		log.Fatalf("opening checkpoints: %s", err)
 	}
+	checkpoints.StartFlushing(ctx)
+
+	// Checkpoints are only written every so often, the last changes are written on the way out
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		sig := <-signals
+		log.Printf("Got %s, saving checkpoints and exiting\n", sig)
+		if err := checkpoints.Flush(); err != nil {
+			log.Printf("saving checkpoints: %s\n", err)
+		}
+		os.Exit(0)
+	}()
+
+	// Characters pick up whatever they were in the middle of when the bot stopped
+	runCtx := state.WithCheckpoints(ctx, checkpoints)
+
+	characterRoles := newRoles(runCtx, characters, queue)
+	characterRoles.apply(cfg)
+
+	// Roles can change without a restart, everything else in the config is only read at startup.
+	// Reloads only come from Watch's goroutine, one at a time.
+	applied := cfg
+	config.Watch(ctx, configPath, 5*time.Second, func(newCfg *config.Config) {
+		if err := newCfg.CheckCatalog(catalog); err != nil {
+			log.Println("Not reloading config:", err)
+			return
+		}
+		if newCfg.Listen != applied.Listen || newCfg.Account != applied.Account {
+			log.Println("Restart to use the new listen address or account")
+		}
+		characterRoles.apply(newCfg)
+		applied = newCfg
+	})
 
 	onNewClient := func() {
 		// Iterate over the character slice since it's ordered
@@ -144,9 +244,94 @@ func main() {
 			char := *characters[charName]
 			events <- Event{Character: &char}
 		}
-		events <- Event{Bank: theBank.Items()}
+		events <- newBankEvent(theBank.Snapshot())
+	}
+
+	calibration := func() combat.Report {
+		var records []combat.Record
+		for _, char := range characters {
+			records = append(records, char.Fights()...)
+		}
+		return combat.Calibrate(records)
+	}
+
+	loadout := func(charName, target string, objective character.Objective) (*character.EquipmentSet, error) {
+		char, ok := characters[charName]
+		if !ok {
+			return nil, fmt.Errorf("no character named %q", charName)
+		}
+		if monster := catalog.Monsters.Get(target); monster != nil {
+			return char.GetBestOwnedEquipment(monster.Stats, objective), nil
+		}
+		if resource := catalog.Resources.Get(target); resource != nil {
+			if stats := state.GatheringStats(resource); stats != nil {
+				return char.GetBestOwnedEquipment(stats, objective), nil
+			}
+		}
+		return nil, fmt.Errorf("no monster or resource %q", target)
+	}
+
+	tree := func(itemCode string, quantity int, withOwned bool) (*graph2.Tree, error) {
+		item := catalog.Items.Get(itemCode)
+		if item == nil {
+			return nil, fmt.Errorf("no item %q", itemCode)
+		}
+
+		var owned map[string]int
+		if withOwned {
+			owned = countOwned(theBank.Snapshot().Items(), characters)
+		}
+		return graph2.NewTree(catalog, item, quantity, owned), nil
+	}
+
+	server := httpServer(serverDeps{
+		Events:         events,
+		OnNewClient:    onNewClient,
+		Calibration:    calibration,
+		Loadout:        loadout,
+		Ledger:         ledger,
+		Tree:           tree,
+		Queue:          queue,
+		UnknownEffects: catalog.Effects.Unknown,
+	})
+	err = server.Listen(cfg.Listen)
+	if flushErr := checkpoints.Flush(); flushErr != nil {
+		log.Printf("saving checkpoints: %s\n", flushErr)
+	}
+	log.Fatal(err)
+}
+
+// loadConfig reads the config from ARTIFACTS_CONFIG, or config.yaml, using the defaults when there isn't one
+func loadConfig() (*config.Config, string) {
+	configPath := os.Getenv("ARTIFACTS_CONFIG")
+	if configPath == "" {
+		configPath = "config.yaml"
+	}
+
+	cfg, err := config.Load(configPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Printf("No config at %s, using the defaults. See config.example.yaml to write one.\n", configPath)
+		cfg = config.Default()
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	return cfg, configPath
+}
+
+// account is the server and token to use for the config's account. The server falls back to ARTIFACTS_SERVER
+// and then the real API.
+func account(cfg *config.Config) (string, string) {
+	token, err := cfg.Account.ResolveToken()
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	server := httpServer(events, onNewClient)
-	log.Fatal(server.Listen(":8080"))
+	serverURL := cfg.Account.Server
+	if serverURL == "" {
+		serverURL = os.Getenv("ARTIFACTS_SERVER")
+	}
+	if serverURL == "" {
+		serverURL = client.DefaultServer
+	}
+	return serverURL, token
 }
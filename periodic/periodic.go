@@ -0,0 +1,53 @@
+package periodic
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Refresher calls a function every interval in the background. The zero value is ready to use.
+type Refresher struct {
+	stop context.CancelFunc
+	mux  sync.Mutex
+}
+
+// Start calls refresh every interval until Stop is called or ctx is done, replacing whatever was already running.
+// Failures are logged with what, e.g. "game data", and don't stop the next refresh.
+func (r *Refresher) Start(ctx context.Context, interval time.Duration, what string, refresh func(ctx context.Context) error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.stop != nil {
+		r.stop()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	r.stop = cancel
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := refresh(ctx); err != nil {
+					log.Printf("Refreshing %s failed: %s\n", what, err)
+				}
+			}
+		}
+	}()
+}
+
+func (r *Refresher) Stop() {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.stop != nil {
+		r.stop()
+		r.stop = nil
+	}
+}
@@ -0,0 +1,164 @@
+package plan
+
+import (
+	"fmt"
+	"github.com/ahornerr/artifacts/game"
+	"math"
+	"strings"
+)
+
+type Action string
+
+const (
+	ActionGather Action = "gather"
+	ActionFight  Action = "fight"
+	ActionCraft  Action = "craft"
+)
+
+// Step gets Quantity of Item. Gather steps come from Resource, fight steps from Monster and craft steps take
+// Crafts actions at the item's workshop.
+type Step struct {
+	Action   Action
+	Item     *game.Item
+	Quantity int
+
+	Resource *game.Resource
+	Monster  *game.Monster
+	Crafts   int
+}
+
+func (s Step) String() string {
+	switch s.Action {
+	case ActionGather:
+		return fmt.Sprintf("Gather %d %s from %s", s.Quantity, s.Item.Name, s.Resource.Name)
+	case ActionFight:
+		return fmt.Sprintf("Fight %s for %d %s", s.Monster.Name, s.Quantity, s.Item.Name)
+	default:
+		return fmt.Sprintf("Craft %d %s", s.Quantity, s.Item.Name)
+	}
+}
+
+// Plan is everything it takes to make a list of target items
+type Plan struct {
+	Targets []game.ItemQuantity
+
+	// Required is how many of each item the whole recipe tree needs, before stock
+	Required map[*game.Item]int
+
+	// FromStock is how much of Required is already on hand
+	FromStock map[*game.Item]int
+
+	// Steps are in dependency order, every step's materials come from stock or an earlier step
+	Steps []Step
+}
+
+// New plans the targets, using up stock first. Intermediates that more than one target needs, like bars and planks,
+// are added up so they're only gathered or crafted once.
+func New(catalog *game.Catalog, targets []game.ItemQuantity, stock map[string]int) (*Plan, error) {
+	p := &Plan{
+		Targets:   targets,
+		Required:  map[*game.Item]int{},
+		FromStock: map[*game.Item]int{},
+	}
+
+	// Every item comes after everything that needs it, so its demand is complete by the time it's visited
+	var order []*game.Item
+	visited := map[*game.Item]bool{}
+	var visit func(item *game.Item)
+	visit = func(item *game.Item) {
+		if visited[item] {
+			return
+		}
+		visited[item] = true
+		if item.Crafting != nil {
+			for material := range item.Crafting.Items {
+				visit(material)
+			}
+		}
+		order = append(order, item)
+	}
+	for _, target := range targets {
+		visit(target.Item)
+		p.Required[target.Item] += target.Quantity
+	}
+
+	remaining := map[string]int{}
+	for itemCode, quantity := range stock {
+		remaining[itemCode] = quantity
+	}
+
+	var steps []Step
+	for i := len(order) - 1; i >= 0; i-- {
+		item := order[i]
+
+		need := p.Required[item]
+		if used := min(need, remaining[item.Code]); used > 0 {
+			p.FromStock[item] = used
+			remaining[item.Code] -= used
+			need -= used
+		}
+		if need <= 0 {
+			continue
+		}
+
+		if item.Crafting != nil {
+			crafts := int(math.Ceil(float64(need) / float64(max(item.Crafting.Quantity, 1))))
+			for material, perCraft := range item.Crafting.Items {
+				p.Required[material] += perCraft * crafts
+			}
+			steps = append(steps, Step{
+				Action:   ActionCraft,
+				Item:     item,
+				Quantity: crafts * max(item.Crafting.Quantity, 1),
+				Crafts:   crafts,
+			})
+			continue
+		}
+
+		step, ok := source(catalog, item, need)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", game.ErrNoSource, item.Code)
+		}
+		steps = append(steps, step)
+	}
+
+	// Steps were found from the targets down, materials have to come first
+	for i := len(steps) - 1; i >= 0; i-- {
+		p.Steps = append(p.Steps, steps[i])
+	}
+
+	return p, nil
+}
+
+// source picks the resource or monster on the map that drops the item most often
+func source(catalog *game.Catalog, item *game.Item, quantity int) (Step, bool) {
+	best := math.Inf(1)
+	var step Step
+	for _, resource := range catalog.Resources.ResourcesForItem(item) {
+		if len(catalog.Maps.GetResources(resource.Code)) == 0 {
+			continue
+		}
+		if actions := resource.Loot[item.Code].ExpectedActions(); actions < best {
+			best = actions
+			step = Step{Action: ActionGather, Item: item, Quantity: quantity, Resource: resource}
+		}
+	}
+	for _, monster := range catalog.Monsters.MonstersForItem(item) {
+		if len(catalog.Maps.GetMonsters(monster.Code)) == 0 {
+			continue
+		}
+		if actions := monster.Loot[item.Code].ExpectedActions(); actions < best {
+			best = actions
+			step = Step{Action: ActionFight, Item: item, Quantity: quantity, Monster: monster}
+		}
+	}
+	return step, !math.IsInf(best, 1)
+}
+
+func (p *Plan) String() string {
+	var sb strings.Builder
+	for i, step := range p.Steps {
+		fmt.Fprintf(&sb, "%d. %s\n", i+1, step)
+	}
+	return sb.String()
+}
@@ -0,0 +1,103 @@
+package plan
+
+import (
+	"errors"
+	"github.com/ahornerr/artifacts/game"
+	"github.com/promiseofcake/artifactsmmo-go-client/client"
+	"reflect"
+	"testing"
+)
+
+func craftedItem(t *testing.T, code, skill string, materials map[string]int) client.ItemSchema {
+	t.Helper()
+
+	var items []client.SimpleItemSchema
+	for material, quantity := range materials {
+		items = append(items, client.SimpleItemSchema{Code: material, Quantity: quantity})
+	}
+	craftSkill := client.CraftSchemaSkill(skill)
+	level, quantity := 1, 1
+
+	var craft client.ItemSchema_Craft
+	if err := craft.FromCraftSchema(client.CraftSchema{Skill: &craftSkill, Level: &level, Items: &items, Quantity: &quantity}); err != nil {
+		t.Fatal(err)
+	}
+	return client.ItemSchema{Code: code, Name: code, Type: "resource", Level: 1, Craft: &craft}
+}
+
+func tile(t *testing.T, x, y int, contentType, code string) client.MapSchema {
+	t.Helper()
+
+	var content client.MapSchema_Content
+	if err := content.FromMapContentSchema(client.MapContentSchema{Type: contentType, Code: code}); err != nil {
+		t.Fatal(err)
+	}
+	return client.MapSchema{X: x, Y: y, Content: content}
+}
+
+// copperCatalog has a dagger and a ring that are both made from copper bars
+func copperCatalog(t *testing.T) *game.Catalog {
+	t.Helper()
+
+	catalog, err := game.NewCatalog(game.Data{
+		Items: []client.ItemSchema{
+			{Code: "copper_ore", Name: "copper_ore", Type: "resource", Level: 1},
+			craftedItem(t, "copper_bar", "mining", map[string]int{"copper_ore": 6}),
+			craftedItem(t, "copper_dagger", "weaponcrafting", map[string]int{"copper_bar": 6}),
+			craftedItem(t, "copper_ring", "jewelrycrafting", map[string]int{"copper_bar": 4}),
+		},
+		Resources: []client.ResourceSchema{{
+			Code:  "copper_rocks",
+			Name:  "copper_rocks",
+			Skill: "mining",
+			Level: 1,
+			Drops: []client.DropRateSchema{{Code: "copper_ore", Rate: 1, MinQuantity: 1, MaxQuantity: 1}},
+		}},
+		Maps: []client.MapSchema{tile(t, 2, 0, "resource", "copper_rocks")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return catalog
+}
+
+func TestNewSharedIntermediate(t *testing.T) {
+	catalog := copperCatalog(t)
+	item := catalog.Items.Get
+
+	p, err := New(catalog, []game.ItemQuantity{
+		{Item: item("copper_dagger"), Quantity: 1},
+		{Item: item("copper_ring"), Quantity: 1},
+	}, map[string]int{"copper_ore": 5, "copper_bar": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 10 bars between them, less the one we have, is one craft of 9 bars from 54 ore, less the 5 we have
+	want := []Step{
+		{Action: ActionGather, Item: item("copper_ore"), Quantity: 49, Resource: catalog.Resources.Get("copper_rocks")},
+		{Action: ActionCraft, Item: item("copper_bar"), Quantity: 9, Crafts: 9},
+		{Action: ActionCraft, Item: item("copper_dagger"), Quantity: 1, Crafts: 1},
+		{Action: ActionCraft, Item: item("copper_ring"), Quantity: 1, Crafts: 1},
+	}
+	if !reflect.DeepEqual(p.Steps, want) {
+		t.Errorf("steps:\n%s\nwant:\n%s", p, &Plan{Steps: want})
+	}
+
+	if required := p.Required[item("copper_bar")]; required != 10 {
+		t.Errorf("required %d copper bars, want 10", required)
+	}
+	wantStock := map[*game.Item]int{item("copper_ore"): 5, item("copper_bar"): 1}
+	if !reflect.DeepEqual(p.FromStock, wantStock) {
+		t.Errorf("from stock = %v, want %v", p.FromStock, wantStock)
+	}
+}
+
+func TestNewNoSource(t *testing.T) {
+	catalog := copperCatalog(t)
+
+	_, err := New(catalog, []game.ItemQuantity{{Item: &game.Item{Code: "mystery_ore"}, Quantity: 1}}, nil)
+	if !errors.Is(err, game.ErrNoSource) {
+		t.Errorf("got %v, want ErrNoSource for an item nothing drops", err)
+	}
+}
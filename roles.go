@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"github.com/ahornerr/artifacts/character"
+	"github.com/ahornerr/artifacts/config"
+	"github.com/ahornerr/artifacts/jobs"
+	"github.com/ahornerr/artifacts/state"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// roles runs every character's role from the config and swaps roles out when the config changes
+type roles struct {
+	ctx        context.Context
+	characters map[string]*character.Character
+	queue      *jobs.Queue
+	running    map[string]*runningRole
+	mux        sync.Mutex
+}
+
+type runningRole struct {
+	settings roleSettings
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	// ended is set before done is closed when the role finished by itself and left "Error" or "Done" on the stack
+	ended bool
+}
+
+// roleSettings is everything in the config that goes into a character's role. The role is restarted when it changes.
+type roleSettings struct {
+	Character  config.Character
+	Training   state.Training
+	Harvesters int
+	Stockpile  map[string]int
+	Gold       state.GoldRange
+}
+
+func newRoles(ctx context.Context, characters map[string]*character.Character, queue *jobs.Queue) *roles {
+	return &roles{
+		ctx:        ctx,
+		characters: characters,
+		queue:      queue,
+		running:    map[string]*runningRole{},
+	}
+}
+
+// apply starts the roles in the config, restarting characters whose roles changed. Characters can only be added or
+// removed with a restart, anyone who's gone from the config just stops.
+func (r *roles) apply(cfg *config.Config) {
+	for _, c := range cfg.Characters {
+		if _, ok := r.characters[c.Name]; !ok {
+			log.Printf("%s isn't one of the characters the bot started with, restart to add them\n", c.Name)
+		}
+	}
+
+	settings := map[string]roleSettings{}
+	for charName := range r.characters {
+		c, ok := cfg.Character(charName)
+		if !ok {
+			c = config.Character{Name: charName, Role: config.RoleIdle}
+		}
+		settings[charName] = newRoleSettings(cfg, c)
+	}
+
+	// Roles stop at the next chance they get, which might be after whatever they're doing now. They're all told to
+	// stop at once, and waited on without the lock so nothing else has to wait for the slowest of them.
+	r.mux.Lock()
+	stopping := map[string]*runningRole{}
+	for charName, running := range r.running {
+		if reflect.DeepEqual(running.settings, settings[charName]) {
+			delete(settings, charName)
+			continue
+		}
+		running.cancel()
+		stopping[charName] = running
+		delete(r.running, charName)
+	}
+	r.mux.Unlock()
+
+	// The old role's goroutine is the only one touching the character's states until it's done
+	for charName, running := range stopping {
+		<-running.done
+		char := r.characters[charName]
+		if running.ended {
+			char.PopState()
+		}
+		char.PushState("Changing role")
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	for charName := range stopping {
+		r.characters[charName].PopState()
+	}
+
+	for charName, s := range settings {
+		if r.running[charName] != nil {
+			// Another apply started it while we were waiting
+			continue
+		}
+		runner := roleRunner(s, r.characters, r.queue)
+		if runner == nil {
+			continue
+		}
+		r.running[charName] = r.start(r.characters[charName], s, runner)
+	}
+}
+
+func (r *roles) start(char *character.Character, settings roleSettings, runner state.Runner) *runningRole {
+	ctx, cancel := context.WithCancel(r.ctx)
+	running := &runningRole{settings: settings, cancel: cancel, done: make(chan struct{})}
+
+	go func() {
+		defer close(running.done)
+
+		char.PushState("Waiting for cooldown")
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Until(char.CooldownExpires)):
+		}
+		char.PopState()
+		if ctx.Err() != nil {
+			return
+		}
+
+		err := runner(ctx, char)
+		if ctx.Err() != nil {
+			// The role was swapped out
+			return
+		}
+		if err != nil {
+			char.PushState("Error: %s", err)
+		} else {
+			char.PushState("Done")
+		}
+		running.ended = true
+	}()
+
+	return running
+}
+
+func newRoleSettings(cfg *config.Config, c config.Character) roleSettings {
+	training := state.DefaultTraining
+	if cfg.Training.MaxLevel > 0 {
+		training.MaxLevel = cfg.Training.MaxLevel
+	}
+	if len(cfg.Training.LevelMilestones) > 0 {
+		training.LevelMilestones = cfg.Training.LevelMilestones
+	}
+
+	gold := config.DefaultGold
+	if c.Gold != nil {
+		gold = *c.Gold
+	}
+
+	settings := roleSettings{Character: c, Training: training, Gold: state.GoldRange(gold)}
+	if c.Role == config.RoleCrafter {
+		settings.Harvesters = c.Params.Harvesters
+		if settings.Harvesters == 0 {
+			settings.Harvesters = cfg.Harvesters()
+		}
+		settings.Stockpile = cfg.Stockpile
+	}
+	return settings
+}
+
+// roleRunner is nil for characters who don't do anything
+func roleRunner(settings roleSettings, characters map[string]*character.Character, queue *jobs.Queue) state.Runner {
+	switch settings.Character.Role {
+	case config.RoleCrafter:
+		return state.RoleCrafter(characters, queue, state.CrafterParams{
+			Training:   settings.Training,
+			Harvesters: settings.Harvesters,
+			Stockpile:  settings.Stockpile,
+			Gold:       settings.Gold,
+		})
+	case config.RoleHarvester:
+		return state.RoleHarvester(queue, settings.Training, settings.Gold)
+	case config.RoleFighter:
+		return state.Prioritized(state.Fight(settings.Character.Params.Monster, nil, nil), state.GoldTrigger(settings.Gold))
+	case config.RoleTasks:
+		return state.Prioritized(state.Task(nil), state.GoldTrigger(settings.Gold))
+	}
+	return nil
+}
@@ -2,10 +2,19 @@ package state
 
 import (
 	"context"
+	"errors"
 	"github.com/ahornerr/artifacts/character"
-	"github.com/ahornerr/artifacts/game"
+	"time"
 )
 
+// reservationTTL is long enough to walk to the bank from anywhere on the map and deposit everything
+const reservationTTL = 2 * time.Minute
+
+// maxBankRetries is how many times in a row we pick again after another character beats us to items in the bank
+const maxBankRetries = 5
+
+var ErrBankContended = errors.New("other characters keep taking the items we want from the bank")
+
 func Deposit(ctx context.Context, char *character.Character, items map[string]int) error {
 	for itemCode, quantity := range items {
 		_, err := char.DepositBank(ctx, itemCode, quantity)
@@ -21,7 +30,7 @@ func DepositAll(ctx context.Context, char *character.Character) error {
 }
 
 func MoveToBankAndDepositAll(ctx context.Context, char *character.Character) error {
-	err := MoveToClosest(ctx, char, game.Maps.GetBanks())
+	err := MoveToClosest(ctx, char, char.Catalog().Maps.GetBanks())
 	if err != nil {
 		return err
 	}
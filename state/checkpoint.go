@@ -0,0 +1,185 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ahornerr/artifacts/atomicfile"
+	"github.com/ahornerr/artifacts/periodic"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// checkpointWriteInterval is the least time between writes of the checkpoints file. Progress in between is only in
+// memory, so a crash loses at most this much of it.
+const checkpointWriteInterval = 30 * time.Second
+
+// maxCheckpointAge is how long a checkpoint from before a restart is kept waiting for the same state machine to run again
+const maxCheckpointAge = 24 * time.Hour
+
+// Checkpointer is state machine args whose progress can be saved and picked back up after a restart
+type Checkpointer interface {
+	// CheckpointKey says what the state machine is doing, like fight:chicken. Progress is only resumed into args with the same key.
+	CheckpointKey() string
+	MarshalProgress() ([]byte, error)
+	UnmarshalProgress(data []byte) error
+}
+
+type checkpoint struct {
+	Progress json.RawMessage `json:"progress"`
+	Updated  time.Time       `json:"updated"`
+
+	// resumable is only true for checkpoints left by the last run, until they're resumed
+	resumable bool
+}
+
+// Checkpoints are the progress of every character's running state machines, saved to a JSON file every so often
+type Checkpoints struct {
+	path        string
+	checkpoints map[string]map[string]*checkpoint
+
+	// dirty is true when there are changes that haven't been written since lastWrite
+	dirty     bool
+	lastWrite time.Time
+	flush     periodic.Refresher
+
+	mux sync.Mutex
+}
+
+// OpenCheckpoints loads the checkpoints at path, creating it if needed
+func OpenCheckpoints(path string) (*Checkpoints, error) {
+	c := &Checkpoints{
+		path:        path,
+		checkpoints: map[string]map[string]*checkpoint{},
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(b, &c.checkpoints); err != nil {
+		return nil, fmt.Errorf("reading checkpoints from %s: %w", path, err)
+	}
+	for charName, byKey := range c.checkpoints {
+		for key, cp := range byKey {
+			if time.Since(cp.Updated) > maxCheckpointAge {
+				delete(byKey, key)
+				continue
+			}
+			cp.resumable = true
+		}
+		if len(byKey) == 0 {
+			delete(c.checkpoints, charName)
+		}
+	}
+
+	return c, nil
+}
+
+// resume loads progress left by the last run into args, once. It's false if there wasn't any.
+func (c *Checkpoints) resume(charName string, args Checkpointer) (bool, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	cp, ok := c.checkpoints[charName][args.CheckpointKey()]
+	if !ok || !cp.resumable {
+		return false, nil
+	}
+	cp.resumable = false
+
+	return true, args.UnmarshalProgress(cp.Progress)
+}
+
+func (c *Checkpoints) save(charName string, args Checkpointer) error {
+	progress, err := args.MarshalProgress()
+	if err != nil {
+		return err
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.checkpoints[charName] == nil {
+		c.checkpoints[charName] = map[string]*checkpoint{}
+	}
+	c.checkpoints[charName][args.CheckpointKey()] = &checkpoint{Progress: progress, Updated: time.Now()}
+
+	return c.changed()
+}
+
+func (c *Checkpoints) clear(charName string, args Checkpointer) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	key := args.CheckpointKey()
+	if _, ok := c.checkpoints[charName][key]; !ok {
+		return nil
+	}
+	delete(c.checkpoints[charName], key)
+	if len(c.checkpoints[charName]) == 0 {
+		delete(c.checkpoints, charName)
+	}
+
+	return c.changed()
+}
+
+// StartFlushing writes changes left waiting every checkpointWriteInterval, so they're saved even once nothing changes
+func (c *Checkpoints) StartFlushing(ctx context.Context) {
+	c.flush.Start(ctx, checkpointWriteInterval, "checkpoints", func(ctx context.Context) error {
+		return c.Flush()
+	})
+}
+
+// Flush writes any changes that are waiting for checkpointWriteInterval to pass. Call it before exiting.
+func (c *Checkpoints) Flush() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+	return c.write()
+}
+
+// changed writes the checkpoints unless they were written less than checkpointWriteInterval ago, in which case the
+// next change after that writes them. It must be called with the lock held.
+func (c *Checkpoints) changed() error {
+	c.dirty = true
+	if time.Since(c.lastWrite) < checkpointWriteInterval {
+		return nil
+	}
+	return c.write()
+}
+
+// write must be called with the lock held
+func (c *Checkpoints) write() error {
+	b, err := json.MarshalIndent(c.checkpoints, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err = atomicfile.WriteFile(c.path, b); err != nil {
+		return err
+	}
+	c.dirty = false
+	c.lastWrite = time.Now()
+	return nil
+}
+
+type checkpointsKey struct{}
+
+// WithCheckpoints makes every Run under ctx checkpoint its progress and resume from the last run's checkpoint
+func WithCheckpoints(ctx context.Context, checkpoints *Checkpoints) context.Context {
+	return context.WithValue(ctx, checkpointsKey{}, checkpoints)
+}
+
+func checkpointsFrom(ctx context.Context) *Checkpoints {
+	checkpoints, _ := ctx.Value(checkpointsKey{}).(*Checkpoints)
+	return checkpoints
+}
@@ -18,8 +18,8 @@ type CollectItemsArgs struct {
 
 // CollectItems collects the materials required to craft the item in the desired quantity
 func CollectItems(itemCode string, quantity int, includeBank bool, includeAllInventoriesAndEquipment bool, characters map[string]*character.Character) Runner {
-	item := game.Items.Get(itemCode)
 	return func(ctx context.Context, char *character.Character) error {
+		item := char.Catalog().Items.Get(itemCode)
 		err := Run(ctx, char, CollectItemsLoop, NewCollectItemsArgs(item, quantity, includeBank, includeAllInventoriesAndEquipment, characters))
 		if err != nil {
 			return CollectErr{
@@ -71,14 +71,14 @@ func CollectItemsLoop(ctx context.Context, char *character.Character, args *Coll
 	defer char.PopState()
 
 	// Item comes from a resource
-	resources := game.Resources.ResourcesForItem(item)
+	resources := char.Catalog().Resources.ResourcesForItem(item)
 	if len(resources) > 0 {
 		if len(resources) > 1 {
 			// TODO: If this is possible, figure out which one is easier to get
 			log.Println("Found multiple resources for item", item.Code)
 		}
 		resource := resources[0]
-		rate := resource.Loot[item].Rate
+		rate := resource.Loot[item.Code].Rate
 		if rate != 1 {
 			char.PopState()
 			char.PushState("Harvesting %d %s from %s (1/%d)", need, item.Name, resource.Name, rate)
@@ -97,19 +97,19 @@ func CollectItemsLoop(ctx context.Context, char *character.Character, args *Coll
 		}
 	}
 
-	monsters := game.Monsters.MonstersForItem(item)
+	monsters := char.Catalog().Monsters.MonstersForItem(item)
 	if len(monsters) > 0 {
 		if len(monsters) > 1 {
 			// TODO: If this is possible, figure out which one is easier to get
 			log.Println("Found multiple monsters for item", item.Code)
 		}
 		monster := monsters[0]
-		rate := monster.Loot[item].Rate
+		rate := monster.Loot[item.Code].Rate
 		if rate != 1 {
 			char.PopState()
 			char.PushState("Fighting %s for %d %s (1/%d)", monster.Name, need, item.Name, rate)
 		}
-		fightArgs := NewFightArgs(monster.Code, func(c *character.Character, _ *FightArgs) bool {
+		fightArgs := NewFightArgs(char.Catalog(), monster.Code, func(c *character.Character, _ *FightArgs) bool {
 			have := char.Inventory[item.Code]
 			if args.includeBank {
 				have += char.Bank()[item.Code]
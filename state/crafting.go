@@ -2,7 +2,10 @@ package state
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/ahornerr/artifacts/bank"
 	"github.com/ahornerr/artifacts/character"
 	"github.com/ahornerr/artifacts/game"
 	"github.com/ahornerr/artifacts/httperror"
@@ -20,17 +23,43 @@ type CraftingArgs struct {
 	Crafted map[string]int
 
 	stop func(*character.Character, *CraftingArgs) bool
+
+	// retries is how many times in a row the materials went missing from the bank
+	retries int
+}
+
+type craftingProgress struct {
+	Made    int            `json:"made"`
+	Xp      int            `json:"xp"`
+	Crafted map[string]int `json:"crafted"`
+}
+
+func (c *CraftingArgs) CheckpointKey() string {
+	return fmt.Sprintf("craft:%s:%d", c.Item.Code, c.Quantity)
+}
+
+func (c *CraftingArgs) MarshalProgress() ([]byte, error) {
+	return json.Marshal(craftingProgress{Made: c.Made, Xp: c.Xp, Crafted: c.Crafted})
+}
+
+func (c *CraftingArgs) UnmarshalProgress(data []byte) error {
+	progress := craftingProgress{Crafted: c.Crafted}
+	if err := json.Unmarshal(data, &progress); err != nil {
+		return err
+	}
+	c.Made, c.Xp, c.Crafted = progress.Made, progress.Xp, progress.Crafted
+	return nil
 }
 
 func Craft(itemCode string, quantity int, bankWhenDone bool, stop func(*character.Character, *CraftingArgs) bool) Runner {
 	return func(ctx context.Context, char *character.Character) error {
-		return Run(ctx, char, CraftingLoop, NewCraftArgs(itemCode, quantity, bankWhenDone, stop))
+		return Run(ctx, char, CraftingLoop, NewCraftArgs(char.Catalog(), itemCode, quantity, bankWhenDone, stop))
 	}
 }
 
-func NewCraftArgs(itemCode string, quantity int, bankWhenDone bool, stop func(*character.Character, *CraftingArgs) bool) *CraftingArgs {
+func NewCraftArgs(catalog *game.Catalog, itemCode string, quantity int, bankWhenDone bool, stop func(*character.Character, *CraftingArgs) bool) *CraftingArgs {
 	return &CraftingArgs{
-		Item:         game.Items.Get(itemCode),
+		Item:         catalog.Items.Get(itemCode),
 		Quantity:     quantity,
 		BankWhenDone: bankWhenDone,
 		Crafted:      map[string]int{},
@@ -99,22 +128,33 @@ func CraftingLoop(ctx context.Context, char *character.Character, args *Crafting
 	}
 
 	if len(toWithdraw) > 0 {
-		err := MoveToBankAndDepositAll(ctx, char)
+		// Claim the materials before walking over so another character doesn't plan on using them
+		reservation, err := char.ReserveBank(toWithdraw, reservationTTL)
+		if err != nil {
+			if errors.Is(err, bank.ErrInsufficientStock) {
+				// Someone reserved them since we looked, try again with what's left
+				return args.retry()
+			}
+			return nil, err
+		}
+		defer char.ReleaseBank(reservation)
+
+		err = MoveToBankAndDepositAll(ctx, char)
 		if err != nil {
 			return nil, err
 		}
 		err = WithdrawItems(ctx, char, toWithdraw)
 		if err != nil {
-			// Since we don't lock the bank, it's possible that another character took the items we needed
+			// The reservation could have expired, or the bank changed some other way
 			if httperror.ErrIsBankInsufficientQuantity(err) || httperror.ErrIsBankItemNotFound(err) {
-				return CraftingLoop, nil
+				return args.retry()
 			}
 			return nil, err
 		}
 	}
 
 	// Move to the closest workshop
-	err := MoveToClosest(ctx, char, game.Maps.GetWorkshops(args.Item.Crafting.Skill))
+	err := MoveToClosest(ctx, char, char.Catalog().Maps.GetWorkshops(args.Item.Crafting.Skill))
 	if err != nil {
 		return nil, err
 	}
@@ -125,6 +165,7 @@ func CraftingLoop(ctx context.Context, char *character.Character, args *Crafting
 		return nil, err
 	}
 
+	args.retries = 0
 	args.Made += numToCraft
 	args.Xp += result.Xp
 	for _, drop := range result.Items {
@@ -134,6 +175,14 @@ func CraftingLoop(ctx context.Context, char *character.Character, args *Crafting
 	return CraftingLoop, nil
 }
 
+func (c *CraftingArgs) retry() (State[*CraftingArgs], error) {
+	if c.retries >= maxBankRetries {
+		return nil, ErrBankContended
+	}
+	c.retries++
+	return CraftingLoop, nil
+}
+
 // Calculate the maximum number of items we can craft given the resources in our inventory and bank
 func getNumCanCraft(crafting *game.Crafting, inventory map[string]int, bank map[string]int) (totalCraftable int, inventoryCraftable int) {
 	totalCraftable = math.MaxInt32
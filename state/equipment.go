@@ -3,6 +3,7 @@ package state
 import (
 	"context"
 	"errors"
+	"github.com/ahornerr/artifacts/bank"
 	"github.com/ahornerr/artifacts/character"
 	"github.com/ahornerr/artifacts/game"
 	"github.com/ahornerr/artifacts/httperror"
@@ -11,38 +12,95 @@ import (
 
 var ErrFightUnwinnable = errors.New("fight unwinnable with current equipment")
 
-func EquipBestEquipment(ctx context.Context, char *character.Character, targetStats *game.Stats) error {
-	bestEquipment := char.GetBestOwnedEquipment(targetStats)
-	if bestEquipment.TurnsToKillMonster > bestEquipment.TurnsToKillPlayer {
+// MinWinProbability is how sure the combat simulator needs to be before we pick a fight
+const MinWinProbability = 0.9
+
+func EquipBestEquipment(ctx context.Context, char *character.Character, targetStats *game.Stats, objective character.Objective) error {
+	return equipBestEquipment(ctx, char, targetStats, objective, 0)
+}
+
+// equipBestEquipment picks again when the bank changes under it, up to maxBankRetries times
+func equipBestEquipment(ctx context.Context, char *character.Character, targetStats *game.Stats, objective character.Objective, retries int) error {
+	retry := func() error {
+		if retries >= maxBankRetries {
+			return ErrBankContended
+		}
+		return equipBestEquipment(ctx, char, targetStats, objective, retries+1)
+	}
+
+	bestEquipment := char.GetBestOwnedEquipment(targetStats, objective)
+	if bestEquipment.Outcome.WinProbability < MinWinProbability {
 		return ErrFightUnwinnable
 	}
 
+	var slotsToUnequip []string
+	unequipCount := 0
+	// Whatever comes off can go straight back on in another slot, like an artifact moving from artifact1 to artifact2
+	unequipped := map[string]int{}
+
+	for slot, item := range bestEquipment.Equipment {
+		if char.Equipment[slot] == item.Code || char.Equipment[slot] == "" {
+			continue
+		}
+		slotsToUnequip = append(slotsToUnequip, slot)
+		// Consumable slots hold a stack that all comes back to the inventory
+		quantity := max(1, char.EquipmentQuantity[slot])
+		unequipCount += quantity
+		unequipped[char.Equipment[slot]] += quantity
+	}
+
 	upgradesInInventory := map[string]*game.Item{}
+	upgradesFromSlots := map[string]*game.Item{}
 	upgradesInBank := map[string]*game.Item{}
-	var slotsToUnequip []string
+	inventory := map[string]int{}
 
 	for slot, item := range bestEquipment.Equipment {
 		if char.Equipment[slot] == item.Code {
 			continue
 		}
-		if char.Equipment[slot] != "" {
-			slotsToUnequip = append(slotsToUnequip, slot)
-		}
-		if char.Inventory[item.Code] > 0 {
+		quantity := bestEquipment.Quantity(slot)
+		switch {
+		case char.Inventory[item.Code]-inventory[item.Code] >= quantity:
 			upgradesInInventory[slot] = item
-		} else {
+			inventory[item.Code] += quantity
+		case unequipped[item.Code] >= quantity:
+			upgradesFromSlots[slot] = item
+			unequipped[item.Code] -= quantity
+		default:
 			upgradesInBank[slot] = item
 		}
 	}
 
-	if len(upgradesInInventory) == 0 && len(upgradesInBank) == 0 {
+	if len(upgradesInInventory) == 0 && len(upgradesFromSlots) == 0 && len(upgradesInBank) == 0 {
 		return nil
 	}
 
 	char.PushState("Upgrading equipment")
 	defer char.PopState()
 
-	bankBecauseInventoryFull := len(slotsToUnequip) > char.MaxInventoryItems()-char.InventoryCount()
+	if len(upgradesInBank) > 0 {
+		toReserve := map[string]int{}
+		for slot, item := range upgradesInBank {
+			toReserve[item.Code] += bestEquipment.Quantity(slot)
+		}
+		// Some of it can be in the inventory, that gets deposited before withdrawing
+		bankItems := char.Bank()
+		for itemCode, quantity := range toReserve {
+			toReserve[itemCode] = min(quantity, bankItems[itemCode])
+		}
+
+		reservation, err := char.ReserveBank(toReserve, reservationTTL)
+		if err != nil {
+			if errors.Is(err, bank.ErrInsufficientStock) {
+				// Another character got to it first, pick again from what's left
+				return retry()
+			}
+			return err
+		}
+		defer char.ReleaseBank(reservation)
+	}
+
+	bankBecauseInventoryFull := unequipCount > char.MaxInventoryItems()-char.InventoryCount()
 	needToBank := len(upgradesInBank) > 0 || bankBecauseInventoryFull
 	if needToBank {
 		err := MoveToBankAndDepositAll(ctx, char)
@@ -58,15 +116,23 @@ func EquipBestEquipment(ctx context.Context, char *character.Character, targetSt
 		}
 	}
 
+	// Unequipping happens after banking, so these are still in the inventory
+	for slot, item := range upgradesFromSlots {
+		err := char.Equip(ctx, client.EquipSchemaSlot(slot), item.Code)
+		if err != nil {
+			return err
+		}
+	}
+
 	for slot, item := range upgradesInBank {
-		err := Withdraw(ctx, char, item.Code, 1)
+		err := Withdraw(ctx, char, item.Code, bestEquipment.Quantity(slot))
 		if err != nil {
 			if httperror.ErrIsBankItemNotFound(err) {
 				err = MoveToBankAndDepositAll(ctx, char)
 				if err != nil {
 					return err
 				}
-				return EquipBestEquipment(ctx, char, targetStats)
+				return retry()
 			}
 			return err
 		}
@@ -79,14 +145,14 @@ func EquipBestEquipment(ctx context.Context, char *character.Character, targetSt
 
 	for slot, item := range upgradesInInventory {
 		if needToBank {
-			err := Withdraw(ctx, char, item.Code, 1)
+			err := Withdraw(ctx, char, item.Code, bestEquipment.Quantity(slot))
 			if err != nil {
 				if httperror.ErrIsBankItemNotFound(err) {
 					err = MoveToBankAndDepositAll(ctx, char)
 					if err != nil {
 						return err
 					}
-					return EquipBestEquipment(ctx, char, targetStats)
+					return retry()
 				}
 				return err
 			}
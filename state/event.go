@@ -37,7 +37,7 @@ func EventLoop(ctx context.Context, char *character.Character, args *EventArgs)
 	char.PushState("Doing event")
 	defer char.PopState()
 
-	//for eventType, eventCodes := range game.Events.Events() {
+	//for eventType, eventCodes := range char.Catalog().Events.Events() {
 	//	for eventCode, locations := range eventCodes {
 	//
 	//	}
@@ -45,7 +45,7 @@ func EventLoop(ctx context.Context, char *character.Character, args *EventArgs)
 	//
 	//// Complete task
 	//if char.Task != "" && char.TaskProgress == char.TaskTotal {
-	//	err := MoveToClosest(ctx, char, game.Maps.GetTaskMasters("monsters"))
+	//	err := MoveToClosest(ctx, char, char.Catalog().Maps.GetTaskMasters("monsters"))
 	//	if err != nil {
 	//		return nil, err
 	//	}
@@ -60,7 +60,7 @@ func EventLoop(ctx context.Context, char *character.Character, args *EventArgs)
 	//
 	//// Get new task
 	//if char.Task == "" {
-	//	err := MoveToClosest(ctx, char, game.Maps.GetTaskMasters("monsters"))
+	//	err := MoveToClosest(ctx, char, char.Catalog().Maps.GetTaskMasters("monsters"))
 	//	if err != nil {
 	//		return nil, err
 	//	}
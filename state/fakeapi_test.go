@@ -0,0 +1,109 @@
+package state
+
+import (
+	"context"
+	"github.com/ahornerr/artifacts/bank"
+	"github.com/ahornerr/artifacts/character"
+	"github.com/ahornerr/artifacts/client"
+	"github.com/ahornerr/artifacts/fakeapi"
+	"github.com/ahornerr/artifacts/game"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newFakeCharacter is a character in fakeapi's default world, with cooldowns shrunk so runners finish quickly
+func newFakeCharacter(t *testing.T, ctx context.Context, name string) (*character.Character, *fakeapi.Server) {
+	t.Helper()
+
+	world, err := fakeapi.DefaultWorld()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := fakeapi.New(world, fakeapi.Options{CooldownScale: 0.0001, Seed: 1})
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(ts.Close)
+
+	c, err := client.NewWithServer(ts.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	catalog, err := game.LoadCatalog(ctx, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	theBank := bank.NewBank(c)
+	if _, err = theBank.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	updates := make(chan *character.Character)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-updates:
+			}
+		}
+	}()
+
+	char := character.NewCharacter(c, theBank, catalog, updates, name)
+	if _, err = char.Get(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	return char, server
+}
+
+func TestHarvestAgainstFakeAPI(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	char, server := newFakeCharacter(t, ctx, "curlyBoy2")
+
+	err := Harvest("copper_rocks", func(_ *character.Character, args *HarvestArgs) bool {
+		return args.Count >= 3
+	})(ctx, char)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake, _ := server.Character("curlyBoy2")
+	ore := 0
+	for _, slot := range fake.Inventory {
+		if slot.Code == "copper_ore" {
+			ore = slot.Quantity
+		}
+	}
+	if ore != 3 {
+		t.Errorf("server has %d copper ore in the inventory, want 3", ore)
+	}
+	if char.Inventory["copper_ore"] != ore {
+		t.Errorf("character thinks it has %d copper ore, the server says %d", char.Inventory["copper_ore"], ore)
+	}
+}
+
+func TestFightAgainstFakeAPI(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	char, server := newFakeCharacter(t, ctx, "curlyBoy1")
+
+	args := NewFightArgs(char.Catalog(), "chicken", func(_ *character.Character, args *FightArgs) bool {
+		return args.NumFights() >= 2
+	}, nil)
+	if err := Run(ctx, char, FightLoop, args); err != nil {
+		t.Fatal(err)
+	}
+
+	if args.NumFights() != 2 {
+		t.Fatalf("fought %d times, want 2", args.NumFights())
+	}
+	fake, _ := server.Character("curlyBoy1")
+	if args.NumLosses() == 0 && (fake.X != 0 || fake.Y != 1) {
+		t.Errorf("character is at %d,%d, not the chicken", fake.X, fake.Y)
+	}
+}
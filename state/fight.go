@@ -2,13 +2,13 @@ package state
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/ahornerr/artifacts/character"
 	"github.com/ahornerr/artifacts/game"
 	"github.com/ahornerr/artifacts/httperror"
 	"github.com/promiseofcake/artifactsmmo-go-client/client"
 	"log"
-	"reflect"
 	"strings"
 )
 
@@ -19,9 +19,12 @@ type FightArgs struct {
 	Xp      int
 	Results []client.FightSchemaResult
 
-	lastBank map[string]int
-	stop     func(*character.Character, *FightArgs) bool
-	bankWhen func(*character.Character, *FightArgs) bool
+	// Objective for picking equipment, the zero value kills fastest
+	Objective character.Objective
+
+	lastBankVersion int
+	stop            func(*character.Character, *FightArgs) bool
+	bankWhen        func(*character.Character, *FightArgs) bool
 }
 
 func (t *FightArgs) NumFights() int {
@@ -48,18 +51,45 @@ func (t *FightArgs) NumLosses() int {
 	return num
 }
 
+type fightProgress struct {
+	Drops   map[string]int             `json:"drops"`
+	Gold    int                        `json:"gold"`
+	Xp      int                        `json:"xp"`
+	Results []client.FightSchemaResult `json:"results"`
+}
+
+func (t *FightArgs) CheckpointKey() string {
+	return "fight:" + t.Monster.Code
+}
+
+func (t *FightArgs) MarshalProgress() ([]byte, error) {
+	return json.Marshal(fightProgress{Drops: t.Drops, Gold: t.Gold, Xp: t.Xp, Results: t.Results})
+}
+
+func (t *FightArgs) UnmarshalProgress(data []byte) error {
+	progress := fightProgress{Drops: t.Drops}
+	if err := json.Unmarshal(data, &progress); err != nil {
+		return err
+	}
+	t.Drops, t.Gold, t.Xp, t.Results = progress.Drops, progress.Gold, progress.Xp, progress.Results
+	return nil
+}
+
 func Fight(monsterCode string, stop func(*character.Character, *FightArgs) bool, bankWhen func(*character.Character, *FightArgs) bool) Runner {
 	return func(ctx context.Context, char *character.Character) error {
-		return Run(ctx, char, FightLoop, NewFightArgs(monsterCode, stop, bankWhen))
+		return Run(ctx, char, FightLoop, NewFightArgs(char.Catalog(), monsterCode, stop, bankWhen))
 	}
 }
 
-func NewFightArgs(monsterCode string, stop func(*character.Character, *FightArgs) bool, bankWhen func(*character.Character, *FightArgs) bool) *FightArgs {
+func NewFightArgs(catalog *game.Catalog, monsterCode string, stop func(*character.Character, *FightArgs) bool, bankWhen func(*character.Character, *FightArgs) bool) *FightArgs {
 	return &FightArgs{
-		Monster:  game.Monsters.Get(monsterCode),
+		Monster:  catalog.Monsters.Get(monsterCode),
 		Drops:    map[string]int{},
 		stop:     stop,
 		bankWhen: bankWhen,
+
+		// Always pick equipment before the first fight
+		lastBankVersion: -1,
 	}
 }
 
@@ -82,13 +112,13 @@ func FightLoop(ctx context.Context, char *character.Character, args *FightArgs)
 			fmt.Sprintf("%d XP", args.Xp),
 		}
 		for itemCode, count := range args.Drops {
-			drops = append(drops, fmt.Sprintf("%d %s", count, game.Items.Get(itemCode).Name))
+			drops = append(drops, fmt.Sprintf("%d %s", count, char.Catalog().Items.Get(itemCode).Name))
 		}
 		char.PushState("Got %s", strings.Join(drops, ", "))
 		defer char.PopState()
 	}
 
-	locations := game.Maps.GetMonsters(args.Monster.Code)
+	locations := char.Catalog().Maps.GetMonsters(args.Monster.Code)
 	if len(locations) == 0 {
 		log.Println("No locations found for monster", args.Monster.Name)
 		return nil, nil
@@ -103,13 +133,13 @@ func FightLoop(ctx context.Context, char *character.Character, args *FightArgs)
 	}
 
 	// Equip best equipment
-	if !reflect.DeepEqual(args.lastBank, char.Bank()) {
-		err := EquipBestEquipment(ctx, char, args.Monster.Stats)
+	if args.lastBankVersion != char.BankVersion() {
+		err := EquipBestEquipment(ctx, char, args.Monster.Stats, args.Objective)
 		if err != nil {
 			log.Println(char.Name, err)
 			return nil, nil
 		}
-		args.lastBank = char.Bank()
+		args.lastBankVersion = char.BankVersion()
 	}
 
 	// Move to the closest monster
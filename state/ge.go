@@ -0,0 +1,179 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ahornerr/artifacts/bank"
+	"github.com/ahornerr/artifacts/character"
+	"github.com/ahornerr/artifacts/game"
+	"github.com/ahornerr/artifacts/ge"
+	"github.com/ahornerr/artifacts/httperror"
+)
+
+var ErrOverBudget = errors.New("buying would go over budget")
+
+// SellSurplus sells everything in the bank over what keep says to hold on to, for items the grand exchange buys
+func SellSurplus(exchange *ge.Exchange, keep func(item *game.Item) int) Runner {
+	return func(ctx context.Context, char *character.Character) error {
+		char.PushState("Selling surplus")
+		defer char.PopState()
+
+		for itemCode, quantity := range char.Bank() {
+			if _, ok := exchange.SellPrice(itemCode); !ok {
+				continue
+			}
+
+			surplus := quantity - keep(char.Catalog().Items.Get(itemCode))
+			for surplus > 0 {
+				toSell := min(surplus, char.MaxInventoryItems())
+
+				reservation, err := char.ReserveBank(map[string]int{itemCode: toSell}, reservationTTL)
+				if errors.Is(err, bank.ErrInsufficientStock) {
+					// Someone else has plans for it
+					break
+				} else if err != nil {
+					return err
+				}
+
+				err = MoveToBankAndDepositAll(ctx, char)
+				if err == nil {
+					err = Withdraw(ctx, char, itemCode, toSell)
+				}
+				char.ReleaseBank(reservation)
+				if err != nil {
+					return err
+				}
+
+				err = MoveToClosest(ctx, char, char.Catalog().Maps.GetGrandExchanges())
+				if err != nil {
+					return err
+				}
+
+				err = sellInventory(ctx, char, exchange, itemCode)
+				if err != nil {
+					return err
+				}
+
+				surplus -= toSell
+			}
+		}
+
+		return MoveToBankAndDepositAll(ctx, char)
+	}
+}
+
+// sellInventory sells all of the item in the character's inventory, as long as the grand exchange buys it
+func sellInventory(ctx context.Context, char *character.Character, exchange *ge.Exchange, itemCode string) error {
+	for char.Inventory[itemCode] > 0 {
+		price, ok := exchange.Price(itemCode)
+		if !ok || price.SellPrice <= 0 {
+			return nil
+		}
+
+		_, err := char.SellGE(ctx, itemCode, min(char.Inventory[itemCode], max(1, price.MaxQuantity)), price.SellPrice)
+		if httperror.ErrIsGEPriceChanged(err) {
+			err = exchange.Load(ctx)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BuyMaterials buys whatever the character doesn't already have, between the inventory and bank, to craft quantity
+// of the item. It doesn't buy anything unless it can get everything for no more than budget gold.
+func BuyMaterials(exchange *ge.Exchange, item *game.Item, quantity int, budget int) Runner {
+	return func(ctx context.Context, char *character.Character) error {
+		if item.Crafting == nil {
+			return fmt.Errorf("%s can't be crafted", item.Code)
+		}
+
+		bankItems := char.Bank()
+		missing := map[string]int{}
+		total := 0
+		for material, perCraft := range item.Crafting.Items {
+			need := perCraft*quantity - char.Inventory[material.Code] - bankItems[material.Code]
+			if need <= 0 {
+				continue
+			}
+
+			price, ok := exchange.BuyPrice(material.Code)
+			if !ok {
+				return fmt.Errorf("grand exchange isn't selling %s", material.Code)
+			}
+			missing[material.Code] = need
+			total += need * price
+		}
+
+		if total > budget {
+			return fmt.Errorf("%w: %d gold for %s, budget is %d", ErrOverBudget, total, item.Code, budget)
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+
+		char.PushState("Buying materials for %d %s", quantity, item.Name)
+		defer char.PopState()
+
+		for itemCode, need := range missing {
+			err := buy(ctx, char, exchange, itemCode, need)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// buy gets quantity of the item from the grand exchange, going to the bank for gold or to make room as needed
+func buy(ctx context.Context, char *character.Character, exchange *ge.Exchange, itemCode string, quantity int) error {
+	for quantity > 0 {
+		price, ok := exchange.Price(itemCode)
+		if !ok || price.BuyPrice <= 0 || price.Stock <= 0 {
+			return fmt.Errorf("grand exchange isn't selling %s", itemCode)
+		}
+
+		n := min(quantity, max(1, price.MaxQuantity), price.Stock, char.MaxInventoryItems()-char.InventoryCount())
+		if n <= 0 || char.Gold < n*price.BuyPrice {
+			// Deposit directly instead of MoveToBankAndDepositAll, it would put away the gold we're about to spend
+			err := MoveToClosest(ctx, char, char.Catalog().Maps.GetBanks())
+			if err != nil {
+				return err
+			}
+			err = DepositAll(ctx, char)
+			if err != nil {
+				return err
+			}
+
+			n = min(quantity, max(1, price.MaxQuantity), price.Stock, char.MaxInventoryItems()-char.InventoryCount())
+			if short := n*price.BuyPrice - char.Gold; short > 0 {
+				_, err = char.WithdrawBankGold(ctx, short)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		err := MoveToClosest(ctx, char, char.Catalog().Maps.GetGrandExchanges())
+		if err != nil {
+			return err
+		}
+
+		transaction, err := char.BuyGE(ctx, itemCode, n, price.BuyPrice)
+		if httperror.ErrIsGEPriceChanged(err) {
+			err = exchange.Load(ctx)
+			if err != nil {
+				return err
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		quantity -= transaction.Quantity
+	}
+	return nil
+}
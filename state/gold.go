@@ -0,0 +1,53 @@
+package state
+
+import (
+	"context"
+	"github.com/ahornerr/artifacts/character"
+	"github.com/ahornerr/artifacts/httperror"
+)
+
+// GoldRange is how much gold a character should carry. Anything over Max goes to the bank, and a character
+// with less than Min takes what it can from the bank to get back up to it.
+type GoldRange struct {
+	Min int
+	Max int
+}
+
+// KeepGoldInRange deposits or withdraws gold so the character's gold is within r. The character has to be at the bank.
+func KeepGoldInRange(ctx context.Context, char *character.Character, r GoldRange) error {
+	switch {
+	case char.Gold > r.Max:
+		_, err := char.DepositBankGold(ctx, char.Gold-r.Max)
+		return err
+	case char.Gold < r.Min:
+		quantity := min(r.Min-char.Gold, char.BankGold())
+		if quantity <= 0 {
+			return nil
+		}
+		_, err := char.WithdrawBankGold(ctx, quantity)
+		if httperror.ErrIsInsufficientGold(err) {
+			// Someone else got to it first, we'll try again next time
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// GoldTrigger goes to the bank when the character's gold is out of r and the bank can do something about it
+func GoldTrigger(r GoldRange) Trigger {
+	return Trigger{
+		Name:     "gold",
+		Priority: PriorityHousekeeping,
+		When: func(c *character.Character) bool {
+			return c.Gold > r.Max || (c.Gold < r.Min && c.BankGold() > 0)
+		},
+		Runner: func(ctx context.Context, char *character.Character) error {
+			err := MoveToClosest(ctx, char, char.Catalog().Maps.GetBanks())
+			if err != nil {
+				return err
+			}
+			return KeepGoldInRange(ctx, char, r)
+		},
+	}
+}
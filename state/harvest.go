@@ -2,6 +2,7 @@ package state
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/ahornerr/artifacts/character"
 	"github.com/ahornerr/artifacts/game"
@@ -20,20 +21,63 @@ type HarvestArgs struct {
 	stop func(*character.Character, *HarvestArgs) bool
 }
 
+type harvestProgress struct {
+	Count int            `json:"count"`
+	Drops map[string]int `json:"drops"`
+	Xp    int            `json:"xp"`
+}
+
+func (h *HarvestArgs) CheckpointKey() string {
+	return "harvest:" + h.Resource.Code
+}
+
+func (h *HarvestArgs) MarshalProgress() ([]byte, error) {
+	return json.Marshal(harvestProgress{Count: h.Count, Drops: h.Drops, Xp: h.Xp})
+}
+
+func (h *HarvestArgs) UnmarshalProgress(data []byte) error {
+	progress := harvestProgress{Drops: h.Drops}
+	if err := json.Unmarshal(data, &progress); err != nil {
+		return err
+	}
+	h.Count, h.Drops, h.Xp = progress.Count, progress.Drops, progress.Xp
+	return nil
+}
+
 func Harvest(resourceCode string, stop func(*character.Character, *HarvestArgs) bool) Runner {
 	return func(ctx context.Context, char *character.Character) error {
-		return Run(ctx, char, HarvestLoop, NewHarvestArgs(resourceCode, stop))
+		return Run(ctx, char, HarvestLoop, NewHarvestArgs(char.Catalog(), resourceCode, stop))
 	}
 }
 
-func NewHarvestArgs(resourceCode string, stop func(*character.Character, *HarvestArgs) bool) *HarvestArgs {
+func NewHarvestArgs(catalog *game.Catalog, resourceCode string, stop func(*character.Character, *HarvestArgs) bool) *HarvestArgs {
 	return &HarvestArgs{
-		Resource: game.Resources.Get(resourceCode),
+		Resource: catalog.Resources.Get(resourceCode),
 		Drops:    map[string]int{},
 		stop:     stop,
 	}
 }
 
+// GatheringStats are what the equipment optimizer targets to find the best tool for a resource.
+// It's nil for skills we don't gather.
+func GatheringStats(resource *game.Resource) *game.Stats {
+	skillStats := &game.Stats{
+		IsResource: true,
+		Hp:         20,
+	}
+	switch resource.Skill {
+	case "woodcutting":
+		skillStats.ResistWoodcutting = math.MinInt8
+	case "mining":
+		skillStats.ResistMining = math.MinInt8
+	case "fishing":
+		skillStats.ResistFishing = math.MinInt8
+	default:
+		return nil
+	}
+	return skillStats
+}
+
 func HarvestLoop(ctx context.Context, char *character.Character, args *HarvestArgs) (State[*HarvestArgs], error) {
 	// Repeat until stop condition
 	if args.stop != nil && args.stop(char, args) {
@@ -45,7 +89,7 @@ func HarvestLoop(ctx context.Context, char *character.Character, args *HarvestAr
 		return nil, nil
 	}
 
-	locations := game.Maps.GetResources(args.Resource.Code)
+	locations := char.Catalog().Maps.GetResources(args.Resource.Code)
 	if len(locations) == 0 {
 		log.Println("No locations found for resource", args.Resource.Name)
 		return nil, nil
@@ -59,7 +103,7 @@ func HarvestLoop(ctx context.Context, char *character.Character, args *HarvestAr
 			fmt.Sprintf("%d XP", args.Xp),
 		}
 		for itemCode, count := range args.Drops {
-			drops = append(drops, fmt.Sprintf("%d %s", count, game.Items.Get(itemCode).Name))
+			drops = append(drops, fmt.Sprintf("%d %s", count, char.Catalog().Items.Get(itemCode).Name))
 		}
 		char.PushState("Got %s", strings.Join(drops, ", "))
 		defer char.PopState()
@@ -74,21 +118,11 @@ func HarvestLoop(ctx context.Context, char *character.Character, args *HarvestAr
 	}
 
 	// Equip best equipment
-	skillStats := &game.Stats{
-		IsResource: true,
-		Hp:         20,
-	}
-	switch args.Resource.Skill {
-	case "woodcutting":
-		skillStats.ResistWoodcutting = math.MinInt8
-	case "mining":
-		skillStats.ResistMining = math.MinInt8
-	case "fishing":
-		skillStats.ResistFishing = math.MinInt8
-	default:
+	skillStats := GatheringStats(args.Resource)
+	if skillStats == nil {
 		panic(args.Resource.Skill)
 	}
-	err := EquipBestEquipment(ctx, char, skillStats)
+	err := EquipBestEquipment(ctx, char, skillStats, character.Objective{Profile: character.ProfileGathering})
 	if err != nil {
 		return nil, err
 	}
@@ -2,6 +2,8 @@ package state
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/ahornerr/artifacts/character"
 	"github.com/ahornerr/artifacts/game"
 	"log"
@@ -16,9 +18,30 @@ type MakeXArgs struct {
 	Made int
 }
 
-func NewMakeXArgs(itemCode string, quantity int, recycle bool, stop func(character *character.Character, args *MakeXArgs) bool) *MakeXArgs {
+type makeXProgress struct {
+	Made int `json:"made"`
+}
+
+func (m *MakeXArgs) CheckpointKey() string {
+	return fmt.Sprintf("make_x:%s:%d", m.Item.Code, m.Quantity)
+}
+
+func (m *MakeXArgs) MarshalProgress() ([]byte, error) {
+	return json.Marshal(makeXProgress{Made: m.Made})
+}
+
+func (m *MakeXArgs) UnmarshalProgress(data []byte) error {
+	var progress makeXProgress
+	if err := json.Unmarshal(data, &progress); err != nil {
+		return err
+	}
+	m.Made = progress.Made
+	return nil
+}
+
+func NewMakeXArgs(catalog *game.Catalog, itemCode string, quantity int, recycle bool, stop func(character *character.Character, args *MakeXArgs) bool) *MakeXArgs {
 	return &MakeXArgs{
-		Item:     game.Items.Get(itemCode),
+		Item:     catalog.Items.Get(itemCode),
 		Quantity: quantity,
 		Recycle:  recycle,
 		stop:     stop,
@@ -27,7 +50,7 @@ func NewMakeXArgs(itemCode string, quantity int, recycle bool, stop func(charact
 
 func MakeX(itemCode string, quantity int, recycle bool, stop func(character *character.Character, args *MakeXArgs) bool) Runner {
 	return func(ctx context.Context, char *character.Character) error {
-		return Run(ctx, char, MakeXLoop, NewMakeXArgs(itemCode, quantity, recycle, stop))
+		return Run(ctx, char, MakeXLoop, NewMakeXArgs(char.Catalog(), itemCode, quantity, recycle, stop))
 	}
 }
 
@@ -60,5 +83,10 @@ func MakeXLoop(ctx context.Context, char *character.Character, args *MakeXArgs)
 		return nil, err
 	}
 
+	// Finishing here rather than at the top of the next state means a saved checkpoint is never a batch that's done,
+	// so the next batch of the same item doesn't resume one and stop without making anything
+	if args.stop != nil && args.stop(char, args) {
+		return nil, nil
+	}
 	return MakeXLoop, nil
 }
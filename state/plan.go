@@ -0,0 +1,125 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ahornerr/artifacts/bank"
+	"github.com/ahornerr/artifacts/character"
+	"github.com/ahornerr/artifacts/combat"
+	"github.com/ahornerr/artifacts/game"
+	"github.com/ahornerr/artifacts/graph2"
+	"time"
+)
+
+// PlanActor is the character as the graph2 planner sees them
+func PlanActor(char *character.Character) graph2.Actor {
+	levels := map[string]int{}
+	for skill, level := range char.Levels {
+		levels[skill] = level
+	}
+
+	fighter := char.Fighter()
+	return graph2.Actor{
+		Location: char.Location,
+		Levels:   levels,
+		Bank:     char.Bank(),
+		FightTime: func(monster *game.Monster) (time.Duration, bool) {
+			outcome := combat.Expected(fighter, combat.NewMonster(monster))
+			if outcome.WinProbability < MinWinProbability {
+				return 0, false
+			}
+			return outcome.Cooldown, true
+		},
+	}
+}
+
+// PlanAndMake plans the cheapest way for the character to get quantity of the item and then does it
+func PlanAndMake(itemCode string, quantity int) Runner {
+	return func(ctx context.Context, char *character.Character) error {
+		item := char.Catalog().Items.Get(itemCode)
+		if item == nil {
+			return fmt.Errorf("unknown item %s", itemCode)
+		}
+
+		plan, err := graph2.Build(char.Catalog(), PlanActor(char), item, quantity)
+		if err != nil {
+			return err
+		}
+
+		return ExecutePlan(plan)(ctx, char)
+	}
+}
+
+// ExecutePlan does each step of the plan in order
+func ExecutePlan(plan *graph2.Plan) Runner {
+	return func(ctx context.Context, char *character.Character) error {
+		for i, step := range plan.Steps {
+			char.PushState("Step %d/%d: %s", i+1, len(plan.Steps), step)
+			err := executeStep(ctx, char, step)
+			char.PopState()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func executeStep(ctx context.Context, char *character.Character, step graph2.Step) error {
+	switch step.Action {
+	case graph2.ActionMove:
+		return Move(ctx, char, step.Location)
+
+	case graph2.ActionWithdraw:
+		reservation, err := char.ReserveBank(map[string]int{step.Item.Code: step.Quantity}, reservationTTL)
+		if err != nil {
+			if errors.Is(err, bank.ErrInsufficientStock) {
+				// Someone else got to it since we planned
+				return CollectErr{Item: step.Item, Err: err}
+			}
+			return err
+		}
+		defer char.ReleaseBank(reservation)
+
+		err = MoveToClosest(ctx, char, char.Catalog().Maps.GetBanks())
+		if err != nil {
+			return err
+		}
+		if char.MaxInventoryItems()-char.InventoryCount() < step.Quantity {
+			// Whatever else we're carrying is safe in the bank, crafting withdraws materials it needs
+			err = DepositAll(ctx, char)
+			if err != nil {
+				return err
+			}
+		}
+		return Withdraw(ctx, char, step.Item.Code, step.Quantity)
+
+	case graph2.ActionGather:
+		// Harvesting banks everything when the inventory is full, so count both
+		want := char.Inventory[step.Item.Code] + char.Bank()[step.Item.Code] + step.Quantity
+		return Harvest(step.Resource.Code, func(c *character.Character, _ *HarvestArgs) bool {
+			return c.Inventory[step.Item.Code]+c.Bank()[step.Item.Code] >= want
+		})(ctx, char)
+
+	case graph2.ActionFight:
+		want := char.Inventory[step.Item.Code] + char.Bank()[step.Item.Code] + step.Quantity
+		args := NewFightArgs(char.Catalog(), step.Monster.Code, func(c *character.Character, _ *FightArgs) bool {
+			return c.Inventory[step.Item.Code]+c.Bank()[step.Item.Code] >= want
+		}, nil)
+		err := Run(ctx, char, FightLoop, args)
+		if err != nil {
+			return err
+		}
+		if args.NumFights() == 0 {
+			return FightErr{Monster: step.Monster}
+		}
+		return nil
+
+	case graph2.ActionCraft:
+		crafts := step.Quantity / max(step.Item.Crafting.Quantity, 1)
+		return Craft(step.Item.Code, crafts, false, nil)(ctx, char)
+	}
+
+	return fmt.Errorf("unknown plan action %q", step.Action)
+}
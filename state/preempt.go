@@ -0,0 +1,120 @@
+package state
+
+import (
+	"context"
+	"github.com/ahornerr/artifacts/character"
+	"sync"
+)
+
+// Priorities for triggers, anything a role does on its own runs at PriorityRoutine
+const (
+	PriorityRoutine        = 0
+	PriorityHousekeeping   = 5
+	PriorityCrafterRequest = 10
+	PriorityEvent          = 20
+	PriorityUrgent         = 30
+)
+
+// Trigger is urgent work. Once When is true, anything running at a lower priority is stopped between actions so
+// Runner can go, then picks up where it left off.
+type Trigger struct {
+	Name     string
+	Priority int
+
+	// When is checked between actions, so it should be cheap. It's usually best as an edge, like "events changed",
+	// otherwise the same work preempts everything over and over.
+	When func(*character.Character) bool
+
+	Runner Runner
+}
+
+// Prioritized runs the runner at the current priority, preempting it for triggers with a higher one.
+// Triggers preempt each other the same way.
+func Prioritized(runner Runner, triggers ...Trigger) Runner {
+	return func(ctx context.Context, char *character.Character) error {
+		ctx = context.WithValue(ctx, suspendedKey{}, &suspended{progress: map[string][]byte{}})
+		return runAt(ctx, char, priorityFrom(ctx), runner, triggers)
+	}
+}
+
+// runAt runs the runner at the priority until it's done, doing the work of higher priority triggers whenever they fire
+func runAt(ctx context.Context, char *character.Character, priority int, runner Runner, triggers []Trigger) error {
+	ctx = context.WithValue(ctx, priorityKey{}, priority)
+
+	var fired *Trigger
+	firing := func(c *character.Character) bool {
+		for i := range triggers {
+			if triggers[i].Priority > priority && triggers[i].When(c) {
+				fired = &triggers[i]
+				return true
+			}
+		}
+		return false
+	}
+	preemptCtx, i := withInterrupt(ctx, firing)
+	i.preempt = true
+
+	for {
+		if !firing(char) {
+			err := runner(preemptCtx, char)
+			if !isInterrupt(err, i) {
+				return err
+			}
+		}
+
+		char.PushState("Preempted by %s", fired.Name)
+		err := runAt(ctx, char, fired.Priority, fired.Runner, triggers)
+		char.PopState()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+type priorityKey struct{}
+
+func priorityFrom(ctx context.Context) int {
+	priority, _ := ctx.Value(priorityKey{}).(int)
+	return priority
+}
+
+// suspended is the progress of state machines that were preempted, for Run to pick back up when they start again
+type suspended struct {
+	progress map[string][]byte
+	mux      sync.Mutex
+}
+
+type suspendedKey struct{}
+
+func suspendedFrom(ctx context.Context) *suspended {
+	s, _ := ctx.Value(suspendedKey{}).(*suspended)
+	return s
+}
+
+func (s *suspended) suspend(args Checkpointer) error {
+	progress, err := args.MarshalProgress()
+	if err != nil {
+		return err
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.progress[args.CheckpointKey()] = progress
+	return nil
+}
+
+// resume loads and forgets the progress of a preempted state machine with the same key. It's false if there wasn't one.
+func (s *suspended) resume(args Checkpointer) (bool, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	key := args.CheckpointKey()
+	progress, ok := s.progress[key]
+	if !ok {
+		return false, nil
+	}
+	delete(s.progress, key)
+
+	return true, args.UnmarshalProgress(progress)
+}
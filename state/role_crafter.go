@@ -1,41 +1,138 @@
 package state
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/ahornerr/artifacts/character"
 	"github.com/ahornerr/artifacts/game"
+	"github.com/ahornerr/artifacts/jobs"
 	"github.com/gofiber/fiber/v3/log"
 	"math"
-	"reflect"
 	"slices"
+	"time"
 )
 
-var (
-	levelMilestones = []int{5, 10, 15, 20, 25, 30, 35}
+// Training is how far roles take skills
+type Training struct {
+	// MaxLevel is where skills stop being trained
+	MaxLevel int
+
+	// LevelMilestones are the item levels crafters make gear at
+	LevelMilestones []int
+}
+
+// Roles that fail try again after roleBackoff, then twice as long, up to roleAttempts times before the error is
+// logged and they start over
+const (
+	roleAttempts = 3
+	roleBackoff  = 10 * time.Second
 )
 
-func itemsForTraining(char *character.Character, skill string) []*game.Item {
+var DefaultTraining = Training{
+	MaxLevel:        35,
+	LevelMilestones: []int{5, 10, 15, 20, 25, 30, 35},
+}
+
+// CrafterParams are the settings for RoleCrafter
+type CrafterParams struct {
+	Training Training
+
+	// Harvesters is how many characters gathering for the crafter splits between
+	Harvesters int
+
+	// Stockpile is how much of each item to keep around once everyone's geared up
+	Stockpile map[string]int
+
+	// Gold is how much gold the crafter carries
+	Gold GoldRange
+}
+
+func itemsForTraining(char *character.Character, skill string, maxLevel int) []*game.Item {
 	charLevel := char.GetLevel(skill)
 	if charLevel >= maxLevel {
 		return nil
 	}
 
-	return game.Items.ForTrainingCraftingSkill(skill, charLevel)
+	return char.Catalog().Items.ForTrainingCraftingSkill(skill, charLevel)
 }
 
-func RoleCrafter(characters map[string]*character.Character, crafterWants chan game.ItemQuantity) Runner {
+// crafterStep is what the crafter is making, saved so it goes back to the same item after being preempted or restarted
+// instead of picking again from scratch
+type crafterStep struct {
+	Item     string `json:"item"`
+	Quantity int    `json:"quantity"`
+	Recycle  bool   `json:"recycle"`
+}
+
+func (s *crafterStep) CheckpointKey() string {
+	return "role:crafter"
+}
+
+func (s *crafterStep) MarshalProgress() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+func (s *crafterStep) UnmarshalProgress(data []byte) error {
+	return json.Unmarshal(data, s)
+}
+
+func RoleCrafter(characters map[string]*character.Character, queue *jobs.Queue, params CrafterParams) Runner {
 	return func(ctx context.Context, char *character.Character) error {
+		step := &crafterStep{}
+		if checkpoints := checkpointsFrom(ctx); checkpoints != nil {
+			if _, err := checkpoints.resume(char.Name, step); err != nil {
+				log.Errorf("%s error resuming crafter: %v", char.Name, err)
+				*step = crafterStep{}
+			}
+		}
+
+		role := Retry(roleAttempts, roleBackoff, Prioritized(func(ctx context.Context, char *character.Character) error {
+			return crafter(ctx, char, characters, queue, params, step)
+		}, monsterEventTrigger(char), GoldTrigger(params.Gold)))
+
 		for {
-			err := crafter(ctx, char, characters, crafterWants)
+			err := role(ctx, char)
 			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
 				log.Errorf("%s %v", char.Name, err)
 			}
 		}
 	}
 }
 
-func crafter(ctx context.Context, char *character.Character, characters map[string]*character.Character, crafterWants chan game.ItemQuantity) error {
+// monsterEventTrigger goes after event monsters when the events change, which crafter also does on every pass
+func monsterEventTrigger(char *character.Character) Trigger {
+	lastVersion := char.Catalog().Events.Version()
+	return Trigger{
+		Name:     "monster event",
+		Priority: PriorityEvent,
+		When: func(c *character.Character) bool {
+			version := c.Catalog().Events.Version()
+			if version == lastVersion {
+				return false
+			}
+			lastVersion = version
+			return len(c.Catalog().Events.Events()["monster"]) > 0
+		},
+		Runner: func(ctx context.Context, char *character.Character) error {
+			_, err := doMonsterEvent(ctx, char)
+			return err
+		},
+	}
+}
+
+func crafter(ctx context.Context, char *character.Character, characters map[string]*character.Character, queue *jobs.Queue, params CrafterParams, step *crafterStep) error {
+	if item := char.Catalog().Items.Get(step.Item); item != nil && item.Crafting != nil {
+		char.PushState("Resuming %s", item.Name)
+		defer char.PopState()
+		return distributeAndMake(ctx, char, characters, queue, params.Harvesters, item, step.Quantity, step.Recycle, step)
+	}
+
 	did, err := doMonsterEvent(ctx, char)
 	if err != nil {
 		return err
@@ -50,10 +147,9 @@ func crafter(ctx context.Context, char *character.Character, characters map[stri
 		return nil
 	}
 
-	betterEquipment := getBetterEquipmentForCrafting(char.Bank(), characters)
+	betterEquipment := getBetterEquipmentForCrafting(char.Catalog(), char.Bank(), characters, params.Training.LevelMilestones)
 	if len(betterEquipment) == 0 {
-		// TODO: What to do here?
-		return nil
+		return stockpile(ctx, char, characters, queue, params, step)
 	}
 
 	item := betterEquipment[0].Item
@@ -61,17 +157,52 @@ func crafter(ctx context.Context, char *character.Character, characters map[stri
 
 	if char.GetLevel(item.Crafting.Skill) < item.Crafting.Level {
 		// We need to train this skill to be able to craft the item
-		return trainCrafting(ctx, char, crafterWants, item.Crafting.Skill)
+		return trainCrafting(ctx, char, characters, queue, params, item.Crafting.Skill, step)
 	}
 
-	return distributeAndMake(ctx, char, crafterWants, item, quantity, false)
+	return distributeAndMake(ctx, char, characters, queue, params.Harvesters, item, quantity, false, step)
+}
+
+// stockpile tops up the first stockpile item that's short. Crafted items are made, anything else is left to harvesters.
+func stockpile(ctx context.Context, char *character.Character, characters map[string]*character.Character, queue *jobs.Queue, params CrafterParams, step *crafterStep) error {
+	itemCodes := make([]string, 0, len(params.Stockpile))
+	for itemCode := range params.Stockpile {
+		itemCodes = append(itemCodes, itemCode)
+	}
+	slices.Sort(itemCodes)
+
+	owned := ownedItems(char.Bank(), characters)
+	for _, itemCode := range itemCodes {
+		item := char.Catalog().Items.Get(itemCode)
+		if item == nil {
+			continue
+		}
+		short := params.Stockpile[itemCode] - owned[itemCode] - queue.Outstanding(char.Name, itemCode)
+		if short <= 0 {
+			continue
+		}
+
+		if item.Crafting != nil && char.GetLevel(item.Crafting.Skill) >= item.Crafting.Level {
+			char.PushState("Stockpiling %d %s", short, item.Name)
+			defer char.PopState()
+			return distributeAndMake(ctx, char, characters, queue, params.Harvesters, item, short, false, step)
+		}
+		if item.Crafting == nil {
+			_, err := queue.Add(char.Name, itemCode, short, jobs.PriorityLow)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
 }
 
 func doMonsterEvent(ctx context.Context, char *character.Character) (bool, error) {
-	for monsterCode := range game.Events.Events()["monster"] {
-		monster := game.Monsters.Get(monsterCode)
-		bestEquipment := char.GetBestOwnedEquipment(monster.Stats)
-		if bestEquipment.TurnsToKillMonster > bestEquipment.TurnsToKillPlayer {
+	for monsterCode := range char.Catalog().Events.Events()["monster"] {
+		monster := char.Catalog().Monsters.Get(monsterCode)
+		bestEquipment := char.GetBestOwnedEquipment(monster.Stats, character.Objective{})
+		if bestEquipment.Outcome.WinProbability < MinWinProbability {
 			// Can't win the fight
 			continue
 		}
@@ -86,24 +217,33 @@ func doMonsterEvent(ctx context.Context, char *character.Character) (bool, error
 func doTask(ctx context.Context, char *character.Character) (bool, error) {
 	// TODO: Support other task types
 	if char.TaskType == "monsters" && char.Task != "" {
-		lastEvents := game.Events.Events()
-
-		monster := game.Monsters.Get(char.Task)
-		bestEquipment := char.GetBestOwnedEquipment(monster.Stats)
+		monster := char.Catalog().Monsters.Get(char.Task)
+		bestEquipment := char.GetBestOwnedEquipment(monster.Stats, taskObjective)
 		// Make sure we can win the fight
-		if bestEquipment.TurnsToKillMonster < bestEquipment.TurnsToKillPlayer {
-			args := NewTaskArgs(func(c *character.Character, args *TaskArgs) bool {
-				return !reflect.DeepEqual(lastEvents, game.Events.Events())
-			})
-
-			return true, Run(ctx, char, TaskLoop, args)
+		if bestEquipment.Outcome.WinProbability >= MinWinProbability {
+			// Monster events preempt the task, it picks back up once they're done
+			return true, Run(ctx, char, TaskLoop, NewTaskArgs(nil))
 		}
 	}
 
 	return false, nil
 }
 
-func getBetterEquipmentForCrafting(bank map[string]int, characters map[string]*character.Character) []game.ItemQuantity {
+// ownedItems is everything in the bank and every character's inventory
+func ownedItems(bank map[string]int, characters map[string]*character.Character) map[string]int {
+	owned := map[string]int{}
+	for itemCode, quantity := range bank {
+		owned[itemCode] += quantity
+	}
+	for _, c := range characters {
+		for itemCode, quantity := range c.InventoryItems() {
+			owned[itemCode] += quantity
+		}
+	}
+	return owned
+}
+
+func getBetterEquipmentForCrafting(catalog *game.Catalog, bank map[string]int, characters map[string]*character.Character, levelMilestones []int) []game.ItemQuantity {
 	totalItemQuantity := func(itemCode string) int {
 		quantity := bank[itemCode]
 		for _, c := range characters {
@@ -117,7 +257,11 @@ func getBetterEquipmentForCrafting(bank map[string]int, characters map[string]*c
 		return quantity
 	}
 
+	// Materials we already have make items cheaper to finish
+	stock := ownedItems(bank, characters)
+
 	var itemCandidates []game.ItemQuantity
+	costs := map[string]time.Duration{}
 
 	for _, level := range levelMilestones {
 		charactersNeedingThisLevelItem := 0
@@ -139,7 +283,7 @@ func getBetterEquipmentForCrafting(bank map[string]int, characters map[string]*c
 			continue
 		}
 
-		items := game.Items.ForLevel(level)
+		items := catalog.Items.ForLevel(level)
 
 		for _, item := range items {
 			// TODO: Ignore tools for now
@@ -164,6 +308,13 @@ func getBetterEquipmentForCrafting(bank map[string]int, characters map[string]*c
 				continue
 			}
 
+			cost, err := catalog.CostFrom(item.Code, remainingQuantity, stock)
+			if err != nil {
+				// Nothing we can do to get it
+				continue
+			}
+			costs[item.Code] = cost
+
 			itemCandidates = append(itemCandidates, game.ItemQuantity{
 				Item:     item,
 				Quantity: remainingQuantity,
@@ -173,18 +324,23 @@ func getBetterEquipmentForCrafting(bank map[string]int, characters map[string]*c
 
 	// Sort items lowest cost first
 	slices.SortFunc(itemCandidates, func(a, b game.ItemQuantity) int {
-		return game.Cost(a.Item.Code)*a.Quantity - game.Cost(b.Item.Code)*b.Quantity
+		return cmp.Compare(costs[a.Item.Code], costs[b.Item.Code])
 	})
 
 	return itemCandidates
 }
 
-func trainCrafting(ctx context.Context, char *character.Character, crafterWants chan game.ItemQuantity, skill string) error {
-	// TODO: Take into account materials we have in inventory/bank
-	lowestCost := math.MaxInt32
+func trainCrafting(ctx context.Context, char *character.Character, characters map[string]*character.Character, queue *jobs.Queue, params CrafterParams, skill string, step *crafterStep) error {
+	quantityToMakeAtATime := 5
+
+	stock := ownedItems(char.Bank(), characters)
+	lowestCost := time.Duration(math.MaxInt64)
 	var lowestItem *game.Item
-	for _, item := range itemsForTraining(char, skill) {
-		cost := game.Cost(item.Code)
+	for _, item := range itemsForTraining(char, skill, params.Training.MaxLevel) {
+		cost, err := char.Catalog().CostFrom(item.Code, quantityToMakeAtATime, stock)
+		if err != nil {
+			continue
+		}
 		if cost < lowestCost {
 			lowestCost = cost
 			lowestItem = item
@@ -196,10 +352,8 @@ func trainCrafting(ctx context.Context, char *character.Character, crafterWants
 		return fmt.Errorf("unable to find item for training %s", skill)
 	}
 
-	quantityToMakeAtATime := 5
-
 	startXp := char.GetXP(skill)
-	err := distributeAndMake(ctx, char, crafterWants, lowestItem, quantityToMakeAtATime, true)
+	err := distributeAndMake(ctx, char, characters, queue, params.Harvesters, lowestItem, quantityToMakeAtATime, true, step)
 	if err != nil {
 		return err
 	}
@@ -212,45 +366,71 @@ func trainCrafting(ctx context.Context, char *character.Character, crafterWants
 	return nil
 }
 
-func distributeAndMake(ctx context.Context, char *character.Character, crafterWants chan game.ItemQuantity, item *game.Item, quantity int, recycle bool) error {
-	numHarvesters := 4 // TODO: Make this configurable?
+// distributeAndMake splits gathering the materials for the item between harvesters and makes it. It's the crafter's
+// step until it's done, or until it fails for some reason other than being preempted.
+func distributeAndMake(ctx context.Context, char *character.Character, characters map[string]*character.Character, queue *jobs.Queue, numHarvesters int, item *game.Item, quantity int, recycle bool, step *crafterStep) error {
+	*step = crafterStep{Item: item.Code, Quantity: quantity, Recycle: recycle}
+	saveCheckpoint(checkpointsFrom(ctx), char, step)
 
-	var totalCost int
-	for reqItem, reqQuantity := range item.Crafting.Items {
-		// Account for items in the bank and inventory
-		totalQuantity := quantity * reqQuantity
-		remainingQuantity := totalQuantity - char.Bank()[reqItem.Code]
-		remainingQuantity -= char.Inventory[reqItem.Code]
-		if remainingQuantity <= 0 {
-			continue
-		}
-		totalCost += game.Cost(reqItem.Code) * remainingQuantity
+	err := makeDistributed(ctx, char, characters, queue, numHarvesters, item, quantity, recycle)
+	if !isPreempted(err) {
+		clearCheckpoint(checkpointsFrom(ctx), char, step)
+		*step = crafterStep{}
 	}
+	return err
+}
+
+func makeDistributed(ctx context.Context, char *character.Character, characters map[string]*character.Character, queue *jobs.Queue, numHarvesters int, item *game.Item, quantity int, recycle bool) error {
+	numHarvesters = max(numHarvesters, 1)
 
-	avgHarvesterCost := totalCost / numHarvesters
+	// Gear comes before training
+	priority := jobs.PriorityNormal
+	if recycle {
+		priority = jobs.PriorityLow
+	}
 
+	// Harvesters get whatever isn't in the bank, on a character or already being harvested. Only materials that are
+	// short need a cost, anything we can't get is still queued in case a harvester can.
+	owned := ownedItems(char.Bank(), characters)
+	shortfall := map[*game.Item]int{}
+	costs := map[*game.Item]time.Duration{}
+	var totalCost time.Duration
 	for reqItem, reqQuantity := range item.Crafting.Items {
-		// Account for items in the bank
-		totalQuantity := reqQuantity * quantity
-		remainingQuantity := totalQuantity - char.Bank()[reqItem.Code]
-		remainingQuantity -= char.Inventory[reqItem.Code]
-		if remainingQuantity <= 0 {
+		short := quantity*reqQuantity - owned[reqItem.Code] - queue.Outstanding(char.Name, reqItem.Code)
+		if short <= 0 {
 			continue
 		}
+		shortfall[reqItem] = short
 
-		itemCost := game.Cost(reqItem.Code)
+		cost, err := char.Catalog().Cost(reqItem.Code)
+		if errors.Is(err, game.ErrNoSource) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		costs[reqItem] = cost
+		totalCost += cost * time.Duration(short)
+	}
+
+	avgHarvesterCost := totalCost / time.Duration(numHarvesters)
+
+	for reqItem, short := range shortfall {
+		// Split into jobs of about even cost so every harvester gets a share, or one job without a cost to go by
+		perJob := short
+		if itemCost := costs[reqItem]; itemCost > 0 {
+			perJob = max(1, int(avgHarvesterCost/itemCost))
+		}
 
-		for remainingQuantity > 0 {
-			thisQuantity := max(1, min(remainingQuantity, avgHarvesterCost/itemCost))
-			crafterWants <- game.ItemQuantity{
-				Item:     reqItem,
-				Quantity: thisQuantity,
+		for short > 0 {
+			thisQuantity := min(short, perJob)
+			if _, err := queue.Add(char.Name, reqItem.Code, thisQuantity, priority); err != nil {
+				return err
 			}
-			remainingQuantity -= thisQuantity
+			short -= thisQuantity
 		}
 	}
 
-	args := NewMakeXArgs(item.Code, quantity, recycle, func(c *character.Character, args *MakeXArgs) bool {
+	args := NewMakeXArgs(char.Catalog(), item.Code, quantity, recycle, func(c *character.Character, args *MakeXArgs) bool {
 		if args.Made >= quantity {
 			// We made one batch
 			return true
@@ -3,13 +3,13 @@ package state
 import (
 	"context"
 	"github.com/ahornerr/artifacts/character"
+	"github.com/ahornerr/artifacts/combat"
 	"github.com/ahornerr/artifacts/game"
+	"github.com/ahornerr/artifacts/jobs"
 	"github.com/gofiber/fiber/v3/log"
 	"time"
 )
 
-const maxLevel = 35
-
 // 28 jewelrycrafting made iron ring (level 10) got 0 xp
 // 28 jewelrycrafting made life ring (level 15) got 0 xp
 // 28 jewelrycrafting made steel ring (level 20) got 279 xp
@@ -20,64 +20,89 @@ const maxLevel = 35
 
 // and as soon as you have a 10-level difference with a monster, resource or craft, it won't give you any more xp.
 
-func RoleHarvester(crafterWants chan game.ItemQuantity) Runner {
+// trainingBudget is how long harvesters train one skill before checking whether another one is further behind
+const trainingBudget = 30 * time.Minute
+
+func RoleHarvester(queue *jobs.Queue, training Training, gold GoldRange) Runner {
 	return func(ctx context.Context, char *character.Character) error {
-		var forCrafter *game.ItemQuantity
+		// Harvest on our own until a job we can do comes up
+		role := Retry(roleAttempts, roleBackoff, Prioritized(func(ctx context.Context, char *character.Character) error {
+			return harvester(ctx, char, training)
+		}, crafterRequestTrigger(char, queue), GoldTrigger(gold)))
+
 		for {
-			ctx, cancel := context.WithCancel(ctx)
-
-			if forCrafter != nil {
-				time.Sleep(time.Until(char.CooldownExpires))
-				err := harvestForCrafter(ctx, char, *forCrafter)
-				if err != nil {
-					log.Errorf("%s %v", char.Name, err)
-					// TODO: Should we put the ItemQuantity back on the channel?
-				}
-				forCrafter = nil
-			} else {
-				go func() {
-					for {
-						select {
-						case <-ctx.Done():
-							return
-						case want := <-crafterWants:
-							if !canCollect(char, want.Item) {
-								crafterWants <- want
-								continue
-							}
-							forCrafter = &want
-							cancel()
-							return
-						}
-					}
-				}()
-
-				err := harvester(ctx, char)
-				if err != nil {
-					log.Errorf("%s %v", char.Name, err)
+			err := role(ctx, char)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
 				}
+				log.Errorf("%s %v", char.Name, err)
 			}
-
-			cancel()
 		}
 	}
 }
 
+// crafterRequestTrigger fires when there's a job in the queue we can do, then claims it and gets the items for it
+func crafterRequestTrigger(char *character.Character, queue *jobs.Queue) Trigger {
+	canDo := func(job jobs.Job) bool {
+		item := char.Catalog().Items.Get(job.Item)
+		return item != nil && canCollect(char, item)
+	}
+
+	return Trigger{
+		Name:     "crafter request",
+		Priority: PriorityCrafterRequest,
+		When: func(c *character.Character) bool {
+			return queue.Pending(canDo)
+		},
+		Runner: func(ctx context.Context, char *character.Character) error {
+			job, ok, err := queue.Claim(char.Name, canDo)
+			if err != nil || !ok {
+				// Another harvester got to it first if it's not ok
+				return err
+			}
+
+			err = harvestForCrafter(ctx, char, job)
+			switch {
+			case isPreempted(err), ctx.Err() != nil:
+				// Someone else can have it, this wasn't the job's fault
+				if releaseErr := queue.Release(job.ID, char.Name); releaseErr != nil {
+					log.Errorf("%s %v", char.Name, releaseErr)
+				}
+				return err
+			case err != nil:
+				log.Errorf("%s %v", char.Name, err)
+				err = queue.Fail(job.ID, char.Name, err)
+			default:
+				err = queue.Complete(job.ID, char.Name)
+			}
+			if err != nil {
+				log.Errorf("%s %v", char.Name, err)
+			}
+			return nil
+		},
+	}
+}
+
 func canCollect(char *character.Character, item *game.Item) bool {
 	if item.Crafting != nil {
 		return char.GetLevel(item.Crafting.Skill) >= item.Crafting.Level
 	}
 
-	for _, resource := range game.Resources.ResourcesForItem(item) {
+	for _, resource := range char.Catalog().Resources.ResourcesForItem(item) {
 		if char.GetLevel(resource.Skill) >= resource.Level {
 			return true
 		}
 	}
 
-	for _, monster := range game.Monsters.MonstersForItem(item) {
-		// TODO: Determine if we can win against a monster
-		_ = monster
-		return true
+	// Fights are judged with what's equipped now, the same as the planner does
+	if monsters := char.Catalog().Monsters.MonstersForItem(item); len(monsters) > 0 {
+		fighter := char.Fighter()
+		for _, monster := range monsters {
+			if combat.Expected(fighter, combat.NewMonster(monster)).WinProbability >= MinWinProbability {
+				return true
+			}
+		}
 	}
 
 	// Just jasper crystal for now
@@ -89,67 +114,67 @@ func canCollect(char *character.Character, item *game.Item) bool {
 	return false
 }
 
-func harvestForCrafter(ctx context.Context, char *character.Character, crafterWants game.ItemQuantity) error {
-	char.PushState("Getting %d %s for crafter", crafterWants.Quantity, crafterWants.Item)
+func harvestForCrafter(ctx context.Context, char *character.Character, job jobs.Job) error {
+	char.PushState("Getting %d %s for %s", job.Quantity, char.Catalog().Items.Get(job.Item), job.Owner)
 	defer char.PopState()
 
-	err := CollectItems(
-		crafterWants.Item.Code,
-		crafterWants.Quantity,
-		false,
-		false,
-		nil,
+	return Sequence(
+		CollectItems(job.Item, job.Quantity, false, false, nil),
+		MoveToBankAndDepositAll,
 	)(ctx, char)
-	if err != nil {
-		return err
-	}
+}
 
-	return MoveToBankAndDepositAll(ctx, char)
+// eventResources are what harvesters go after instead of training, once they're done training the skill
+var eventResources = []struct {
+	skill    string
+	resource string
+}{
+	{"mining", "strange_rocks"},
+	{"woodcutting", "magic_tree"},
 }
 
-func stopForEvent[T any](char *character.Character, args T) bool {
-	if char.GetLevel("mining") >= 35 && len(game.Maps.GetResources("strange_rocks")) > 0 {
-		return true
-	}
-	if char.GetLevel("woodcutting") >= 35 && len(game.Maps.GetResources("magic_tree")) > 0 {
-		return true
+// eventResource is an event resource that's up for a skill the character is done training, "" if there isn't one
+func eventResource(char *character.Character, training Training) string {
+	for _, event := range eventResources {
+		if char.GetLevel(event.skill) >= training.MaxLevel && len(char.Catalog().Maps.GetResources(event.resource)) > 0 {
+			return event.resource
+		}
 	}
-	return false
+	return ""
 }
 
-func harvester(ctx context.Context, char *character.Character) error {
-
-	if char.GetLevel("mining") >= 35 && len(game.Maps.GetResources("strange_rocks")) > 0 {
-		return Harvest("strange_rocks", nil)(ctx, char)
+func harvester(ctx context.Context, char *character.Character, training Training) error {
+	if resource := eventResource(char, training); resource != "" {
+		return Harvest(resource, nil)(ctx, char)
 	}
 
-	if char.GetLevel("woodcutting") >= 35 && len(game.Maps.GetResources("magic_tree")) > 0 {
-		return Harvest("magic_tree", nil)(ctx, char)
+	eventResourceUp := func(c *character.Character) bool {
+		return eventResource(c, training) != ""
 	}
 
 	// Train skills 5 levels at a time.
 	// TODO: Stop at level
 	if char.GetLevel("woodcutting")%5 == 0 && char.GetLevel("woodcutting") >= char.GetLevel("mining") {
-		miningResources := resourcesForTraining(char, "mining")
+		miningResources := resourcesForTraining(char, "mining", training.MaxLevel)
 		if len(miningResources) > 0 {
 			char.PushState("Training mining")
 			defer char.PopState()
-			return Harvest(miningResources[0].Code, stopForEvent)(ctx, char)
+			return Budget(trainingBudget, Until(eventResourceUp, Harvest(miningResources[0].Code, nil)))(ctx, char)
 		}
 	}
 
-	woodcuttingResources := resourcesForTraining(char, "woodcutting")
+	woodcuttingResources := resourcesForTraining(char, "woodcutting", training.MaxLevel)
 	if len(woodcuttingResources) > 0 {
 		char.PushState("Training woodcutting")
 		defer char.PopState()
-		return Harvest(woodcuttingResources[0].Code, stopForEvent)(ctx, char)
+		return Budget(trainingBudget, Until(eventResourceUp, Harvest(woodcuttingResources[0].Code, nil)))(ctx, char)
 	}
 
-	fishingResources := resourcesForTraining(char, "fishing")
+	fishingResources := resourcesForTraining(char, "fishing", training.MaxLevel)
 	if len(fishingResources) > 0 {
 		char.PushState("Training fishing")
 		defer char.PopState()
-		return Harvest(fishingResources[0].Code, stopForEvent)(ctx, char)
+		return Budget(trainingBudget, Until(eventResourceUp, Harvest(fishingResources[0].Code, nil)))(ctx, char)
 	}
 
 	//miningItems := itemsForTraining(char, "mining")
@@ -161,11 +186,11 @@ func harvester(ctx context.Context, char *character.Character) error {
 	return nil
 }
 
-func resourcesForTraining(char *character.Character, skill string) []*game.Resource {
+func resourcesForTraining(char *character.Character, skill string, maxLevel int) []*game.Resource {
 	charLevel := char.GetLevel(skill)
 	if charLevel >= maxLevel {
 		return nil
 	}
 
-	return game.Resources.ResourcesForSkill(skill, charLevel)
+	return char.Catalog().Resources.ResourcesForSkill(skill, charLevel)
 }
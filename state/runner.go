@@ -0,0 +1,236 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ahornerr/artifacts/character"
+	"time"
+)
+
+// Combinators build bigger runners out of smaller ones. Each one pushes a state saying where it's at, like
+// "Step 2/3", for as long as the runner under it is going.
+
+// ErrInterrupted is returned by Run when Until, Preemptible, Timeout, Budget or a Trigger stops it between states
+var ErrInterrupted = errors.New("interrupted")
+
+var ErrTimeout = errors.New("out of time")
+
+// interrupt stops any Run under the context between states once when is true
+type interrupt struct {
+	when func(*character.Character) bool
+
+	// preempt means the work will be picked back up, so Run keeps its progress
+	preempt bool
+}
+
+type interruptedErr struct {
+	interrupt *interrupt
+}
+
+func (e interruptedErr) Error() string {
+	return ErrInterrupted.Error()
+}
+
+func (e interruptedErr) Is(target error) bool {
+	return target == ErrInterrupted
+}
+
+type interruptsKey struct{}
+
+func withInterrupt(ctx context.Context, when func(*character.Character) bool) (context.Context, *interrupt) {
+	i := &interrupt{when: when}
+	parent, _ := ctx.Value(interruptsKey{}).([]*interrupt)
+	interrupts := append(append([]*interrupt{}, parent...), i)
+	return context.WithValue(ctx, interruptsKey{}, interrupts), i
+}
+
+// interrupted is the error for the outermost interrupt under ctx that fired, nil if none did
+func interrupted(ctx context.Context, char *character.Character) error {
+	interrupts, _ := ctx.Value(interruptsKey{}).([]*interrupt)
+	for _, i := range interrupts {
+		if i.when(char) {
+			return interruptedErr{interrupt: i}
+		}
+	}
+	return nil
+}
+
+func isPreempted(err error) bool {
+	var interruptedErr interruptedErr
+	return errors.As(err, &interruptedErr) && interruptedErr.interrupt.preempt
+}
+
+func isInterrupt(err error, i *interrupt) bool {
+	var interruptedErr interruptedErr
+	return errors.As(err, &interruptedErr) && interruptedErr.interrupt == i
+}
+
+// keepGoing is false for errors that have to go straight up instead of being retried
+func keepGoing(ctx context.Context, err error) bool {
+	return ctx.Err() == nil && !errors.Is(err, ErrInterrupted)
+}
+
+func Loop(runners ...Runner) Runner {
+	return func(ctx context.Context, char *character.Character) error {
+		for {
+			for _, runner := range runners {
+				if err := runner(ctx, char); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// Sequence runs each runner once, in order, stopping at the first error
+func Sequence(runners ...Runner) Runner {
+	return func(ctx context.Context, char *character.Character) error {
+		for i, runner := range runners {
+			char.PushState("Step %d/%d", i+1, len(runners))
+			err := runner(ctx, char)
+			char.PopState()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Fallback tries each runner in order until one succeeds
+func Fallback(runners ...Runner) Runner {
+	return func(ctx context.Context, char *character.Character) error {
+		var errs []error
+		for i, runner := range runners {
+			char.PushState("Option %d/%d", i+1, len(runners))
+			err := runner(ctx, char)
+			char.PopState()
+			if err == nil {
+				return nil
+			}
+			if !keepGoing(ctx, err) {
+				return err
+			}
+			errs = append(errs, err)
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// Retry runs the runner up to attempts times, waiting backoff after the first failure and twice as long after each one after that
+func Retry(attempts int, backoff time.Duration, runner Runner) Runner {
+	return func(ctx context.Context, char *character.Character) error {
+		var err error
+		for attempt := 1; attempt <= attempts; attempt++ {
+			if attempt > 1 {
+				char.PushState("Retrying in %s: %s", backoff, err)
+				select {
+				case <-ctx.Done():
+				case <-time.After(backoff):
+				}
+				char.PopState()
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				backoff *= 2
+			}
+
+			char.PushState("Attempt %d/%d", attempt, attempts)
+			err = runner(ctx, char)
+			char.PopState()
+			if err == nil || !keepGoing(ctx, err) {
+				return err
+			}
+		}
+		return fmt.Errorf("failed after %d attempts: %w", attempts, err)
+	}
+}
+
+// RepeatN runs the runner n times
+func RepeatN(n int, runner Runner) Runner {
+	return func(ctx context.Context, char *character.Character) error {
+		for i := 0; i < n; i++ {
+			char.PushState("Repeat %d/%d", i+1, n)
+			err := runner(ctx, char)
+			char.PopState()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Until runs the runner over and over until done, stopping it between actions as soon as done is true
+func Until(done func(*character.Character) bool, runner Runner) Runner {
+	return func(ctx context.Context, char *character.Character) error {
+		ctx, i := withInterrupt(ctx, done)
+		for n := 1; !done(char); n++ {
+			char.PushState("Repeat %d until done", n)
+			err := runner(ctx, char)
+			char.PopState()
+			if isInterrupt(err, i) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Preemptible runs the runner, but whenever when is true it's stopped between actions so higher can run.
+// The runner starts over once higher is done, and Preemptible returns when the runner does.
+func Preemptible(runner Runner, when func(*character.Character) bool, higher Runner) Runner {
+	return func(ctx context.Context, char *character.Character) error {
+		preemptCtx, i := withInterrupt(ctx, when)
+		for {
+			if when(char) {
+				char.PushState("Preempted")
+				err := higher(ctx, char)
+				char.PopState()
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			char.PushState("Preemptible")
+			err := runner(preemptCtx, char)
+			char.PopState()
+			if !isInterrupt(err, i) {
+				return err
+			}
+		}
+	}
+}
+
+// Timeout stops the runner between actions once it's been going for longer than d, and returns ErrTimeout
+func Timeout(d time.Duration, runner Runner) Runner {
+	return timeBudget(d, runner, ErrTimeout)
+}
+
+// Budget is like Timeout, but running out of time isn't an error. It's for work that's worth doing for a while
+// before looking around for something better.
+func Budget(d time.Duration, runner Runner) Runner {
+	return timeBudget(d, runner, nil)
+}
+
+func timeBudget(d time.Duration, runner Runner, outOfTime error) Runner {
+	return func(ctx context.Context, char *character.Character) error {
+		deadline := time.Now().Add(d)
+		ctx, i := withInterrupt(ctx, func(*character.Character) bool {
+			return time.Now().After(deadline)
+		})
+
+		char.PushState("Until %s", deadline.Format(time.TimeOnly))
+		err := runner(ctx, char)
+		char.PopState()
+		if isInterrupt(err, i) {
+			return outOfTime
+		}
+		return err
+	}
+}
@@ -0,0 +1,289 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"github.com/ahornerr/artifacts/character"
+	"testing"
+	"time"
+)
+
+// newTestCharacter is a character that's only good for pushing and popping states, for runners that don't act
+func newTestCharacter(t *testing.T) *character.Character {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	updates := make(chan *character.Character)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-updates:
+			}
+		}
+	}()
+
+	return character.NewCharacter(nil, nil, nil, updates, "tester")
+}
+
+// counting is a state machine that counts its states forever, sleeping between them
+func counting(count *int) Runner {
+	var state State[*int]
+	state = func(ctx context.Context, char *character.Character, count *int) (State[*int], error) {
+		*count++
+		time.Sleep(time.Millisecond)
+		return state, nil
+	}
+	return func(ctx context.Context, char *character.Character) error {
+		return Run(ctx, char, state, count)
+	}
+}
+
+func TestSequence(t *testing.T) {
+	char := newTestCharacter(t)
+	errStop := errors.New("stop")
+
+	var ran []int
+	step := func(n int, err error) Runner {
+		return func(ctx context.Context, char *character.Character) error {
+			ran = append(ran, n)
+			return err
+		}
+	}
+
+	err := Sequence(step(1, nil), step(2, errStop), step(3, nil))(context.Background(), char)
+	if !errors.Is(err, errStop) {
+		t.Errorf("got %v, want the second step's error", err)
+	}
+	if len(ran) != 2 || ran[0] != 1 || ran[1] != 2 {
+		t.Errorf("ran steps %v, want 1 and 2", ran)
+	}
+	if len(char.State) != 0 {
+		t.Errorf("states %v are left on the stack", char.State)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	char := newTestCharacter(t)
+	errFlaky := errors.New("flaky")
+
+	attempts := 0
+	flaky := func(failures int) Runner {
+		return func(ctx context.Context, char *character.Character) error {
+			attempts++
+			if attempts <= failures {
+				return errFlaky
+			}
+			return nil
+		}
+	}
+
+	if err := Retry(3, time.Millisecond, flaky(2))(context.Background(), char); err != nil {
+		t.Errorf("failing twice then succeeding got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("%d attempts, want 3", attempts)
+	}
+
+	attempts = 0
+	if err := Retry(3, time.Millisecond, flaky(5))(context.Background(), char); !errors.Is(err, errFlaky) {
+		t.Errorf("failing every time got %v, want the last error", err)
+	}
+	if attempts != 3 {
+		t.Errorf("%d attempts, want 3", attempts)
+	}
+
+	// Being interrupted isn't a failure worth retrying
+	attempts = 0
+	interrupted := func(ctx context.Context, char *character.Character) error {
+		attempts++
+		return interruptedErr{interrupt: &interrupt{}}
+	}
+	if err := Retry(3, time.Millisecond, interrupted)(context.Background(), char); !errors.Is(err, ErrInterrupted) {
+		t.Errorf("got %v, want it interrupted", err)
+	}
+	if attempts != 1 {
+		t.Errorf("%d attempts after an interrupt, want 1", attempts)
+	}
+	if len(char.State) != 0 {
+		t.Errorf("states %v are left on the stack", char.State)
+	}
+}
+
+func TestUntil(t *testing.T) {
+	char := newTestCharacter(t)
+
+	count := 0
+	err := Until(func(*character.Character) bool { return count >= 5 }, counting(&count))(context.Background(), char)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Checked between every state, so it stops right on time
+	if count != 5 {
+		t.Errorf("ran %d states, want 5", count)
+	}
+	if len(char.State) != 0 {
+		t.Errorf("states %v are left on the stack", char.State)
+	}
+}
+
+func TestBudget(t *testing.T) {
+	char := newTestCharacter(t)
+
+	count := 0
+	start := time.Now()
+	err := Budget(20*time.Millisecond, counting(&count))(context.Background(), char)
+	if err != nil {
+		t.Fatalf("running out of time got %v, want no error", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("took %s with a 20ms budget", elapsed)
+	}
+	if count == 0 {
+		t.Error("didn't run anything")
+	}
+	if len(char.State) != 0 {
+		t.Errorf("states %v are left on the stack", char.State)
+	}
+}
+
+// Combinators nest, an Until inside a Budget stops for whichever comes first
+func TestBudgetAroundUntil(t *testing.T) {
+	char := newTestCharacter(t)
+
+	count := 0
+	err := Budget(time.Minute, Until(func(*character.Character) bool { return count >= 3 }, counting(&count)))(context.Background(), char)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("ran %d states, want 3", count)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	char := newTestCharacter(t)
+	errFirst := errors.New("first")
+	errSecond := errors.New("second")
+
+	var ran []int
+	option := func(n int, err error) Runner {
+		return func(ctx context.Context, char *character.Character) error {
+			ran = append(ran, n)
+			return err
+		}
+	}
+
+	if err := Fallback(option(1, errFirst), option(2, nil), option(3, nil))(context.Background(), char); err != nil {
+		t.Errorf("got %v, want the second option to succeed", err)
+	}
+	if len(ran) != 2 {
+		t.Errorf("ran options %v, want 1 and 2", ran)
+	}
+
+	err := Fallback(option(1, errFirst), option(2, errSecond))(context.Background(), char)
+	if !errors.Is(err, errFirst) || !errors.Is(err, errSecond) {
+		t.Errorf("got %v, want both options' errors", err)
+	}
+
+	// Being interrupted goes straight up instead of trying the next option
+	ran = nil
+	err = Fallback(option(1, interruptedErr{interrupt: &interrupt{}}), option(2, nil))(context.Background(), char)
+	if !errors.Is(err, ErrInterrupted) {
+		t.Errorf("got %v, want it interrupted", err)
+	}
+	if len(ran) != 1 {
+		t.Errorf("ran options %v after an interrupt, want only 1", ran)
+	}
+	if len(char.State) != 0 {
+		t.Errorf("states %v are left on the stack", char.State)
+	}
+}
+
+func TestRepeatN(t *testing.T) {
+	char := newTestCharacter(t)
+	errStop := errors.New("stop")
+
+	runs := 0
+	once := func(ctx context.Context, char *character.Character) error {
+		runs++
+		if runs == 5 {
+			return errStop
+		}
+		return nil
+	}
+
+	if err := RepeatN(3, once)(context.Background(), char); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 3 {
+		t.Errorf("ran %d times, want 3", runs)
+	}
+
+	if err := RepeatN(3, once)(context.Background(), char); !errors.Is(err, errStop) {
+		t.Errorf("got %v, want the fifth run's error", err)
+	}
+	if runs != 5 {
+		t.Errorf("ran %d times, want it to stop at 5", runs)
+	}
+	if len(char.State) != 0 {
+		t.Errorf("states %v are left on the stack", char.State)
+	}
+}
+
+func TestPreemptible(t *testing.T) {
+	char := newTestCharacter(t)
+
+	count := 0
+	preempted := 0
+	// Preempt once after 3 states, then finish after 6
+	higher := func(ctx context.Context, char *character.Character) error {
+		preempted++
+		return nil
+	}
+	when := func(*character.Character) bool { return count >= 3 && preempted == 0 }
+	runner := Until(func(*character.Character) bool { return count >= 6 }, counting(&count))
+
+	if err := Preemptible(runner, when, higher)(context.Background(), char); err != nil {
+		t.Fatal(err)
+	}
+	if preempted != 1 {
+		t.Errorf("preempted %d times, want 1", preempted)
+	}
+	if count != 6 {
+		t.Errorf("ran %d states, want 6", count)
+	}
+	if len(char.State) != 0 {
+		t.Errorf("states %v are left on the stack", char.State)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	char := newTestCharacter(t)
+
+	count := 0
+	start := time.Now()
+	err := Timeout(20*time.Millisecond, counting(&count))(context.Background(), char)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("running out of time got %v, want ErrTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("took %s with a 20ms timeout", elapsed)
+	}
+	if count == 0 {
+		t.Error("didn't run anything")
+	}
+
+	// Finishing in time isn't an error
+	count = 0
+	err = Timeout(time.Minute, Until(func(*character.Character) bool { return count >= 3 }, counting(&count)))(context.Background(), char)
+	if err != nil {
+		t.Errorf("finishing in time got %v", err)
+	}
+	if len(char.State) != 0 {
+		t.Errorf("states %v are left on the stack", char.State)
+	}
+}
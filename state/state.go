@@ -2,38 +2,98 @@ package state
 
 import (
 	"context"
+	"errors"
 	"github.com/ahornerr/artifacts/character"
+	"log"
 )
 
 type State[T any] func(ctx context.Context, char *character.Character, args T) (State[T], error)
 
+// Run the state machine until a state returns nil. When ctx has checkpoints and args is a Checkpointer, progress is
+// checkpointed after every state and a state machine that was interrupted by a restart picks up where it left off.
+// Interrupts from combinators like Until are checked before every state. A state machine that's preempted by a
+// Trigger keeps its progress and picks it back up the next time it runs.
 func Run[T any](ctx context.Context, char *character.Character, start State[T], args T) error {
+	checkpoints := checkpointsFrom(ctx)
+	suspended := suspendedFrom(ctx)
+	checkpointer, ok := any(args).(Checkpointer)
+	if !ok {
+		checkpoints = nil
+		suspended = nil
+	}
+
+	resumed := false
+	if suspended != nil {
+		var err error
+		resumed, err = suspended.resume(checkpointer)
+		if err != nil {
+			log.Println(char.Name, "error resuming preempted", checkpointer.CheckpointKey(), err)
+		}
+	}
+	if checkpoints != nil && !resumed {
+		var err error
+		resumed, err = checkpoints.resume(char.Name, checkpointer)
+		if err != nil {
+			log.Println(char.Name, "error resuming", checkpointer.CheckpointKey(), "checkpoint:", err)
+		}
+	}
+	if resumed {
+		log.Println(char.Name, "resuming", checkpointer.CheckpointKey())
+	}
+
+	stopped := func(err error) error {
+		if suspended != nil && isPreempted(err) {
+			// The checkpoint stays too in case we're restarted before getting back to it
+			if err := suspended.suspend(checkpointer); err != nil {
+				log.Println(char.Name, "error suspending", checkpointer.CheckpointKey(), err)
+			}
+			return err
+		}
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrInterrupted) {
+			// Stopped on purpose, there's nothing to resume
+			clearCheckpoint(checkpoints, char, checkpointer)
+		}
+		// Anything else is kept so the next run after a restart can try again from here
+		return err
+	}
+
 	var err error
 	current := start
 	for {
 		if ctx.Err() != nil {
-			return ctx.Err()
+			return stopped(ctx.Err())
+		}
+		if err = interrupted(ctx, char); err != nil {
+			return stopped(err)
 		}
 		current, err = current(ctx, char, args)
 		if err != nil {
-			return err
+			return stopped(err)
 		}
 		if current == nil {
+			clearCheckpoint(checkpoints, char, checkpointer)
 			return nil
 		}
+		saveCheckpoint(checkpoints, char, checkpointer)
 	}
 }
 
-type Runner func(ctx context.Context, char *character.Character) error
+func saveCheckpoint(checkpoints *Checkpoints, char *character.Character, checkpointer Checkpointer) {
+	if checkpoints == nil {
+		return
+	}
+	if err := checkpoints.save(char.Name, checkpointer); err != nil {
+		log.Println(char.Name, "error saving", checkpointer.CheckpointKey(), "checkpoint:", err)
+	}
+}
 
-func Loop(runners ...Runner) Runner {
-	return func(ctx context.Context, char *character.Character) error {
-		for {
-			for _, runner := range runners {
-				if err := runner(ctx, char); err != nil {
-					return err
-				}
-			}
-		}
+func clearCheckpoint(checkpoints *Checkpoints, char *character.Character, checkpointer Checkpointer) {
+	if checkpoints == nil {
+		return
+	}
+	if err := checkpoints.clear(char.Name, checkpointer); err != nil {
+		log.Println(char.Name, "error clearing", checkpointer.CheckpointKey(), "checkpoint:", err)
 	}
 }
+
+type Runner func(ctx context.Context, char *character.Character) error
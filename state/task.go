@@ -2,11 +2,14 @@ package state
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/ahornerr/artifacts/character"
-	"github.com/ahornerr/artifacts/game"
 	"github.com/promiseofcake/artifactsmmo-go-client/client"
 )
 
+// taskObjective picks equipment for task fights. Tasks are the grind, so make the most of every fight's XP.
+var taskObjective = character.Objective{Profile: character.ProfileXpPerHour}
+
 type TaskArgs struct {
 	// TODO: Equipment override?
 	// TODO: Bank first?
@@ -18,6 +21,29 @@ type TaskArgs struct {
 	stop func(*character.Character, *TaskArgs) bool
 }
 
+// taskProgress leaves out the task itself, the character always has the current one
+type taskProgress struct {
+	Rewards        map[string]int `json:"rewards"`
+	TasksCompleted int            `json:"tasks_completed"`
+}
+
+func (t *TaskArgs) CheckpointKey() string {
+	return "task"
+}
+
+func (t *TaskArgs) MarshalProgress() ([]byte, error) {
+	return json.Marshal(taskProgress{Rewards: t.Rewards, TasksCompleted: t.TasksCompleted})
+}
+
+func (t *TaskArgs) UnmarshalProgress(data []byte) error {
+	progress := taskProgress{Rewards: t.Rewards}
+	if err := json.Unmarshal(data, &progress); err != nil {
+		return err
+	}
+	t.Rewards, t.TasksCompleted = progress.Rewards, progress.TasksCompleted
+	return nil
+}
+
 func Task(stop func(*character.Character, *TaskArgs) bool) Runner {
 	return func(ctx context.Context, char *character.Character) error {
 		return Run(ctx, char, TaskLoop, NewTaskArgs(stop))
@@ -36,7 +62,7 @@ func TaskLoop(ctx context.Context, char *character.Character, args *TaskArgs) (S
 	// Complete task if possible
 	// TODO: Support other task types
 	if char.Task != "" && char.TaskProgress == char.TaskTotal {
-		err := MoveToClosest(ctx, char, game.Maps.GetTaskMasters("monsters"))
+		err := MoveToClosest(ctx, char, char.Catalog().Maps.GetTaskMasters("monsters"))
 		if err != nil {
 			return nil, err
 		}
@@ -61,7 +87,7 @@ func TaskLoop(ctx context.Context, char *character.Character, args *TaskArgs) (S
 
 	// Get new task
 	if char.Task == "" {
-		err := MoveToClosest(ctx, char, game.Maps.GetTaskMasters("monsters"))
+		err := MoveToClosest(ctx, char, char.Catalog().Maps.GetTaskMasters("monsters"))
 		if err != nil {
 			return nil, err
 		}
@@ -73,9 +99,10 @@ func TaskLoop(ctx context.Context, char *character.Character, args *TaskArgs) (S
 	}
 
 	// Bank if full, get better equipment, move to monster, fight once
-	fightArgs := NewFightArgs(char.Task, func(c *character.Character, _ *FightArgs) bool {
+	fightArgs := NewFightArgs(char.Catalog(), char.Task, func(c *character.Character, _ *FightArgs) bool {
 		return args.stop != nil && args.stop(char, args) || c.TaskProgress >= c.TaskTotal
 	}, nil)
+	fightArgs.Objective = taskObjective
 	err := Run(ctx, char, FightLoop, fightArgs)
 	if err != nil {
 		return nil, err
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"github.com/ahornerr/artifacts/character"
 	"github.com/ahornerr/artifacts/game"
+	"github.com/ahornerr/artifacts/plan"
 	"slices"
 )
 
@@ -45,7 +46,7 @@ func TaskCraftingLoop(ctx context.Context, char *character.Character, args *Task
 
 		attackUpgrades := slices.Clone(haveLevelToCraft)
 		resistUpgrades := slices.Clone(haveLevelToCraft)
-		monster := game.Monsters.Get(otherChar.Task)
+		monster := char.Catalog().Monsters.Get(otherChar.Task)
 
 		slices.SortFunc(attackUpgrades, func(a, b *game.Item) int {
 			dmg := int(b.Stats.GetDamageAgainst(monster.Stats) - a.Stats.GetDamageAgainst(monster.Stats))
@@ -77,7 +78,7 @@ func TaskCraftingLoop(ctx context.Context, char *character.Character, args *Task
 
 		//possibleUpgradesFor := map[*game.Item][]*game.Item{}
 		for slot, itemCode := range otherChar.Equipment {
-			equipped := game.Items.Get(itemCode)
+			equipped := char.Catalog().Items.Get(itemCode)
 			itemType := slot
 			if itemType == "ring1" || itemType == "ring2" {
 				itemType = "ring"
@@ -137,7 +138,7 @@ func TaskCraftingLoop(ctx context.Context, char *character.Character, args *Task
 			continue
 		}
 
-		craftingArgs := NewCraftArgs(item.Code, quantity, false, nil)
+		craftingArgs := NewCraftArgs(char.Catalog(), item.Code, quantity, false, nil)
 		err := Run(ctx, char, CraftingLoop, craftingArgs)
 		if err != nil {
 			return nil, err
@@ -149,27 +150,40 @@ func TaskCraftingLoop(ctx context.Context, char *character.Character, args *Task
 		}
 	}
 
-	craftingMaterials := map[*game.Item]int{}
+	// Plan everything at once so intermediates more than one upgrade needs are only made once. toCraft already
+	// has what we own of the upgrades taken out, so they're left out of the stock.
+	var targets []game.ItemQuantity
+	stock := map[string]int{}
+	for itemCode, quantity := range char.Bank() {
+		stock[itemCode] += quantity
+	}
+	for itemCode, quantity := range char.Inventory {
+		stock[itemCode] += quantity
+	}
 	for item, quantity := range toCraft {
-		for reqItem, reqQuantity := range item.Crafting.Items {
-			craftingMaterials[reqItem] += quantity * reqQuantity
-		}
+		targets = append(targets, game.ItemQuantity{Item: item, Quantity: quantity})
+		delete(stock, item.Code)
 	}
 
-	for item, quantity := range craftingMaterials {
-		err := CollectItems(item.Code, quantity, true, false, args.characters)(ctx, char)
-		if err != nil {
+	p, err := plan.New(char.Catalog(), targets, stock)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, step := range p.Steps {
+		switch step.Action {
+		case plan.ActionCraft:
+			// Upgrades go to the bank for whoever needs them, intermediates stay on hand for the next craft
+			_, isTarget := toCraft[step.Item]
+			err = Craft(step.Item.Code, step.Quantity, isTarget, nil)(ctx, char)
+		default:
+			// CollectItems counts the bank and inventory, so it's asked for the total and not only the shortfall
+			err = CollectItems(step.Item.Code, p.Required[step.Item], true, false, args.characters)(ctx, char)
 			var fightErr FightErr
 			if errors.As(err, &fightErr) {
 				continue
 			}
-			return nil, err
 		}
-	}
-
-	for item, quantity := range toCraft {
-		runner := Craft(item.Code, quantity, true, nil)
-		err := runner(ctx, char)
 		if err != nil {
 			return nil, err
 		}
@@ -28,13 +28,13 @@ type TaskItemArgs struct {
 
 func TaskItem(itemCode string, quantity int, stop func(*character.Character, *TaskItemArgs) bool) Runner {
 	return func(ctx context.Context, char *character.Character) error {
-		return Run(ctx, char, TaskItemLoop, NewTaskItemArgs(itemCode, quantity, stop))
+		return Run(ctx, char, TaskItemLoop, NewTaskItemArgs(char.Catalog(), itemCode, quantity, stop))
 	}
 }
 
-func NewTaskItemArgs(itemCode string, quantity int, stop func(*character.Character, *TaskItemArgs) bool) *TaskItemArgs {
+func NewTaskItemArgs(catalog *game.Catalog, itemCode string, quantity int, stop func(*character.Character, *TaskItemArgs) bool) *TaskItemArgs {
 	return &TaskItemArgs{
-		Item:     game.Items.Get(itemCode),
+		Item:     catalog.Items.Get(itemCode),
 		Quantity: quantity,
 		stop:     stop,
 	}
@@ -61,7 +61,7 @@ func TaskItemLoop(ctx context.Context, char *character.Character, args *TaskItem
 			return nil, err
 		}
 
-		err = MoveToClosest(ctx, char, game.Maps.GetTaskMasters("monsters"))
+		err = MoveToClosest(ctx, char, char.Catalog().Maps.GetTaskMasters("monsters"))
 		if err != nil {
 			return nil, err
 		}
@@ -107,7 +107,7 @@ func TaskItemLoop(ctx context.Context, char *character.Character, args *TaskItem
 			return nil, err
 		}
 
-		err = MoveToClosest(ctx, char, game.Maps.GetTaskMasters("monsters"))
+		err = MoveToClosest(ctx, char, char.Catalog().Maps.GetTaskMasters("monsters"))
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/ahornerr/artifacts/bank"
+	"github.com/ahornerr/artifacts/character"
+	"github.com/ahornerr/artifacts/client"
+	"github.com/ahornerr/artifacts/game"
+	"github.com/ahornerr/artifacts/graph2"
+	"log"
+	"os"
+	"strconv"
+)
+
+// treeCommand prints the recipe and sources for an item: artifacts tree [-format dot|mermaid|json] [-owned] item [quantity]
+func treeCommand(args []string) {
+	flags := flag.NewFlagSet("tree", flag.ExitOnError)
+	format := flags.String("format", graph2.FormatMermaid, "output format: dot, mermaid or json")
+	withOwned := flags.Bool("owned", false, "annotate every item with how many are in the bank and on the config's characters")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: artifacts tree [flags] item [quantity]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	quantity := 1
+	if flags.NArg() == 2 {
+		var err error
+		quantity, err = strconv.Atoi(flags.Arg(1))
+		if err != nil || quantity < 1 {
+			log.Fatalf("quantity must be a positive number, got %q", flags.Arg(1))
+		}
+	}
+
+	cfg, _ := loadConfig()
+	c, err := client.NewWithServer(account(cfg))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	snapshotPath := os.Getenv("ARTIFACTS_SNAPSHOT")
+	if snapshotPath == "" {
+		snapshotPath = "game_data.json"
+	}
+
+	catalog, err := game.LoadCatalogCached(ctx, c, snapshotPath)
+	if err != nil {
+		log.Fatalf("loading game data: %s", err)
+	}
+
+	item := catalog.Items.Get(flags.Arg(0))
+	if item == nil {
+		log.Fatalf("no item %q", flags.Arg(0))
+	}
+
+	var owned map[string]int
+	if *withOwned {
+		theBank := bank.NewBank(c)
+		if _, err = theBank.Load(ctx); err != nil {
+			log.Fatalf("loading bank items: %s", err)
+		}
+
+		// Nobody is watching the characters, their updates go nowhere
+		updates := make(chan *character.Character)
+		go func() {
+			for range updates {
+			}
+		}()
+
+		characters := map[string]*character.Character{}
+		for _, charCfg := range cfg.Characters {
+			char := character.NewCharacter(c, theBank, catalog, updates, charCfg.Name)
+			if _, err = char.Get(ctx); err != nil {
+				log.Fatalf("loading %s: %s", charCfg.Name, err)
+			}
+			characters[charCfg.Name] = char
+		}
+		owned = countOwned(theBank.Snapshot().Items(), characters)
+	}
+
+	err = graph2.NewTree(catalog, item, quantity, owned).Write(os.Stdout, *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// countOwned adds up the bank and everything the characters have in their inventories and equipped
+func countOwned(bankItems map[string]int, characters map[string]*character.Character) map[string]int {
+	owned := map[string]int{}
+	for itemCode, quantity := range bankItems {
+		owned[itemCode] += quantity
+	}
+	for _, char := range characters {
+		for itemCode, quantity := range char.Items() {
+			owned[itemCode] += quantity
+		}
+	}
+	return owned
+}